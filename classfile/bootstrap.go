@@ -0,0 +1,98 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	BOOTSTRAP_METHODS = "BootstrapMethods"
+)
+
+// BootstrapMethod is an entry of the BootstrapMethods attribute, it links the call sites of
+// invokedynamic instructions. MethodHandle and Arguments are constant pool indices
+type BootstrapMethod struct {
+	MethodHandle uint16
+	Arguments    []uint16
+}
+
+// BootstrapMethods returns the entries of the BootstrapMethods attribute of the class
+func (c *Class) BootstrapMethods() []BootstrapMethod {
+	return c.bootstrapMethods
+}
+
+// AddBootstrapMethod returns the index of an equal bootstrap method if there already is one and appends it otherwise
+func (c *Class) AddBootstrapMethod(method BootstrapMethod) uint16 {
+	for index, existing := range c.bootstrapMethods {
+		if existing.MethodHandle == method.MethodHandle && slices.Equal(existing.Arguments, method.Arguments) {
+			return uint16(index)
+		}
+	}
+	c.bootstrapMethods = append(c.bootstrapMethods, method)
+	return uint16(len(c.bootstrapMethods) - 1)
+}
+
+// AddMethodHandle adds a method handle of one of the REF_ kinds for a method of a class. Targets without
+// invokedynamic cannot contain method handles, which is recorded as error of the constant pool
+func (c *Class) AddMethodHandle(kind byte, class, name, descriptor string) uint16 {
+	if !c.target.SupportsInvokeDynamic() {
+		c.constPool.fail("method handles are not supported by %v", c.target)
+		return 0
+	}
+	return c.constPool.MethodHandle(kind, c.constPool.Methodref(class, name, descriptor))
+}
+
+// AddInvokeDynamic adds the call site of an invokedynamic instruction, which is linked by the bootstrap method
+func (c *Class) AddInvokeDynamic(bootstrap BootstrapMethod, name, descriptor string) uint16 {
+	if !c.target.SupportsInvokeDynamic() {
+		c.constPool.fail("invokedynamic is not supported by %v", c.target)
+		return 0
+	}
+	return c.constPool.InvokeDynamic(c.AddBootstrapMethod(bootstrap), name, descriptor)
+}
+
+func (c *Class) convertBootstrapMethodsToBytes() []byte {
+	data := binary.BigEndian.AppendUint16(nil, uint16(len(c.bootstrapMethods)))
+	for _, method := range c.bootstrapMethods {
+		data = binary.BigEndian.AppendUint16(data, method.MethodHandle)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(method.Arguments)))
+		for _, argument := range method.Arguments {
+			data = binary.BigEndian.AppendUint16(data, argument)
+		}
+	}
+	return data
+}
+
+func readBootstrapMethods(data []byte) ([]BootstrapMethod, error) {
+	r := &classReader{data: data}
+	count := int(r.u2())
+	methods := make([]BootstrapMethod, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		method := BootstrapMethod{MethodHandle: r.u2()}
+		argumentCount := int(r.u2())
+		for j := 0; j < argumentCount && r.err == nil; j++ {
+			method.Arguments = append(method.Arguments, r.u2())
+		}
+		methods = append(methods, method)
+	}
+	if r.err == nil && r.offset != len(data) {
+		return nil, fmt.Errorf("error: %v trailing bytes in the BootstrapMethods attribute", len(data)-r.offset)
+	}
+	return methods, r.err
+}
+
+func (c *Class) disassembleBootstrapMethods(w io.Writer) {
+	if len(c.bootstrapMethods) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "BootstrapMethods:")
+	for index, method := range c.bootstrapMethods {
+		fmt.Fprintf(w, "  %v: #%v %v\n", index, method.MethodHandle, c.constPool.describe(method.MethodHandle))
+		fmt.Fprintln(w, "    Method arguments:")
+		for _, argument := range method.Arguments {
+			fmt.Fprintf(w, "      #%v %v\n", argument, c.constPool.describe(argument))
+		}
+	}
+}
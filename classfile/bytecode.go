@@ -0,0 +1,146 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instruction struct {
+	Offset   int
+	Opcode   byte
+	Wide     bool
+	Operands []byte
+	Targets  []int
+}
+
+func (i Instruction) Length() int {
+	if i.Wide {
+		return len(i.Operands) + 2
+	}
+	return len(i.Operands) + 1
+}
+
+func (i Instruction) U8() int {
+	return int(i.Operands[0])
+}
+
+func (i Instruction) U16() uint16 {
+	return binary.BigEndian.Uint16(i.Operands)
+}
+
+// LocalIndex returns the local variable index of load, store, iinc and ret instructions
+func (i Instruction) LocalIndex() int {
+	if i.Wide {
+		return int(i.U16())
+	}
+	return i.U8()
+}
+
+// IsUnconditionalJump reports whether execution never continues with the following instruction
+func (i Instruction) IsUnconditionalJump() bool {
+	return instructions.Opcodes[i.Opcode].Unconditional
+}
+
+func DecodeInstructions(code []byte) ([]Instruction, error) {
+	insts := make([]Instruction, 0)
+	offset := 0
+	for offset < len(code) {
+		inst, err := decodeInstruction(code, offset)
+		if err != nil {
+			return nil, err
+		}
+		insts = append(insts, inst)
+		offset += inst.Length()
+	}
+	return insts, nil
+}
+
+func decodeInstruction(code []byte, offset int) (Instruction, error) {
+	inst := Instruction{Offset: offset, Opcode: code[offset]}
+	start := offset + 1
+	if inst.Opcode == instructions.WIDE {
+		if start >= len(code) {
+			return inst, fmt.Errorf("error: truncated wide instruction at %v", offset)
+		}
+		inst.Wide = true
+		inst.Opcode = code[start]
+		start++
+	}
+	length, err := operandLength(inst, code, start)
+	if err != nil {
+		return inst, err
+	}
+	if start+length > len(code) {
+		return inst, fmt.Errorf("error: truncated instruction at %v", offset)
+	}
+	inst.Operands = code[start : start+length]
+	inst.Targets = branchTargets(inst, start)
+	return inst, nil
+}
+
+func operandLength(inst Instruction, code []byte, start int) (int, error) {
+	opcode, ok := instructions.Lookup(inst.Opcode)
+	if !ok {
+		return 0, fmt.Errorf("error: unknown opcode 0x%x", inst.Opcode)
+	}
+	if inst.Wide && !opcode.CanBeWide() {
+		return 0, fmt.Errorf("error: opcode 0x%x cannot be widened", inst.Opcode)
+	}
+	if opcode.Operands == instructions.SWITCH {
+		return switchLength(inst.Opcode, code, start)
+	}
+	return opcode.OperandLength(inst.Wide), nil
+}
+
+func switchLength(op byte, code []byte, start int) (int, error) {
+	padding := (4 - start%4) % 4
+	header := padding + 12
+	if op == instructions.LOOKUPSWITCH {
+		header = padding + 8
+	}
+	if start+header > len(code) {
+		return 0, fmt.Errorf("error: truncated switch instruction at %v", start-1)
+	}
+	if op == instructions.TABLESWITCH {
+		low := int32(binary.BigEndian.Uint32(code[start+padding+4:]))
+		high := int32(binary.BigEndian.Uint32(code[start+padding+8:]))
+		if high < low {
+			return 0, fmt.Errorf("error: invalid tableswitch at %v", start-1)
+		}
+		return header + int(high-low+1)*4, nil
+	}
+	pairs := int32(binary.BigEndian.Uint32(code[start+padding+4:]))
+	if pairs < 0 {
+		return 0, fmt.Errorf("error: invalid lookupswitch at %v", start-1)
+	}
+	return header + int(pairs)*8, nil
+}
+
+func branchTargets(inst Instruction, start int) []int {
+	offset := start - 1
+	switch instructions.Opcodes[inst.Opcode].Operands {
+	case instructions.BRANCH:
+		return []int{offset + int(int16(inst.U16()))}
+	case instructions.BRANCH_W:
+		return []int{offset + int(int32(binary.BigEndian.Uint32(inst.Operands)))}
+	case instructions.SWITCH:
+		padding := (4 - start%4) % 4
+		data := inst.Operands[padding:]
+		targets := []int{offset + int(int32(binary.BigEndian.Uint32(data)))}
+		data = data[4:]
+		if inst.Opcode == instructions.TABLESWITCH {
+			data = data[8:]
+			for i := 0; i+4 <= len(data); i += 4 {
+				targets = append(targets, offset+int(int32(binary.BigEndian.Uint32(data[i:]))))
+			}
+		} else {
+			data = data[4:]
+			for i := 0; i+8 <= len(data); i += 8 {
+				targets = append(targets, offset+int(int32(binary.BigEndian.Uint32(data[i+4:]))))
+			}
+		}
+		return targets
+	}
+	return nil
+}
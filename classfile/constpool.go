@@ -0,0 +1,423 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+const (
+	CONSTANT_UNUSABLE           = 0
+	CONSTANT_UTF8               = 1
+	CONSTANT_INTEGER            = 3
+	CONSTANT_FLOAT              = 4
+	CONSTANT_LONG               = 5
+	CONSTANT_DOUBLE             = 6
+	CONSTANT_CLASS              = 7
+	CONSTANT_STRING             = 8
+	CONSTANT_FIELDREF           = 9
+	CONSTANT_METHODREF          = 10
+	CONSTANT_INTERFACEMETHODREF = 11
+	CONSTANT_NAMEANDTYPE        = 12
+	CONSTANT_METHODHANDLE       = 15
+	CONSTANT_METHODTYPE         = 16
+	CONSTANT_DYNAMIC            = 17
+	CONSTANT_INVOKEDYNAMIC      = 18
+	CONSTANT_MODULE             = 19
+	CONSTANT_PACKAGE            = 20
+)
+
+const (
+	REF_GETFIELD         = 1
+	REF_GETSTATIC        = 2
+	REF_PUTFIELD         = 3
+	REF_PUTSTATIC        = 4
+	REF_INVOKEVIRTUAL    = 5
+	REF_INVOKESTATIC     = 6
+	REF_INVOKESPECIAL    = 7
+	REF_NEWINVOKESPECIAL = 8
+	REF_INVOKEINTERFACE  = 9
+)
+
+const (
+	// the length of a CONSTANT_Utf8 entry is stored in two bytes
+	MAX_UTF8_LENGTH = 0xffff
+)
+
+type Const struct {
+	Tag                      byte
+	NameIndex                uint16
+	ClassIndex               uint16
+	NameAndTypeIndex         uint16
+	StringIndex              uint16
+	DescIndex                uint16
+	ReferenceKind            byte
+	ReferenceIndex           uint16
+	BootstrapMethodAttrIndex uint16
+	Integer                  int32
+	Long                     int64
+	Float                    float32
+	Double                   float64
+	String                   string
+}
+
+// constKey compares floating point constants by their bits, so that 0.0 and -0.0 or NaNs are interned correctly
+type constKey struct {
+	c    Const
+	bits uint64
+}
+
+func keyOf(c Const) constKey {
+	key := constKey{c: c}
+	if c.Tag == CONSTANT_FLOAT {
+		key.bits = uint64(math.Float32bits(c.Float))
+	} else if c.Tag == CONSTANT_DOUBLE {
+		key.bits = math.Float64bits(c.Double)
+	}
+	key.c.Float, key.c.Double = 0, 0
+	return key
+}
+
+type ConstPool struct {
+	entries []Const
+	indices map[constKey]uint16
+	// the first constant that could not be added, the class file cannot be written after it
+	err error
+}
+
+func NewConstPool() *ConstPool {
+	return &ConstPool{entries: make([]Const, 0), indices: make(map[constKey]uint16)}
+}
+
+func (cp *ConstPool) fail(format string, args ...any) {
+	if cp.err == nil {
+		cp.err = fmt.Errorf("error: "+format, args...)
+	}
+}
+
+// AddConst returns the index of an equal entry if there already is one and appends the constant otherwise.
+// Constants that do not fit into the pool are recorded as error and get the index 0
+func (cp *ConstPool) AddConst(c Const) uint16 {
+	key := keyOf(c)
+	if index, ok := cp.indices[key]; ok {
+		return index
+	}
+	size := 1
+	if c.Tag == CONSTANT_LONG || c.Tag == CONSTANT_DOUBLE {
+		size = 2
+	}
+	if len(cp.entries)+size > 0xfffe {
+		cp.fail("too many constants (the constant pool is limited to 65535 entries)")
+		return 0
+	}
+	cp.entries = append(cp.entries, c)
+	index := uint16(len(cp.entries))
+	// long and double constants take up two entries
+	if size == 2 {
+		cp.entries = append(cp.entries, Const{Tag: CONSTANT_UNUSABLE})
+	}
+	cp.indices[key] = index
+	return index
+}
+
+// Count is the constant_pool_count of the class file which is one larger than the number of entries
+func (cp *ConstPool) Count() int {
+	return len(cp.entries) + 1
+}
+
+func (cp *ConstPool) Utf8(value string) uint16 {
+	if length := ModifiedUtf8Length(value); length > MAX_UTF8_LENGTH {
+		cp.fail("constant string too long (%v bytes, the limit is %v)", length, MAX_UTF8_LENGTH)
+		return 0
+	}
+	return cp.AddConst(Const{Tag: CONSTANT_UTF8, String: value})
+}
+
+func (cp *ConstPool) Class(name string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_CLASS, NameIndex: cp.Utf8(name)})
+}
+
+func (cp *ConstPool) String(value string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_STRING, StringIndex: cp.Utf8(value)})
+}
+
+func (cp *ConstPool) Integer(value int32) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_INTEGER, Integer: value})
+}
+
+func (cp *ConstPool) Long(value int64) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_LONG, Long: value})
+}
+
+func (cp *ConstPool) Float(value float32) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_FLOAT, Float: value})
+}
+
+func (cp *ConstPool) Double(value float64) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_DOUBLE, Double: value})
+}
+
+func (cp *ConstPool) NameAndType(name, descriptor string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_NAMEANDTYPE, NameIndex: cp.Utf8(name), DescIndex: cp.Utf8(descriptor)})
+}
+
+func (cp *ConstPool) Fieldref(class, name, descriptor string) uint16 {
+	return cp.memberRef(CONSTANT_FIELDREF, class, name, descriptor)
+}
+
+func (cp *ConstPool) Methodref(class, name, descriptor string) uint16 {
+	return cp.memberRef(CONSTANT_METHODREF, class, name, descriptor)
+}
+
+func (cp *ConstPool) InterfaceMethodref(class, name, descriptor string) uint16 {
+	return cp.memberRef(CONSTANT_INTERFACEMETHODREF, class, name, descriptor)
+}
+
+func (cp *ConstPool) memberRef(tag byte, class, name, descriptor string) uint16 {
+	classIndex := cp.Class(class)
+	nameAndType := cp.NameAndType(name, descriptor)
+	return cp.AddConst(Const{Tag: tag, ClassIndex: classIndex, NameAndTypeIndex: nameAndType})
+}
+
+// MethodHandle references a field or method entry with one of the REF_ kinds
+func (cp *ConstPool) MethodHandle(kind byte, reference uint16) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_METHODHANDLE, ReferenceKind: kind, ReferenceIndex: reference})
+}
+
+func (cp *ConstPool) MethodType(descriptor string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_METHODTYPE, DescIndex: cp.Utf8(descriptor)})
+}
+
+// InvokeDynamic references an entry of the BootstrapMethods attribute
+func (cp *ConstPool) InvokeDynamic(bootstrapMethod uint16, name, descriptor string) uint16 {
+	nameAndType := cp.NameAndType(name, descriptor)
+	return cp.AddConst(Const{Tag: CONSTANT_INVOKEDYNAMIC, BootstrapMethodAttrIndex: bootstrapMethod, NameAndTypeIndex: nameAndType})
+}
+
+func (cp *ConstPool) Dynamic(bootstrapMethod uint16, name, descriptor string) uint16 {
+	nameAndType := cp.NameAndType(name, descriptor)
+	return cp.AddConst(Const{Tag: CONSTANT_DYNAMIC, BootstrapMethodAttrIndex: bootstrapMethod, NameAndTypeIndex: nameAndType})
+}
+
+func (cp *ConstPool) Module(name string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_MODULE, NameIndex: cp.Utf8(name)})
+}
+
+func (cp *ConstPool) Package(name string) uint16 {
+	return cp.AddConst(Const{Tag: CONSTANT_PACKAGE, NameIndex: cp.Utf8(name)})
+}
+
+func (cp *ConstPool) get(index uint16) (Const, error) {
+	if index == 0 || int(index) > len(cp.entries) || cp.entries[index-1].Tag == CONSTANT_UNUSABLE {
+		return Const{}, fmt.Errorf("error: invalid constant pool index %v", index)
+	}
+	return cp.entries[index-1], nil
+}
+
+func (cp *ConstPool) utf8(index uint16) (string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return "", err
+	}
+	if c.Tag != CONSTANT_UTF8 {
+		return "", fmt.Errorf("error: constant pool entry %v is not a utf8 constant", index)
+	}
+	return c.String, nil
+}
+
+// memberDescriptor resolves the descriptor of a field, method or invokedynamic reference
+func (cp *ConstPool) memberDescriptor(index uint16) (string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return "", err
+	}
+	if c.Tag != CONSTANT_FIELDREF && c.Tag != CONSTANT_METHODREF && c.Tag != CONSTANT_INTERFACEMETHODREF && c.Tag != CONSTANT_INVOKEDYNAMIC {
+		return "", fmt.Errorf("error: constant pool entry %v is not a member reference", index)
+	}
+	nameAndType, err := cp.get(c.NameAndTypeIndex)
+	if err != nil {
+		return "", err
+	}
+	if nameAndType.Tag != CONSTANT_NAMEANDTYPE {
+		return "", fmt.Errorf("error: constant pool entry %v is not a name and type", c.NameAndTypeIndex)
+	}
+	return cp.utf8(nameAndType.DescIndex)
+}
+
+func (cp *ConstPool) className(index uint16) (string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return "", err
+	}
+	if c.Tag != CONSTANT_CLASS {
+		return "", fmt.Errorf("error: constant pool entry %v is not a class", index)
+	}
+	return cp.utf8(c.NameIndex)
+}
+
+func (cp *ConstPool) memberClassName(index uint16) (string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return "", err
+	}
+	return cp.className(c.ClassIndex)
+}
+
+func (cp *ConstPool) memberName(index uint16) (string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return "", err
+	}
+	nameAndType, err := cp.get(c.NameAndTypeIndex)
+	if err != nil {
+		return "", err
+	}
+	return cp.utf8(nameAndType.NameIndex)
+}
+
+// Get returns the constant at the given index
+func (cp *ConstPool) Get(index uint16) (Const, error) {
+	return cp.get(index)
+}
+
+// Utf8Value returns the string stored in the utf8 constant at the given index
+func (cp *ConstPool) Utf8Value(index uint16) (string, error) {
+	return cp.utf8(index)
+}
+
+// MemberRef resolves the class, name and descriptor of a field or method reference
+func (cp *ConstPool) MemberRef(index uint16) (string, string, string, error) {
+	class, err := cp.memberClassName(index)
+	if err != nil {
+		return "", "", "", err
+	}
+	name, err := cp.memberName(index)
+	if err != nil {
+		return "", "", "", err
+	}
+	descriptor, err := cp.memberDescriptor(index)
+	if err != nil {
+		return "", "", "", err
+	}
+	return class, name, descriptor, nil
+}
+
+// InvokeDynamicRef resolves the bootstrap method index, name and descriptor of an invokedynamic call site
+func (cp *ConstPool) InvokeDynamicRef(index uint16) (uint16, string, string, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return 0, "", "", err
+	}
+	if c.Tag != CONSTANT_INVOKEDYNAMIC {
+		return 0, "", "", fmt.Errorf("error: constant pool entry %v is not an invokedynamic call site", index)
+	}
+	name, err := cp.memberName(index)
+	if err != nil {
+		return 0, "", "", err
+	}
+	descriptor, err := cp.memberDescriptor(index)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return c.BootstrapMethodAttrIndex, name, descriptor, nil
+}
+
+// loadableType returns the type pushed by ldc, ldc_w (wide = false) or ldc2_w (wide = true)
+func (cp *ConstPool) loadableType(index uint16, wide bool) (VerificationType, error) {
+	c, err := cp.get(index)
+	if err != nil {
+		return topType, err
+	}
+	types := map[byte]VerificationType{
+		CONSTANT_INTEGER:      intType,
+		CONSTANT_FLOAT:        floatType,
+		CONSTANT_STRING:       stringType,
+		CONSTANT_CLASS:        objectOf("java/lang/Class"),
+		CONSTANT_METHODHANDLE: objectOf("java/lang/invoke/MethodHandle"),
+		CONSTANT_METHODTYPE:   objectOf("java/lang/invoke/MethodType"),
+	}
+	if wide {
+		types = map[byte]VerificationType{CONSTANT_LONG: longType, CONSTANT_DOUBLE: doubleType}
+	}
+	vt, ok := types[c.Tag]
+	if !ok {
+		return topType, fmt.Errorf("error: constant pool entry %v cannot be loaded by ldc", index)
+	}
+	return vt, nil
+}
+
+func (cp *ConstPool) convertToBytes() ([]byte, error) {
+	if cp.err != nil {
+		return nil, cp.err
+	}
+	constPoolAsBytes := make([]byte, 0)
+	for _, co := range cp.entries {
+		if co.Tag == CONSTANT_UNUSABLE {
+			continue
+		}
+		constAsBytes := make([]byte, 0)
+		constAsBytes = append(constAsBytes, co.Tag)
+		switch co.Tag {
+		case CONSTANT_UTF8:
+			valueInBytes := encodeModifiedUtf8(co.String)
+			if len(valueInBytes) > MAX_UTF8_LENGTH {
+				return nil, fmt.Errorf("error: constant string too long (%v bytes, the limit is %v)", len(valueInBytes), MAX_UTF8_LENGTH)
+			}
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, uint16(len(valueInBytes)))
+			constAsBytes = append(constAsBytes, valueInBytes...)
+		case CONSTANT_INTEGER:
+			constAsBytes = binary.BigEndian.AppendUint32(constAsBytes, uint32(co.Integer))
+		case CONSTANT_FLOAT:
+			constAsBytes = binary.BigEndian.AppendUint32(constAsBytes, math.Float32bits(co.Float))
+		case CONSTANT_LONG:
+			constAsBytes = binary.BigEndian.AppendUint64(constAsBytes, uint64(co.Long))
+		case CONSTANT_DOUBLE:
+			constAsBytes = binary.BigEndian.AppendUint64(constAsBytes, math.Float64bits(co.Double))
+		case CONSTANT_CLASS, CONSTANT_MODULE, CONSTANT_PACKAGE:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameIndex)
+		case CONSTANT_STRING:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.StringIndex)
+		case CONSTANT_FIELDREF, CONSTANT_METHODREF, CONSTANT_INTERFACEMETHODREF:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.ClassIndex)
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameAndTypeIndex)
+		case CONSTANT_NAMEANDTYPE:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameIndex)
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.DescIndex)
+		case CONSTANT_METHODHANDLE:
+			constAsBytes = append(constAsBytes, co.ReferenceKind)
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.ReferenceIndex)
+		case CONSTANT_METHODTYPE:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.DescIndex)
+		case CONSTANT_DYNAMIC, CONSTANT_INVOKEDYNAMIC:
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.BootstrapMethodAttrIndex)
+			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameAndTypeIndex)
+		default:
+			return nil, fmt.Errorf("error: unsupported const pool tag %v", co.Tag)
+		}
+		constPoolAsBytes = append(constPoolAsBytes, constAsBytes...)
+	}
+	return constPoolAsBytes, nil
+}
+
+// ModifiedUtf8Length returns the number of bytes a string takes in the constant pool
+func ModifiedUtf8Length(value string) int {
+	return len(encodeModifiedUtf8(value))
+}
+
+// encodeModifiedUtf8 encodes a string the way the jvm expects it: the null character takes two bytes
+// and characters outside of the basic multilingual plane are stored as surrogate pairs
+func encodeModifiedUtf8(value string) []byte {
+	encoded := make([]byte, 0, len(value))
+	for _, char := range utf16.Encode([]rune(value)) {
+		switch {
+		case char != 0 && char < 0x80:
+			encoded = append(encoded, byte(char))
+		case char < 0x800:
+			encoded = append(encoded, byte(0xc0|char>>6), byte(0x80|char&0x3f))
+		default:
+			encoded = append(encoded, byte(0xe0|char>>12), byte(0x80|(char>>6)&0x3f), byte(0x80|char&0x3f))
+		}
+	}
+	return encoded
+}
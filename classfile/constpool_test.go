@@ -0,0 +1,89 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConstPoolInterning(t *testing.T) {
+	cp := NewConstPool()
+	first := cp.Methodref("java/io/PrintStream", "println", "(I)V")
+	count := cp.Count()
+	if again := cp.Methodref("java/io/PrintStream", "println", "(I)V"); again != first || cp.Count() != count {
+		t.Errorf("an equal method reference was added again as %v (%v entries instead of %v)", again, cp.Count()-1, count-1)
+	}
+	other := cp.Methodref("java/io/PrintStream", "println", "(J)V")
+	entry, _ := cp.Get(first)
+	otherEntry, _ := cp.Get(other)
+	if other == first || entry.ClassIndex != otherEntry.ClassIndex {
+		t.Errorf("methods of the same class must be different entries that share the class entry")
+	}
+	if cp.String("println") == cp.Utf8("println") || cp.String("println") != cp.String("println") {
+		t.Errorf("a string constant must be interned separately from its utf8 entry")
+	}
+	if cp.Double(0) == cp.Double(math.Copysign(0, -1)) || cp.Float(float32(math.NaN())) != cp.Float(float32(math.NaN())) {
+		t.Errorf("floating point constants must be interned by their bits")
+	}
+	if cp.Integer(1) == cp.Float(1) || cp.Long(1) == cp.Double(1) {
+		t.Errorf("constants of different types must not be interned together")
+	}
+}
+
+func TestConstPoolWideEntries(t *testing.T) {
+	cp := NewConstPool()
+	long := cp.Long(1 << 40)
+	double := cp.Double(0.5)
+	integer := cp.Integer(7)
+	if long != 1 || double != 3 || integer != 5 || cp.Count() != 6 {
+		t.Errorf("expected long and double to take two entries but got the indices %v, %v and %v and the count %v", long, double, integer, cp.Count())
+	}
+	if _, err := cp.Get(long + 1); err == nil {
+		t.Errorf("the entry after a long must be unusable")
+	}
+}
+
+func TestConstPoolTags(t *testing.T) {
+	cp := NewConstPool()
+	cp.Utf8("café \x00 \U0001F600")
+	cp.Class("java/lang/String")
+	cp.String("hello")
+	cp.Integer(-5)
+	cp.Float(1.5)
+	cp.Long(-1 << 50)
+	cp.Double(-2.25)
+	cp.Fieldref("java/lang/System", "out", "Ljava/io/PrintStream;")
+	cp.InterfaceMethodref("java/util/List", "size", "()I")
+	cp.MethodHandle(REF_INVOKESTATIC, cp.Methodref("java/lang/Math", "abs", "(I)I"))
+	cp.MethodType("(I)I")
+	cp.InvokeDynamic(0, "run", "()Ljava/lang/Runnable;")
+	cp.Dynamic(1, "value", "I")
+	cp.Module("java.base")
+	cp.Package("java/lang")
+	data, err := cp.convertToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &classReader{data: append(binary.BigEndian.AppendUint16(nil, uint16(cp.Count())), data...)}
+	read := NewConstPool()
+	if err := r.readConstPool(read); err != nil {
+		t.Fatal(err)
+	}
+	if r.offset != len(r.data) || !reflect.DeepEqual(read.entries, cp.entries) {
+		t.Errorf("the constant pool was read as %v instead of %v", read.entries, cp.entries)
+	}
+}
+
+func TestConstPoolLimits(t *testing.T) {
+	class := NewClass("Limits", "java/lang/Object", NewTarget(MIN_RELEASE))
+	class.AddString(strings.Repeat("a", MAX_UTF8_LENGTH))
+	if _, err := class.ConvertToBytes(); err != nil {
+		t.Fatalf("a string of %v bytes must fit into the constant pool: %v", MAX_UTF8_LENGTH, err)
+	}
+	class.AddString(strings.Repeat("\x00", MAX_UTF8_LENGTH/2+1))
+	if _, err := class.ConvertToBytes(); err == nil || !strings.Contains(err.Error(), "constant string too long") {
+		t.Fatalf("expected an error for a string over %v bytes of modified UTF-8 but got %v", MAX_UTF8_LENGTH, err)
+	}
+}
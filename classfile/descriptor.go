@@ -0,0 +1,81 @@
+package classfile
+
+import "fmt"
+
+type MethodDescriptor struct {
+	Args       []string
+	ReturnType string
+}
+
+func ParseMethodDescriptor(descriptor string) (MethodDescriptor, error) {
+	if len(descriptor) == 0 || descriptor[0] != '(' {
+		return MethodDescriptor{}, fmt.Errorf("error: invalid method descriptor '%v'", descriptor)
+	}
+	md := MethodDescriptor{Args: make([]string, 0)}
+	index := 1
+	for index < len(descriptor) && descriptor[index] != ')' {
+		length, err := fieldDescriptorLength(descriptor[index:])
+		if err != nil {
+			return MethodDescriptor{}, fmt.Errorf("error: invalid method descriptor '%v'", descriptor)
+		}
+		md.Args = append(md.Args, descriptor[index:index+length])
+		index += length
+	}
+	if index >= len(descriptor) {
+		return MethodDescriptor{}, fmt.Errorf("error: invalid method descriptor '%v'", descriptor)
+	}
+	md.ReturnType = descriptor[index+1:]
+	if md.ReturnType != "V" {
+		length, err := fieldDescriptorLength(md.ReturnType)
+		if err != nil || length != len(md.ReturnType) {
+			return MethodDescriptor{}, fmt.Errorf("error: invalid method descriptor '%v'", descriptor)
+		}
+	}
+	return md, nil
+}
+
+// ArgSlots returns the amount of local variable / operand stack slots taken by the arguments
+func (md MethodDescriptor) ArgSlots() int {
+	slots := 0
+	for _, arg := range md.Args {
+		slots += SlotSize(arg)
+	}
+	return slots
+}
+
+// SlotSize returns how many stack slots a value of the given field descriptor occupies
+func SlotSize(descriptor string) int {
+	switch descriptor {
+	case "V":
+		return 0
+	case "J", "D":
+		return 2
+	}
+	return 1
+}
+
+func fieldDescriptorLength(descriptor string) (int, error) {
+	if len(descriptor) == 0 {
+		return 0, fmt.Errorf("error: empty field descriptor")
+	}
+	switch descriptor[0] {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return 1, nil
+	case 'L':
+		for i := 1; i < len(descriptor); i++ {
+			if descriptor[i] == ';' {
+				if i == 1 {
+					break
+				}
+				return i + 1, nil
+			}
+		}
+	case '[':
+		length, err := fieldDescriptorLength(descriptor[1:])
+		if err != nil {
+			return 0, err
+		}
+		return length + 1, nil
+	}
+	return 0, fmt.Errorf("error: invalid field descriptor '%v'", descriptor)
+}
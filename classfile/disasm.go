@@ -0,0 +1,358 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var constantNames map[byte]string = map[byte]string{
+	CONSTANT_UTF8:               "Utf8",
+	CONSTANT_INTEGER:            "Integer",
+	CONSTANT_FLOAT:              "Float",
+	CONSTANT_LONG:               "Long",
+	CONSTANT_DOUBLE:             "Double",
+	CONSTANT_CLASS:              "Class",
+	CONSTANT_STRING:             "String",
+	CONSTANT_FIELDREF:           "Fieldref",
+	CONSTANT_METHODREF:          "Methodref",
+	CONSTANT_INTERFACEMETHODREF: "InterfaceMethodref",
+	CONSTANT_NAMEANDTYPE:        "NameAndType",
+	CONSTANT_METHODHANDLE:       "MethodHandle",
+	CONSTANT_METHODTYPE:         "MethodType",
+	CONSTANT_DYNAMIC:            "Dynamic",
+	CONSTANT_INVOKEDYNAMIC:      "InvokeDynamic",
+	CONSTANT_MODULE:             "Module",
+	CONSTANT_PACKAGE:            "Package",
+}
+
+type accessFlag struct {
+	flag uint16
+	name string
+}
+
+var classFlags []accessFlag = []accessFlag{{0x0001, "ACC_PUBLIC"}, {0x0010, "ACC_FINAL"}, {0x0020, "ACC_SUPER"},
+	{0x0200, "ACC_INTERFACE"}, {0x0400, "ACC_ABSTRACT"}, {0x1000, "ACC_SYNTHETIC"}, {0x2000, "ACC_ANNOTATION"},
+	{0x4000, "ACC_ENUM"}, {0x8000, "ACC_MODULE"}}
+
+var memberFlags []accessFlag = []accessFlag{{0x0001, "ACC_PUBLIC"}, {0x0002, "ACC_PRIVATE"}, {0x0004, "ACC_PROTECTED"},
+	{0x0008, "ACC_STATIC"}, {0x0010, "ACC_FINAL"}, {0x0020, "ACC_SYNCHRONIZED"}, {0x0040, "ACC_BRIDGE"},
+	{0x0080, "ACC_VARARGS"}, {0x0100, "ACC_NATIVE"}, {0x0400, "ACC_ABSTRACT"}, {0x0800, "ACC_STRICT"},
+	{0x1000, "ACC_SYNTHETIC"}}
+
+var modifierFlags []accessFlag = []accessFlag{{0x0001, "public"}, {0x0002, "private"}, {0x0004, "protected"},
+	{0x0008, "static"}, {0x0010, "final"}, {0x0020, "synchronized"}, {0x0100, "native"}, {0x0400, "abstract"}}
+
+var arrayTypeNames map[int]string = map[int]string{4: "boolean", 5: "char", 6: "float", 7: "double", 8: "byte", 9: "short", 10: "int", 11: "long"}
+
+func Mnemonic(opcode byte) string {
+	if description, ok := instructions.Lookup(opcode); ok {
+		return description.Mnemonic
+	}
+	return fmt.Sprintf("<0x%x>", opcode)
+}
+
+func flagNames(flags uint16, names []accessFlag) string {
+	set := make([]string, 0)
+	for _, f := range names {
+		if flags&f.flag != 0 {
+			set = append(set, f.name)
+		}
+	}
+	return fmt.Sprintf("(0x%04x) %v", flags, strings.Join(set, ", "))
+}
+
+// modifiers returns the java keywords of the access flags followed by a space
+func modifiers(flags uint16) string {
+	keywords := ""
+	for _, f := range modifierFlags {
+		if flags&f.flag != 0 {
+			keywords += f.name + " "
+		}
+	}
+	return keywords
+}
+
+// javaTypeName turns a field descriptor into the type name used in java source code
+func javaTypeName(descriptor string) string {
+	names := map[byte]string{'B': "byte", 'C': "char", 'D': "double", 'F': "float", 'I': "int", 'J': "long", 'S': "short", 'Z': "boolean", 'V': "void"}
+	if name, ok := names[descriptor[0]]; ok {
+		return name
+	}
+	if descriptor[0] == '[' {
+		return javaTypeName(descriptor[1:]) + "[]"
+	}
+	return strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(descriptor, "L"), ";"), "/", ".")
+}
+
+// Disassemble prints the class in a listing similar to the one of javap -v
+func (c *Class) Disassemble(w io.Writer) {
+	// ACC_SUPER has the same value as ACC_SYNCHRONIZED
+	fmt.Fprintf(w, "%vclass %v", modifiers(c.flags&^ACC_SUPER), c.name)
+	if c.super != "" {
+		fmt.Fprintf(w, " extends %v", c.super)
+	}
+	if len(c.interfaces) > 0 {
+		fmt.Fprintf(w, " implements %v", strings.Join(c.interfaces, ", "))
+	}
+	fmt.Fprintf(w, "\n  minor version: %v\n  major version: %v\n", c.target.Minor, c.target.Major)
+	fmt.Fprintf(w, "  flags: %v\n", flagNames(c.flags, classFlags))
+	fmt.Fprintln(w, "Constant pool:")
+	for index := 1; index < c.constPool.Count(); index++ {
+		co, err := c.constPool.get(uint16(index))
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "%6v = %-18v %v\n", "#"+strconv.Itoa(index), constantNames[co.Tag], c.constPool.describeEntry(co))
+	}
+	fmt.Fprintln(w, "{")
+	for _, f := range c.fields {
+		fmt.Fprintf(w, "  %v%v %v;\n", modifiers(f.Flags), javaTypeName(f.Descriptor), f.Name)
+		fmt.Fprintf(w, "    descriptor: %v\n    flags: %v\n", f.Descriptor, flagNames(f.Flags, memberFlags))
+		c.disassembleAttributes(w, f.Attributes, "    ")
+		fmt.Fprintln(w)
+	}
+	for _, m := range c.methods {
+		c.disassembleMethod(w, m)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "}")
+	c.disassembleAttributes(w, c.attributes, "")
+	c.disassembleBootstrapMethods(w)
+}
+
+func (c *Class) disassembleMethod(w io.Writer, m Method) {
+	md, err := ParseMethodDescriptor(m.Descriptor)
+	if err != nil {
+		fmt.Fprintf(w, "  %v%v%v;\n", modifiers(m.Flags), m.Name, m.Descriptor)
+	} else {
+		args := make([]string, 0, len(md.Args))
+		for _, arg := range md.Args {
+			args = append(args, javaTypeName(arg))
+		}
+		fmt.Fprintf(w, "  %v%v %v(%v);\n", modifiers(m.Flags), javaTypeName(md.ReturnType), m.Name, strings.Join(args, ", "))
+	}
+	fmt.Fprintf(w, "    descriptor: %v\n    flags: %v\n", m.Descriptor, flagNames(m.Flags, memberFlags))
+	if m.Code != nil {
+		fmt.Fprintln(w, "    Code:")
+		fmt.Fprintf(w, "      stack=%v, locals=%v\n", m.Code.MaxStack, m.Code.MaxLocals)
+		insts, err := DecodeInstructions(m.Code.Code)
+		if err != nil {
+			fmt.Fprintf(w, "      %v\n", err)
+		}
+		for _, inst := range insts {
+			fmt.Fprintf(w, "%10v: %v\n", inst.Offset, c.disassembleInstruction(inst))
+		}
+		if len(m.Code.ExceptionTable) > 0 {
+			fmt.Fprintln(w, "      Exception table:\n         from    to  target type")
+			for _, handler := range m.Code.ExceptionTable {
+				catchType := "any"
+				if handler.CatchType != 0 {
+					catchType = "Class " + c.constPool.describe(handler.CatchType)
+				}
+				fmt.Fprintf(w, "%13v%6v%8v   %v\n", handler.StartPC, handler.EndPC, handler.HandlerPC, catchType)
+			}
+		}
+		c.disassembleAttributes(w, m.Code.Attributes, "      ")
+	}
+	c.disassembleAttributes(w, m.Attributes, "    ")
+}
+
+func (c *Class) disassembleInstruction(inst Instruction) string {
+	op := inst.Opcode
+	name := Mnemonic(op)
+	if inst.Wide {
+		name = "wide " + name
+	}
+	ref := func(index uint16, extra string) string {
+		return fmt.Sprintf("%-24v// %v", fmt.Sprintf("%-14v#%v%v", name, index, extra), c.constPool.describe(index))
+	}
+	switch instructions.Opcodes[op].Operands {
+	case instructions.BYTE:
+		return fmt.Sprintf("%-14v%v", name, int8(inst.Operands[0]))
+	case instructions.SHORT:
+		return fmt.Sprintf("%-14v%v", name, int16(inst.U16()))
+	case instructions.CONSTANT_U1:
+		return ref(uint16(inst.U8()), "")
+	case instructions.CONSTANT, instructions.DYNAMIC_CALL:
+		return ref(inst.U16(), "")
+	case instructions.INTERFACE_CALL, instructions.MULTI_ARRAY:
+		return ref(inst.U16(), fmt.Sprintf(", %v", inst.Operands[2]))
+	case instructions.LOCAL_CONSTANT:
+		increment := int(int8(inst.Operands[1]))
+		if inst.Wide {
+			increment = int(int16(binary.BigEndian.Uint16(inst.Operands[2:])))
+		}
+		return fmt.Sprintf("%-14v%v, %v", name, inst.LocalIndex(), increment)
+	case instructions.LOCAL:
+		return fmt.Sprintf("%-14v%v", name, inst.LocalIndex())
+	case instructions.ARRAY_TYPE:
+		return fmt.Sprintf("%-14v%v", name, arrayTypeNames[inst.U8()])
+	case instructions.SWITCH:
+		return disassembleSwitch(name, inst)
+	case instructions.BRANCH, instructions.BRANCH_W:
+		return fmt.Sprintf("%-14v%v", name, inst.Targets[0])
+	}
+	return name
+}
+
+func disassembleSwitch(name string, inst Instruction) string {
+	padding := (4 - (inst.Offset+1)%4) % 4
+	data := inst.Operands[padding:]
+	lines := []string{name + " {"}
+	if inst.Opcode == instructions.TABLESWITCH {
+		low := int32(binary.BigEndian.Uint32(data[4:]))
+		for i := 1; i < len(inst.Targets); i++ {
+			lines = append(lines, fmt.Sprintf("%24v: %v", low+int32(i-1), inst.Targets[i]))
+		}
+	} else {
+		for i := 1; i < len(inst.Targets); i++ {
+			key := int32(binary.BigEndian.Uint32(data[8*i:]))
+			lines = append(lines, fmt.Sprintf("%24v: %v", key, inst.Targets[i]))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%24v: %v", "default", inst.Targets[0]), strings.Repeat(" ", 12)+"}")
+	return strings.Join(lines, "\n")
+}
+
+func (c *Class) disassembleAttributes(w io.Writer, attributes []Attribute, indent string) {
+	for _, a := range attributes {
+		switch a.Name {
+		case "StackMapTable":
+			frames, err := c.describeStackMapTable(a.Data)
+			fmt.Fprintf(w, "%vStackMapTable: number_of_entries = %v\n", indent, len(frames))
+			for _, frame := range frames {
+				fmt.Fprintf(w, "%v  %v\n", indent, frame)
+			}
+			if err != nil {
+				fmt.Fprintf(w, "%v  %v\n", indent, err)
+			}
+		default:
+			fmt.Fprintf(w, "%v%v: length = 0x%x\n", indent, a.Name, len(a.Data))
+		}
+	}
+}
+
+// describeStackMapTable decodes the frames of a StackMapTable attribute into readable lines
+func (c *Class) describeStackMapTable(data []byte) ([]string, error) {
+	r := &classReader{data: data}
+	count := int(r.u2())
+	frames := make([]string, 0, count)
+	types := func(n int) string {
+		names := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			vt := VerificationType{Tag: r.u1()}
+			if vt.Tag == ITEM_OBJECT {
+				vt.Class = c.constPool.describe(r.u2())
+			} else if vt.Tag == ITEM_UNINITIALIZED {
+				vt.Offset = r.u2()
+			}
+			names = append(names, vt.String())
+		}
+		if len(names) == 0 {
+			return "[]"
+		}
+		return "[ " + strings.Join(names, ", ") + " ]"
+	}
+	for i := 0; i < count && r.err == nil; i++ {
+		frameType := r.u1()
+		var frame string
+		switch {
+		case frameType <= SAME_FRAME_MAX:
+			frame = fmt.Sprintf("frame_type = %v /* same */", frameType)
+		case frameType <= SAME_LOCALS_1_STACK_MAX:
+			frame = fmt.Sprintf("frame_type = %v /* same_locals_1_stack_item */ stack = %v", frameType, types(1))
+		case frameType < SAME_LOCALS_1_STACK_EXT:
+			return frames, fmt.Errorf("error: reserved stack map frame type %v", frameType)
+		case frameType == SAME_LOCALS_1_STACK_EXT:
+			frame = fmt.Sprintf("frame_type = %v /* same_locals_1_stack_item_frame_extended */ offset_delta = %v", frameType, r.u2())
+			frame += " stack = " + types(1)
+		case frameType < CHOP_FRAME:
+			frame = fmt.Sprintf("frame_type = %v /* chop */ offset_delta = %v", frameType, r.u2())
+		case frameType == SAME_FRAME_EXTENDED:
+			frame = fmt.Sprintf("frame_type = %v /* same_frame_extended */ offset_delta = %v", frameType, r.u2())
+		case frameType < FULL_FRAME:
+			frame = fmt.Sprintf("frame_type = %v /* append */ offset_delta = %v", frameType, r.u2())
+			frame += " locals = " + types(int(frameType-APPEND_FRAME))
+		default:
+			frame = fmt.Sprintf("frame_type = %v /* full_frame */ offset_delta = %v", frameType, r.u2())
+			frame += " locals = " + types(int(r.u2()))
+			frame += " stack = " + types(int(r.u2()))
+		}
+		frames = append(frames, frame)
+	}
+	return frames, r.err
+}
+
+// describe returns the comment javap prints next to a constant pool reference
+func (cp *ConstPool) describe(index uint16) string {
+	co, err := cp.get(index)
+	if err != nil {
+		return fmt.Sprintf("<invalid #%v>", index)
+	}
+	switch co.Tag {
+	case CONSTANT_UTF8:
+		return co.String
+	case CONSTANT_CLASS, CONSTANT_MODULE, CONSTANT_PACKAGE:
+		return cp.describe(co.NameIndex)
+	case CONSTANT_STRING:
+		return strconv.Quote(cp.describe(co.StringIndex))
+	case CONSTANT_FIELDREF, CONSTANT_METHODREF, CONSTANT_INTERFACEMETHODREF:
+		return cp.describe(co.ClassIndex) + "." + cp.describe(co.NameAndTypeIndex)
+	case CONSTANT_NAMEANDTYPE:
+		return cp.describe(co.NameIndex) + ":" + cp.describe(co.DescIndex)
+	case CONSTANT_METHODHANDLE:
+		return fmt.Sprintf("REF_%v %v", co.ReferenceKind, cp.describe(co.ReferenceIndex))
+	case CONSTANT_METHODTYPE:
+		return cp.describe(co.DescIndex)
+	case CONSTANT_DYNAMIC, CONSTANT_INVOKEDYNAMIC:
+		return fmt.Sprintf("#%v:%v", co.BootstrapMethodAttrIndex, cp.describe(co.NameAndTypeIndex))
+	}
+	return cp.describeValue(co)
+}
+
+// describeEntry returns the operands and comment of a line in the constant pool listing
+func (cp *ConstPool) describeEntry(co Const) string {
+	comment := func(operands string, index uint16) string {
+		return fmt.Sprintf("%-14v// %v", operands, cp.describe(index))
+	}
+	switch co.Tag {
+	case CONSTANT_UTF8:
+		return co.String
+	case CONSTANT_CLASS, CONSTANT_MODULE, CONSTANT_PACKAGE:
+		return comment(fmt.Sprintf("#%v", co.NameIndex), co.NameIndex)
+	case CONSTANT_STRING:
+		return comment(fmt.Sprintf("#%v", co.StringIndex), co.StringIndex)
+	case CONSTANT_FIELDREF, CONSTANT_METHODREF, CONSTANT_INTERFACEMETHODREF:
+		return fmt.Sprintf("%-14v// %v", fmt.Sprintf("#%v.#%v", co.ClassIndex, co.NameAndTypeIndex),
+			cp.describe(co.ClassIndex)+"."+cp.describe(co.NameAndTypeIndex))
+	case CONSTANT_NAMEANDTYPE:
+		return fmt.Sprintf("%-14v// %v", fmt.Sprintf("#%v:#%v", co.NameIndex, co.DescIndex),
+			cp.describe(co.NameIndex)+":"+cp.describe(co.DescIndex))
+	case CONSTANT_METHODHANDLE:
+		return fmt.Sprintf("%-14v// %v", fmt.Sprintf("%v:#%v", co.ReferenceKind, co.ReferenceIndex), cp.describe(co.ReferenceIndex))
+	case CONSTANT_METHODTYPE:
+		return comment(fmt.Sprintf("#%v", co.DescIndex), co.DescIndex)
+	case CONSTANT_DYNAMIC, CONSTANT_INVOKEDYNAMIC:
+		return fmt.Sprintf("%-14v// %v", fmt.Sprintf("#%v:#%v", co.BootstrapMethodAttrIndex, co.NameAndTypeIndex),
+			cp.describe(co.NameAndTypeIndex))
+	}
+	return cp.describeValue(co)
+}
+
+func (cp *ConstPool) describeValue(co Const) string {
+	switch co.Tag {
+	case CONSTANT_INTEGER:
+		return strconv.Itoa(int(co.Integer))
+	case CONSTANT_FLOAT:
+		return strconv.FormatFloat(float64(co.Float), 'g', -1, 32) + "f"
+	case CONSTANT_LONG:
+		return strconv.FormatInt(co.Long, 10) + "l"
+	case CONSTANT_DOUBLE:
+		return strconv.FormatFloat(co.Double, 'g', -1, 64) + "d"
+	}
+	return ""
+}
@@ -0,0 +1,569 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"fmt"
+	"strings"
+)
+
+const (
+	ITEM_TOP               = 0
+	ITEM_INTEGER           = 1
+	ITEM_FLOAT             = 2
+	ITEM_DOUBLE            = 3
+	ITEM_LONG              = 4
+	ITEM_NULL              = 5
+	ITEM_UNINITIALIZEDTHIS = 6
+	ITEM_OBJECT            = 7
+	ITEM_UNINITIALIZED     = 8
+)
+
+type VerificationType struct {
+	Tag    byte
+	Class  string
+	Offset uint16
+}
+
+var (
+	topType     VerificationType = VerificationType{Tag: ITEM_TOP}
+	intType     VerificationType = VerificationType{Tag: ITEM_INTEGER}
+	floatType   VerificationType = VerificationType{Tag: ITEM_FLOAT}
+	longType    VerificationType = VerificationType{Tag: ITEM_LONG}
+	doubleType  VerificationType = VerificationType{Tag: ITEM_DOUBLE}
+	nullType    VerificationType = VerificationType{Tag: ITEM_NULL}
+	objectType  VerificationType = VerificationType{Tag: ITEM_OBJECT, Class: "java/lang/Object"}
+	throwable   VerificationType = VerificationType{Tag: ITEM_OBJECT, Class: "java/lang/Throwable"}
+	stringType  VerificationType = VerificationType{Tag: ITEM_OBJECT, Class: "java/lang/String"}
+	anyRefType  VerificationType = VerificationType{Tag: ITEM_NULL, Class: "reference"}
+	arrayTypes  []string         = []string{4: "[Z", 5: "[C", 6: "[F", 7: "[D", 8: "[B", 9: "[S", 10: "[I", 11: "[J"}
+	typeByIndex []VerificationType
+)
+
+func init() {
+	typeByIndex = []VerificationType{intType, longType, floatType, doubleType, anyRefType}
+}
+
+func objectOf(class string) VerificationType {
+	return VerificationType{Tag: ITEM_OBJECT, Class: class}
+}
+
+func (vt VerificationType) IsWide() bool {
+	return vt.Tag == ITEM_LONG || vt.Tag == ITEM_DOUBLE
+}
+
+func (vt VerificationType) IsReference() bool {
+	return vt.Tag == ITEM_NULL || vt.Tag == ITEM_OBJECT || vt.Tag == ITEM_UNINITIALIZED || vt.Tag == ITEM_UNINITIALIZEDTHIS
+}
+
+func (vt VerificationType) String() string {
+	switch vt.Tag {
+	case ITEM_TOP:
+		return "top"
+	case ITEM_INTEGER:
+		return "int"
+	case ITEM_FLOAT:
+		return "float"
+	case ITEM_DOUBLE:
+		return "double"
+	case ITEM_LONG:
+		return "long"
+	case ITEM_NULL:
+		if vt.Class != "" {
+			return vt.Class
+		}
+		return "null"
+	case ITEM_UNINITIALIZEDTHIS:
+		return "uninitializedThis"
+	case ITEM_OBJECT:
+		return vt.Class
+	}
+	return fmt.Sprintf("uninitialized(%v)", vt.Offset)
+}
+
+// typeOfDescriptor maps a field descriptor to the type the verifier sees on the stack
+func typeOfDescriptor(descriptor string) VerificationType {
+	switch descriptor[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return intType
+	case 'F':
+		return floatType
+	case 'J':
+		return longType
+	case 'D':
+		return doubleType
+	case 'L':
+		return objectOf(descriptor[1 : len(descriptor)-1])
+	}
+	return objectOf(descriptor)
+}
+
+// isAssignable reports whether a value of type from can be used where type to is expected
+func isAssignable(from, to VerificationType) bool {
+	if to == anyRefType {
+		return from.IsReference()
+	}
+	if from == to {
+		return true
+	}
+	if to.Tag == ITEM_TOP {
+		return true
+	}
+	if to.Tag == ITEM_OBJECT && from.Tag == ITEM_NULL {
+		return true
+	}
+	if to.Tag == ITEM_OBJECT && from.Tag == ITEM_OBJECT {
+		// without loading the class hierarchy only array types can be told apart
+		if strings.HasPrefix(to.Class, "[") && strings.HasPrefix(from.Class, "[") {
+			return from.Class == to.Class
+		}
+		return true
+	}
+	return false
+}
+
+// mergeTypes returns the type of a local or stack slot at a point where two paths join
+func mergeTypes(a, b VerificationType) VerificationType {
+	if a == b {
+		return a
+	}
+	if a.Tag == ITEM_NULL && b.Tag == ITEM_OBJECT {
+		return b
+	}
+	if b.Tag == ITEM_NULL && a.Tag == ITEM_OBJECT {
+		return a
+	}
+	if a.Tag == ITEM_OBJECT && b.Tag == ITEM_OBJECT {
+		return objectType
+	}
+	return topType
+}
+
+type Frame struct {
+	Locals []VerificationType
+	Stack  []VerificationType
+}
+
+func (f Frame) copy() Frame {
+	return Frame{Locals: append([]VerificationType{}, f.Locals...), Stack: append([]VerificationType{}, f.Stack...)}
+}
+
+// merge joins another frame into this one and reports whether anything changed
+func (f *Frame) merge(other Frame) (bool, error) {
+	if len(f.Stack) != len(other.Stack) {
+		return false, fmt.Errorf("inconsistent stack height (%v != %v)", len(f.Stack), len(other.Stack))
+	}
+	changed := false
+	for i := range f.Stack {
+		merged := mergeTypes(f.Stack[i], other.Stack[i])
+		if merged.Tag == ITEM_TOP {
+			return false, fmt.Errorf("incompatible stack types %v and %v", f.Stack[i], other.Stack[i])
+		}
+		if merged != f.Stack[i] {
+			f.Stack[i] = merged
+			changed = true
+		}
+	}
+	for i := range f.Locals {
+		local := topType
+		if i < len(other.Locals) {
+			local = other.Locals[i]
+		}
+		merged := mergeTypes(f.Locals[i], local)
+		if merged != f.Locals[i] {
+			f.Locals[i] = merged
+			changed = true
+		}
+	}
+	for i := range f.Locals {
+		// the second half of a long or double is only valid as long as the first one is
+		if f.Locals[i].IsWide() && (i+1 >= len(f.Locals) || f.Locals[i+1].Tag != ITEM_TOP) {
+			f.Locals[i] = topType
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func (f *Frame) push(vt VerificationType) {
+	f.Stack = append(f.Stack, vt)
+}
+
+func (f *Frame) pop(expected VerificationType) (VerificationType, error) {
+	if len(f.Stack) == 0 {
+		return topType, fmt.Errorf("stack underflow")
+	}
+	vt := f.Stack[len(f.Stack)-1]
+	f.Stack = f.Stack[:len(f.Stack)-1]
+	if !isAssignable(vt, expected) {
+		return vt, fmt.Errorf("expected %v on the stack but found %v", expected, vt)
+	}
+	return vt, nil
+}
+
+func (f *Frame) load(index int, expected VerificationType) (VerificationType, error) {
+	if index >= len(f.Locals) {
+		return topType, fmt.Errorf("local variable index %v out of range", index)
+	}
+	vt := f.Locals[index]
+	if vt.Tag == ITEM_TOP || !isAssignable(vt, expected) {
+		return vt, fmt.Errorf("expected %v in local %v but found %v", expected, index, vt)
+	}
+	return vt, nil
+}
+
+func (f *Frame) store(index int, vt VerificationType) error {
+	size := 1
+	if vt.IsWide() {
+		size = 2
+	}
+	if index+size > len(f.Locals) {
+		return fmt.Errorf("local variable index %v out of range", index)
+	}
+	if index > 0 && f.Locals[index-1].IsWide() {
+		f.Locals[index-1] = topType
+	}
+	f.Locals[index] = vt
+	if size == 2 {
+		f.Locals[index+1] = topType
+	}
+	return nil
+}
+
+// entries collapses the slot based locals into the list of entries used by stack map frames
+func entries(locals []VerificationType) []VerificationType {
+	result := make([]VerificationType, 0)
+	for i := 0; i < len(locals); i++ {
+		result = append(result, locals[i])
+		if locals[i].IsWide() {
+			i++
+		}
+	}
+	for len(result) > 0 && result[len(result)-1].Tag == ITEM_TOP {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+func popMany(f *Frame, types ...VerificationType) error {
+	for i := len(types) - 1; i >= 0; i-- {
+		if _, err := f.pop(types[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execute applies the effect of a single instruction to the frame
+func (c *Class) execute(inst Instruction, f *Frame) error {
+	op := inst.Opcode
+	switch {
+	case op == instructions.NOP, op == instructions.GOTO, op == instructions.GOTO_W:
+	case op == instructions.ACONST_NULL:
+		f.push(nullType)
+	case op >= instructions.ICONST_M1 && op <= instructions.ICONST_5, op == instructions.BIPUSH, op == instructions.SIPUSH:
+		f.push(intType)
+	case op == instructions.LCONST_0 || op == instructions.LCONST_1:
+		f.push(longType)
+	case op >= instructions.FCONST_0 && op <= instructions.FCONST_2:
+		f.push(floatType)
+	case op == instructions.DCONST_0 || op == instructions.DCONST_1:
+		f.push(doubleType)
+	case op == instructions.LDC, op == instructions.LDC_W, op == instructions.LDC2_W:
+		index := uint16(inst.U8())
+		if op != instructions.LDC {
+			index = inst.U16()
+		}
+		vt, err := c.constPool.loadableType(index, op == instructions.LDC2_W)
+		if err != nil {
+			return err
+		}
+		f.push(vt)
+	case op >= instructions.ILOAD && op <= instructions.ALOAD:
+		vt, err := f.load(inst.LocalIndex(), typeByIndex[op-instructions.ILOAD])
+		if err != nil {
+			return err
+		}
+		f.push(vt)
+	case op >= instructions.ILOAD_0 && op <= instructions.ALOAD_3:
+		vt, err := f.load(int(op-instructions.ILOAD_0)%4, typeByIndex[(op-instructions.ILOAD_0)/4])
+		if err != nil {
+			return err
+		}
+		f.push(vt)
+	case op >= instructions.IALOAD && op <= instructions.SALOAD:
+		if _, err := f.pop(intType); err != nil {
+			return err
+		}
+		array, err := f.pop(anyRefType)
+		if err != nil {
+			return err
+		}
+		if op == instructions.AALOAD {
+			if array.Tag == ITEM_OBJECT && strings.HasPrefix(array.Class, "[") {
+				f.push(typeOfDescriptor(array.Class[1:]))
+			} else {
+				f.push(nullType)
+			}
+		} else {
+			f.push([]VerificationType{intType, longType, floatType, doubleType, topType, intType, intType, intType}[op-instructions.IALOAD])
+		}
+	case op >= instructions.ISTORE && op <= instructions.ASTORE:
+		vt, err := f.pop(typeByIndex[op-instructions.ISTORE])
+		if err != nil {
+			return err
+		}
+		return f.store(inst.LocalIndex(), vt)
+	case op >= instructions.ISTORE_0 && op <= instructions.ASTORE_3:
+		vt, err := f.pop(typeByIndex[(op-instructions.ISTORE_0)/4])
+		if err != nil {
+			return err
+		}
+		return f.store(int(op-instructions.ISTORE_0)%4, vt)
+	case op >= instructions.IASTORE && op <= instructions.SASTORE:
+		value := []VerificationType{intType, longType, floatType, doubleType, anyRefType, intType, intType, intType}[op-instructions.IASTORE]
+		return popMany(f, anyRefType, intType, value)
+	case op >= instructions.POP && op <= instructions.SWAP:
+		return executeStackOp(op, f)
+	case op >= instructions.IADD && op <= instructions.DNEG:
+		vt := typeByIndex[(op-instructions.IADD)%4]
+		if op < instructions.INEG {
+			if _, err := f.pop(vt); err != nil {
+				return err
+			}
+		}
+		if _, err := f.pop(vt); err != nil {
+			return err
+		}
+		f.push(vt)
+	case op >= instructions.ISHL && op <= instructions.LXOR:
+		vt := typeByIndex[(op-instructions.ISHL)%2]
+		other := vt
+		if op <= instructions.LUSHR {
+			other = intType
+		}
+		if err := popMany(f, vt, other); err != nil {
+			return err
+		}
+		f.push(vt)
+	case op == instructions.IINC:
+		_, err := f.load(inst.LocalIndex(), intType)
+		return err
+	case op >= instructions.I2L && op <= instructions.I2S:
+		from := []VerificationType{intType, intType, intType, longType, longType, longType, floatType, floatType, floatType, doubleType, doubleType, doubleType, intType, intType, intType}[op-instructions.I2L]
+		to := []VerificationType{longType, floatType, doubleType, intType, floatType, doubleType, intType, longType, doubleType, intType, longType, floatType, intType, intType, intType}[op-instructions.I2L]
+		if _, err := f.pop(from); err != nil {
+			return err
+		}
+		f.push(to)
+	case op >= instructions.LCMP && op <= instructions.DCMPG:
+		vt := []VerificationType{longType, floatType, floatType, doubleType, doubleType}[op-instructions.LCMP]
+		if err := popMany(f, vt, vt); err != nil {
+			return err
+		}
+		f.push(intType)
+	case op >= instructions.IFEQ && op <= instructions.IFLE, op == instructions.TABLESWITCH, op == instructions.LOOKUPSWITCH:
+		_, err := f.pop(intType)
+		return err
+	case op >= instructions.IF_ICMPEQ && op <= instructions.IF_ICMPLE:
+		return popMany(f, intType, intType)
+	case op == instructions.IF_ACMPEQ || op == instructions.IF_ACMPNE:
+		return popMany(f, anyRefType, anyRefType)
+	case op == instructions.IFNULL, op == instructions.IFNONNULL, op == instructions.ATHROW, op == instructions.MONITORENTER, op == instructions.MONITOREXIT:
+		_, err := f.pop(anyRefType)
+		return err
+	case op >= instructions.IRETURN && op <= instructions.ARETURN:
+		_, err := f.pop(typeByIndex[op-instructions.IRETURN])
+		return err
+	case op == instructions.RETURN:
+	case op >= instructions.GETSTATIC && op <= instructions.PUTFIELD:
+		descriptor, err := c.constPool.memberDescriptor(inst.U16())
+		if err != nil {
+			return err
+		}
+		vt := typeOfDescriptor(descriptor)
+		if op == instructions.PUTSTATIC || op == instructions.PUTFIELD {
+			if _, err := f.pop(vt); err != nil {
+				return err
+			}
+		}
+		if op == instructions.GETFIELD || op == instructions.PUTFIELD {
+			if _, err := f.pop(anyRefType); err != nil {
+				return err
+			}
+		}
+		if op == instructions.GETSTATIC || op == instructions.GETFIELD {
+			f.push(vt)
+		}
+	case op >= instructions.INVOKEVIRTUAL && op <= instructions.INVOKEDYNAMIC:
+		return c.executeInvoke(inst, f)
+	case op == instructions.NEW:
+		f.push(VerificationType{Tag: ITEM_UNINITIALIZED, Offset: uint16(inst.Offset)})
+	case op == instructions.NEWARRAY:
+		if _, err := f.pop(intType); err != nil {
+			return err
+		}
+		if int(inst.U8()) >= len(arrayTypes) || arrayTypes[inst.U8()] == "" {
+			return fmt.Errorf("invalid array type %v", inst.U8())
+		}
+		f.push(objectOf(arrayTypes[inst.U8()]))
+	case op == instructions.ANEWARRAY:
+		if _, err := f.pop(intType); err != nil {
+			return err
+		}
+		class, err := c.constPool.className(inst.U16())
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(class, "[") {
+			f.push(objectOf("[" + class))
+		} else {
+			f.push(objectOf("[L" + class + ";"))
+		}
+	case op == instructions.ARRAYLENGTH:
+		if _, err := f.pop(anyRefType); err != nil {
+			return err
+		}
+		f.push(intType)
+	case op == instructions.CHECKCAST || op == instructions.INSTANCEOF:
+		if _, err := f.pop(anyRefType); err != nil {
+			return err
+		}
+		if op == instructions.INSTANCEOF {
+			f.push(intType)
+			return nil
+		}
+		class, err := c.constPool.className(inst.U16())
+		if err != nil {
+			return err
+		}
+		f.push(objectOf(class))
+	case op == instructions.MULTIANEWARRAY:
+		for i := 0; i < int(inst.Operands[2]); i++ {
+			if _, err := f.pop(intType); err != nil {
+				return err
+			}
+		}
+		class, err := c.constPool.className(inst.U16())
+		if err != nil {
+			return err
+		}
+		f.push(objectOf(class))
+	default:
+		return fmt.Errorf("unsupported opcode 0x%x", op)
+	}
+	return nil
+}
+
+func (c *Class) executeInvoke(inst Instruction, f *Frame) error {
+	descriptor, err := c.constPool.memberDescriptor(inst.U16())
+	if err != nil {
+		return err
+	}
+	md, err := ParseMethodDescriptor(descriptor)
+	if err != nil {
+		return err
+	}
+	for i := len(md.Args) - 1; i >= 0; i-- {
+		if _, err := f.pop(typeOfDescriptor(md.Args[i])); err != nil {
+			return err
+		}
+	}
+	if inst.Opcode != instructions.INVOKESTATIC && inst.Opcode != instructions.INVOKEDYNAMIC {
+		receiver, err := f.pop(anyRefType)
+		if err != nil {
+			return err
+		}
+		name, err := c.constPool.memberName(inst.U16())
+		if err != nil {
+			return err
+		}
+		if inst.Opcode == instructions.INVOKESPECIAL && name == "<init>" {
+			if receiver.Tag != ITEM_UNINITIALIZED && receiver.Tag != ITEM_UNINITIALIZEDTHIS {
+				return fmt.Errorf("<init> called on already initialized %v", receiver)
+			}
+			initialized := objectOf(c.name)
+			if receiver.Tag == ITEM_UNINITIALIZED {
+				class, err := c.constPool.memberClassName(inst.U16())
+				if err != nil {
+					return err
+				}
+				initialized = objectOf(class)
+			}
+			replaceType(f.Locals, receiver, initialized)
+			replaceType(f.Stack, receiver, initialized)
+		} else if receiver.Tag == ITEM_UNINITIALIZED || receiver.Tag == ITEM_UNINITIALIZEDTHIS {
+			return fmt.Errorf("method %v called on uninitialized object", name)
+		}
+	}
+	if md.ReturnType != "V" {
+		f.push(typeOfDescriptor(md.ReturnType))
+	}
+	return nil
+}
+
+func replaceType(types []VerificationType, old, new VerificationType) {
+	for i := range types {
+		if types[i] == old {
+			types[i] = new
+		}
+	}
+}
+
+func executeStackOp(op byte, f *Frame) error {
+	n := len(f.Stack)
+	category := func(depth int) int {
+		if n-depth < 0 {
+			return 0
+		}
+		if f.Stack[n-depth].IsWide() {
+			return 2
+		}
+		return 1
+	}
+	// the number of entries (not slots) each form works on for the category of the top values
+	var count, insertDepth int
+	switch op {
+	case instructions.POP:
+		count = 1
+	case instructions.POP2:
+		count = 2
+		if category(1) == 2 {
+			count = 1
+		}
+	case instructions.DUP, instructions.DUP_X1, instructions.DUP_X2:
+		count = 1
+		insertDepth = int(op-instructions.DUP) + 1
+		if op == instructions.DUP_X2 && category(2) == 2 {
+			insertDepth = 2
+		}
+	case instructions.DUP2, instructions.DUP2_X1, instructions.DUP2_X2:
+		count = 2
+		if category(1) == 2 {
+			count = 1
+		}
+		insertDepth = count + int(op-instructions.DUP2)
+		if op == instructions.DUP2_X2 && category(count+1) == 2 {
+			insertDepth = count + 1
+		}
+	case instructions.SWAP:
+		if n < 2 || category(1) != 1 || category(2) != 1 {
+			return fmt.Errorf("swap requires two single slot values")
+		}
+		f.Stack[n-1], f.Stack[n-2] = f.Stack[n-2], f.Stack[n-1]
+		return nil
+	}
+	if n < count || n < insertDepth {
+		return fmt.Errorf("stack underflow")
+	}
+	if op == instructions.POP || op == instructions.POP2 {
+		f.Stack = f.Stack[:n-count]
+		return nil
+	}
+	top := append([]VerificationType{}, f.Stack[n-count:]...)
+	position := n - insertDepth
+	stack := append([]VerificationType{}, f.Stack[:position]...)
+	stack = append(stack, top...)
+	stack = append(stack, f.Stack[position:]...)
+	f.Stack = stack
+	return nil
+}
@@ -0,0 +1,56 @@
+package classfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		current Frame
+		other   Frame
+		merged  Frame
+		changed bool
+	}{
+		{"same", Frame{Locals: []VerificationType{intType}}, Frame{Locals: []VerificationType{intType}},
+			Frame{Locals: []VerificationType{intType}}, false},
+		{"different locals", Frame{Locals: []VerificationType{intType}}, Frame{Locals: []VerificationType{floatType}},
+			Frame{Locals: []VerificationType{topType}}, true},
+		{"missing locals", Frame{Locals: []VerificationType{intType, intType}}, Frame{Locals: []VerificationType{intType}},
+			Frame{Locals: []VerificationType{intType, topType}}, true},
+		{"null and object", Frame{Stack: []VerificationType{nullType}}, Frame{Stack: []VerificationType{stringType}},
+			Frame{Stack: []VerificationType{stringType}}, true},
+		{"long kept", Frame{Locals: []VerificationType{longType, topType}}, Frame{Locals: []VerificationType{longType, topType}},
+			Frame{Locals: []VerificationType{longType, topType}}, false},
+		// the second half of the long is overwritten on both paths so only the demotion changes the frame
+		{"long demoted", Frame{Locals: []VerificationType{longType, intType}}, Frame{Locals: []VerificationType{longType, intType}},
+			Frame{Locals: []VerificationType{topType, intType}}, true},
+		{"double without second half", Frame{Locals: []VerificationType{intType, doubleType}}, Frame{Locals: []VerificationType{intType, doubleType}},
+			Frame{Locals: []VerificationType{intType, topType}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed, err := test.current.merge(test.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != test.changed {
+				t.Errorf("expected changed to be %v", test.changed)
+			}
+			if !reflect.DeepEqual(test.current, test.merged) {
+				t.Errorf("expected %v but got %v", test.merged, test.current)
+			}
+		})
+	}
+}
+
+func TestFrameMergeErrors(t *testing.T) {
+	current := Frame{Stack: []VerificationType{intType}}
+	if _, err := current.merge(Frame{}); err == nil {
+		t.Error("expected an error for different stack heights")
+	}
+	if _, err := current.merge(Frame{Stack: []VerificationType{floatType}}); err == nil {
+		t.Error("expected an error for incompatible stack types")
+	}
+}
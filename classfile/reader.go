@@ -0,0 +1,259 @@
+package classfile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+type classReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *classReader) read(n int) []byte {
+	// after an error only enough zeros for the fixed size reads are returned
+	if r.err != nil {
+		return make([]byte, min(n, 8))
+	}
+	if n < 0 || r.offset+n > len(r.data) {
+		r.err = fmt.Errorf("error: unexpected end of class file at offset %v", r.offset)
+		return make([]byte, min(max(n, 0), 8))
+	}
+	bytes := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return bytes
+}
+
+func (r *classReader) u1() byte {
+	return r.read(1)[0]
+}
+
+func (r *classReader) u2() uint16 {
+	return binary.BigEndian.Uint16(r.read(2))
+}
+
+func (r *classReader) u4() uint32 {
+	return binary.BigEndian.Uint32(r.read(4))
+}
+
+func (r *classReader) u8() uint64 {
+	return binary.BigEndian.Uint64(r.read(8))
+}
+
+// ParseClass reads a class file back into the class model
+func ParseClass(data []byte) (*Class, error) {
+	r := &classReader{data: data}
+	if magic := r.u4(); r.err != nil {
+		return nil, r.err
+	} else if magic != MAGIC {
+		return nil, fmt.Errorf("error: not a class file (wrong magic number)")
+	}
+	minor, major := r.u2(), r.u2()
+	class := NewClass("", "", Target{Release: int(major) - JAVA8_MAJOR + 8, Major: major, Minor: minor})
+	if err := r.readConstPool(class.constPool); err != nil {
+		return nil, err
+	}
+	var err error
+	class.flags = r.u2()
+	if class.name, err = class.constPool.className(r.u2()); err != nil {
+		return nil, err
+	}
+	// only java/lang/Object has no super class
+	if superIndex := r.u2(); superIndex != 0 {
+		if class.super, err = class.constPool.className(superIndex); err != nil {
+			return nil, err
+		}
+	}
+	interfaceCount := int(r.u2())
+	for i := 0; i < interfaceCount; i++ {
+		name, err := class.constPool.className(r.u2())
+		if err != nil {
+			return nil, err
+		}
+		class.interfaces = append(class.interfaces, name)
+	}
+	fieldCount := int(r.u2())
+	for i := 0; i < fieldCount; i++ {
+		field := Field{Flags: r.u2()}
+		if field.Name, err = class.constPool.utf8(r.u2()); err != nil {
+			return nil, err
+		}
+		if field.Descriptor, err = class.constPool.utf8(r.u2()); err != nil {
+			return nil, err
+		}
+		if field.Attributes, err = r.readAttributes(class.constPool); err != nil {
+			return nil, err
+		}
+		class.fields = append(class.fields, field)
+	}
+	methodCount := int(r.u2())
+	for i := 0; i < methodCount; i++ {
+		method, err := r.readMethod(class.constPool)
+		if err != nil {
+			return nil, err
+		}
+		class.methods = append(class.methods, method)
+	}
+	attributes, err := r.readAttributes(class.constPool)
+	if err != nil {
+		return nil, err
+	}
+	// the bootstrap methods are kept in their own list, so invokedynamic call sites can be resolved
+	for _, attribute := range attributes {
+		if attribute.Name != BOOTSTRAP_METHODS {
+			class.attributes = append(class.attributes, attribute)
+			continue
+		}
+		if class.bootstrapMethods, err = readBootstrapMethods(attribute.Data); err != nil {
+			return nil, err
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.offset != len(data) {
+		return nil, fmt.Errorf("error: %v trailing bytes after the end of the class file", len(data)-r.offset)
+	}
+	return class, nil
+}
+
+func (r *classReader) readConstPool(cp *ConstPool) error {
+	count := int(r.u2())
+	for index := 1; index < count; index++ {
+		c := Const{Tag: r.u1()}
+		switch c.Tag {
+		case CONSTANT_UTF8:
+			value, err := decodeModifiedUtf8(r.read(int(r.u2())))
+			if err != nil {
+				return err
+			}
+			c.String = value
+		case CONSTANT_INTEGER:
+			c.Integer = int32(r.u4())
+		case CONSTANT_FLOAT:
+			c.Float = math.Float32frombits(r.u4())
+		case CONSTANT_LONG:
+			c.Long = int64(r.u8())
+		case CONSTANT_DOUBLE:
+			c.Double = math.Float64frombits(r.u8())
+		case CONSTANT_CLASS, CONSTANT_MODULE, CONSTANT_PACKAGE:
+			c.NameIndex = r.u2()
+		case CONSTANT_STRING:
+			c.StringIndex = r.u2()
+		case CONSTANT_FIELDREF, CONSTANT_METHODREF, CONSTANT_INTERFACEMETHODREF:
+			c.ClassIndex = r.u2()
+			c.NameAndTypeIndex = r.u2()
+		case CONSTANT_NAMEANDTYPE:
+			c.NameIndex = r.u2()
+			c.DescIndex = r.u2()
+		case CONSTANT_METHODHANDLE:
+			c.ReferenceKind = r.u1()
+			c.ReferenceIndex = r.u2()
+		case CONSTANT_METHODTYPE:
+			c.DescIndex = r.u2()
+		case CONSTANT_DYNAMIC, CONSTANT_INVOKEDYNAMIC:
+			c.BootstrapMethodAttrIndex = r.u2()
+			c.NameAndTypeIndex = r.u2()
+		default:
+			if r.err != nil {
+				return r.err
+			}
+			return fmt.Errorf("error: unknown constant pool tag %v at index %v", c.Tag, index)
+		}
+		// entries are kept at their original index even if the file contains duplicates
+		cp.entries = append(cp.entries, c)
+		if _, ok := cp.indices[keyOf(c)]; !ok {
+			cp.indices[keyOf(c)] = uint16(len(cp.entries))
+		}
+		if c.Tag == CONSTANT_LONG || c.Tag == CONSTANT_DOUBLE {
+			cp.entries = append(cp.entries, Const{Tag: CONSTANT_UNUSABLE})
+			index++
+		}
+	}
+	return r.err
+}
+
+func (r *classReader) readAttributes(cp *ConstPool) ([]Attribute, error) {
+	count := int(r.u2())
+	attributes := make([]Attribute, 0, count)
+	for i := 0; i < count; i++ {
+		name, err := cp.utf8(r.u2())
+		if err != nil {
+			return nil, err
+		}
+		length := int(r.u4())
+		attributes = append(attributes, Attribute{Name: name, Data: r.read(length)})
+	}
+	return attributes, r.err
+}
+
+func (r *classReader) readMethod(cp *ConstPool) (Method, error) {
+	method := Method{Flags: r.u2(), Attributes: make([]Attribute, 0)}
+	var err error
+	if method.Name, err = cp.utf8(r.u2()); err != nil {
+		return method, err
+	}
+	if method.Descriptor, err = cp.utf8(r.u2()); err != nil {
+		return method, err
+	}
+	attributes, err := r.readAttributes(cp)
+	if err != nil {
+		return method, err
+	}
+	for _, attribute := range attributes {
+		if attribute.Name != "Code" {
+			method.Attributes = append(method.Attributes, attribute)
+			continue
+		}
+		if method.Code, err = readCode(attribute.Data, cp); err != nil {
+			return method, fmt.Errorf("error: invalid code of method %v (%v)", method.Name, err)
+		}
+	}
+	return method, nil
+}
+
+func readCode(data []byte, cp *ConstPool) (*Code, error) {
+	r := &classReader{data: data}
+	code := Code{MaxStack: r.u2(), MaxLocals: r.u2()}
+	code.Code = r.read(int(r.u4()))
+	handlerCount := int(r.u2())
+	code.ExceptionTable = make([]ExceptionHandler, 0, handlerCount)
+	for i := 0; i < handlerCount; i++ {
+		code.ExceptionTable = append(code.ExceptionTable, ExceptionHandler{StartPC: r.u2(), EndPC: r.u2(), HandlerPC: r.u2(), CatchType: r.u2()})
+	}
+	attributes, err := r.readAttributes(cp)
+	if err != nil {
+		return nil, err
+	}
+	code.Attributes = attributes
+	if r.offset != len(data) {
+		return nil, fmt.Errorf("error: code attribute has the wrong length")
+	}
+	if _, err := DecodeInstructions(code.Code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func decodeModifiedUtf8(data []byte) (string, error) {
+	chars := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b&0x80 == 0:
+			chars = append(chars, uint16(b))
+		case b&0xe0 == 0xc0 && i+1 < len(data):
+			chars = append(chars, uint16(b&0x1f)<<6|uint16(data[i+1]&0x3f))
+			i++
+		case b&0xf0 == 0xe0 && i+2 < len(data):
+			chars = append(chars, uint16(b&0x0f)<<12|uint16(data[i+1]&0x3f)<<6|uint16(data[i+2]&0x3f))
+			i += 2
+		default:
+			return "", fmt.Errorf("error: invalid modified utf8 string")
+		}
+	}
+	return string(utf16.Decode(chars)), nil
+}
@@ -0,0 +1,156 @@
+package classfile
+
+import (
+	"bytes"
+	"compiler/instructions"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, release := range []int{MIN_RELEASE, MAX_RELEASE} {
+		class := NewClass("RoundTrip", "java/lang/Object", NewTarget(release))
+		assemble(t, class, "main", "([Ljava/lang/String;)V", 3, func(a *instructions.Assembler) {
+			a.Emit(instructions.LDC2_W, int(class.AddLong(1<<40)))
+			a.Emit(instructions.LDC2_W, int(class.AddDouble(-0.5)))
+			a.Emit(instructions.D2L)
+			a.Emit(instructions.LADD)
+			a.Emit(instructions.LSTORE_1)
+			a.Field(instructions.GETSTATIC, class.AddFieldRef("out", "L"+PRINT_STREAM+";", "java/lang/System"), "L"+PRINT_STREAM+";")
+			a.Emit(instructions.LDC, int(class.AddString("café \x00 \U0001F600")))
+			a.Emit(instructions.LLOAD_1)
+			bootstrap := BootstrapMethod{
+				MethodHandle: class.AddMethodHandle(REF_INVOKESTATIC, "java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+					"(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"),
+				Arguments: []uint16{class.AddString("\x01 \x01")},
+			}
+			a.Invoke(instructions.INVOKEDYNAMIC, class.AddInvokeDynamic(bootstrap, "makeConcatWithConstants", "(Ljava/lang/String;J)Ljava/lang/String;"), "(Ljava/lang/String;J)Ljava/lang/String;")
+			a.Invoke(instructions.INVOKEVIRTUAL, class.AddMethodRef("println", "(Ljava/lang/String;)V", PRINT_STREAM), "(Ljava/lang/String;)V")
+			loop := a.NewLabel()
+			a.Emit(instructions.ICONST_3)
+			a.Emit(instructions.ISTORE_0)
+			a.Mark(loop)
+			a.Emit(instructions.IINC, 0, -1)
+			a.Emit(instructions.ILOAD_0)
+			a.Jump(instructions.IFGT, loop)
+			a.Emit(instructions.RETURN)
+		})
+		data, err := class.ConvertToBytes()
+		if err != nil {
+			t.Fatalf("release %v: %v", release, err)
+		}
+		parsed, err := ParseClass(data)
+		if err != nil {
+			t.Fatalf("release %v: %v", release, err)
+		}
+		if converted, err := parsed.ConvertToBytes(); err != nil || !bytes.Equal(converted, data) {
+			t.Errorf("release %v: the parsed class is written as %x instead of %x", release, converted, data)
+		}
+		var original, read bytes.Buffer
+		class.Disassemble(&original)
+		parsed.Disassemble(&read)
+		if original.String() != read.String() {
+			t.Errorf("release %v: the parsed class is disassembled as\n%v\ninstead of\n%v", release, read.String(), original.String())
+		}
+		if parsed.Target() != class.Target() || len(parsed.BootstrapMethods()) != 1 {
+			t.Errorf("release %v: target %v and %v bootstrap methods were read", release, parsed.Target(), len(parsed.BootstrapMethods()))
+		}
+	}
+}
+
+// hello returns a class that prints a string in its main method
+func hello(t *testing.T) *Class {
+	class := NewClass("Hello", "java/lang/Object", NewTarget(MIN_RELEASE))
+	assemble(t, class, "main", "([Ljava/lang/String;)V", 1, func(a *instructions.Assembler) {
+		a.Field(instructions.GETSTATIC, class.AddFieldRef("out", "L"+PRINT_STREAM+";", "java/lang/System"), "L"+PRINT_STREAM+";")
+		a.Emit(instructions.LDC, int(class.AddString("hi")))
+		a.Invoke(instructions.INVOKEVIRTUAL, class.AddMethodRef("println", "(Ljava/lang/String;)V", PRINT_STREAM), "(Ljava/lang/String;)V")
+		a.Emit(instructions.RETURN)
+	})
+	return class
+}
+
+func TestParseClassErrors(t *testing.T) {
+	data, err := hello(t).ConvertToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first constant starts after the magic, the version and the constant pool count
+	firstConstant := 10
+	utf8 := bytes.Index(data, []byte("java/lang/System"))
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		err    string
+	}{
+		{"wrong magic", func(data []byte) []byte { data[0] = 0; return data }, "not a class file"},
+		{"empty", func(data []byte) []byte { return data[:0] }, "unexpected end of class file at offset 0"},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-3] }, "unexpected end of class file"},
+		{"trailing bytes", func(data []byte) []byte { return append(data, 0, 0) }, "2 trailing bytes"},
+		{"unknown constant", func(data []byte) []byte { data[firstConstant] = 99; return data }, "unknown constant pool tag 99 at index 1"},
+		{"invalid utf8", func(data []byte) []byte { data[utf8] = 0xff; return data }, "invalid modified utf8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class, err := ParseClass(test.modify(bytes.Clone(data)))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected the error '%v' but got %v (%v)", test.err, err, class)
+			}
+		})
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	data, err := hello(t).ConvertToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	class, err := ParseClass(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	class.Disassemble(&output)
+	expected := `public class Hello extends java/lang/Object
+  minor version: 0
+  major version: 52
+  flags: (0x0021) ACC_PUBLIC, ACC_SUPER
+Constant pool:
+    #1 = Utf8               java/lang/System
+    #2 = Class              #1            // java/lang/System
+    #3 = Utf8               out
+    #4 = Utf8               Ljava/io/PrintStream;
+    #5 = NameAndType        #3:#4         // out:Ljava/io/PrintStream;
+    #6 = Fieldref           #2.#5         // java/lang/System.out:Ljava/io/PrintStream;
+    #7 = Utf8               hi
+    #8 = String             #7            // hi
+    #9 = Utf8               java/io/PrintStream
+   #10 = Class              #9            // java/io/PrintStream
+   #11 = Utf8               println
+   #12 = Utf8               (Ljava/lang/String;)V
+   #13 = NameAndType        #11:#12       // println:(Ljava/lang/String;)V
+   #14 = Methodref          #10.#13       // java/io/PrintStream.println:(Ljava/lang/String;)V
+   #15 = Utf8               Hello
+   #16 = Class              #15           // Hello
+   #17 = Utf8               java/lang/Object
+   #18 = Class              #17           // java/lang/Object
+   #19 = Utf8               main
+   #20 = Utf8               ([Ljava/lang/String;)V
+   #21 = Utf8               Code
+{
+  public static void main(java.lang.String[]);
+    descriptor: ([Ljava/lang/String;)V
+    flags: (0x0009) ACC_PUBLIC, ACC_STATIC
+    Code:
+      stack=2, locals=1
+         0: getstatic     #6        // java/lang/System.out:Ljava/io/PrintStream;
+         3: ldc           #8        // "hi"
+         5: invokevirtual #14       // java/io/PrintStream.println:(Ljava/lang/String;)V
+         8: return
+
+}
+`
+	if output.String() != expected {
+		t.Errorf("expected the listing\n%v\nbut got\n%v", expected, output.String())
+	}
+}
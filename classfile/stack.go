@@ -0,0 +1,119 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"fmt"
+)
+
+// computeMaxStack walks every reachable path through the code and returns the deepest operand stack
+func (c *Class) computeMaxStack(code []byte, handlers []ExceptionHandler) (uint16, error) {
+	insts, err := DecodeInstructions(code)
+	if err != nil {
+		return 0, err
+	}
+	indexOfOffset := make(map[int]int)
+	for index, inst := range insts {
+		indexOfOffset[inst.Offset] = index
+	}
+	depths := make(map[int]int)
+	worklist := make([]int, 0)
+	maxStack := 0
+	enqueue := func(offset int, depth int) error {
+		if _, ok := indexOfOffset[offset]; !ok {
+			return fmt.Errorf("error: jump to invalid offset %v", offset)
+		}
+		if known, ok := depths[offset]; ok {
+			if known != depth {
+				return fmt.Errorf("error: inconsistent stack height at offset %v (%v != %v)", offset, known, depth)
+			}
+			return nil
+		}
+		depths[offset] = depth
+		if depth > maxStack {
+			maxStack = depth
+		}
+		worklist = append(worklist, offset)
+		return nil
+	}
+	if len(insts) > 0 {
+		if err := enqueue(0, 0); err != nil {
+			return 0, err
+		}
+	}
+	for _, handler := range handlers {
+		if err := enqueue(int(handler.HandlerPC), 1); err != nil {
+			return 0, err
+		}
+	}
+	for len(worklist) > 0 {
+		offset := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		inst := insts[indexOfOffset[offset]]
+		pop, push, err := c.stackEffect(inst)
+		if err != nil {
+			return 0, err
+		}
+		depth := depths[offset]
+		if depth < pop {
+			return 0, fmt.Errorf("error: stack underflow at offset %v", offset)
+		}
+		depth = depth - pop + push
+		if depth > maxStack {
+			maxStack = depth
+		}
+		for _, target := range inst.Targets {
+			if err := enqueue(target, depth); err != nil {
+				return 0, err
+			}
+		}
+		if inst.IsUnconditionalJump() {
+			continue
+		}
+		next := offset + inst.Length()
+		if next >= len(code) {
+			return 0, fmt.Errorf("error: execution falls off the end of the code at offset %v", offset)
+		}
+		if err := enqueue(next, depth); err != nil {
+			return 0, err
+		}
+	}
+	if maxStack > 0xffff {
+		return 0, fmt.Errorf("error: operand stack too deep (%v)", maxStack)
+	}
+	return uint16(maxStack), nil
+}
+
+// stackEffect returns how many stack slots an instruction pops and pushes
+func (c *Class) stackEffect(inst Instruction) (int, int, error) {
+	op := inst.Opcode
+	opcode, ok := instructions.Lookup(op)
+	if !ok {
+		return 0, 0, fmt.Errorf("error: unknown opcode 0x%x at offset %v", op, inst.Offset)
+	}
+	switch {
+	case op >= instructions.GETSTATIC && op <= instructions.PUTFIELD:
+		descriptor, err := c.constPool.memberDescriptor(inst.U16())
+		if err != nil {
+			return 0, 0, err
+		}
+		size := SlotSize(descriptor)
+		return []int{0, size, 1, size + 1}[op-instructions.GETSTATIC], []int{size, 0, size, 0}[op-instructions.GETSTATIC], nil
+	case op >= instructions.INVOKEVIRTUAL && op <= instructions.INVOKEDYNAMIC:
+		descriptor, err := c.constPool.memberDescriptor(inst.U16())
+		if err != nil {
+			return 0, 0, err
+		}
+		md, err := ParseMethodDescriptor(descriptor)
+		if err != nil {
+			return 0, 0, err
+		}
+		pop := md.ArgSlots()
+		if op != instructions.INVOKESTATIC && op != instructions.INVOKEDYNAMIC {
+			pop++
+		}
+		return pop, SlotSize(md.ReturnType), nil
+	case op == instructions.MULTIANEWARRAY:
+		return int(inst.Operands[2]), opcode.Push, nil
+	}
+	return opcode.Pop, opcode.Push, nil
+}
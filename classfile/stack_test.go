@@ -0,0 +1,67 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"strings"
+	"testing"
+)
+
+func TestMaxStack(t *testing.T) {
+	class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+	add := class.AddMethodRef("add", "(JJ)J", "Test")
+	tests := []struct {
+		name     string
+		code     []byte
+		handlers []ExceptionHandler
+		maxStack uint16
+		// a part of the expected error message, empty if the code is valid
+		err string
+	}{
+		{"straight line", []byte{instructions.ICONST_1, instructions.ICONST_2, instructions.IADD, instructions.IRETURN}, nil, 2, ""},
+		{"wide values", []byte{instructions.LCONST_1, instructions.DCONST_1, instructions.POP2, instructions.POP2, instructions.RETURN}, nil, 4, ""},
+		{"invoke", []byte{instructions.LCONST_0, instructions.LCONST_1, instructions.INVOKESTATIC, byte(add >> 8), byte(add), instructions.LRETURN}, nil, 4, ""},
+		{"deepest branch", []byte{instructions.ILOAD_0, instructions.IFEQ, 0, 8, instructions.ICONST_1, instructions.ICONST_2, instructions.ICONST_3,
+			instructions.POP2, instructions.POP, instructions.RETURN}, nil, 3, ""},
+		{"exception handler", []byte{instructions.RETURN, instructions.ATHROW}, []ExceptionHandler{{StartPC: 0, EndPC: 1, HandlerPC: 1}}, 1, ""},
+		{"inconsistent height", []byte{instructions.ILOAD_0, instructions.IFEQ, 0, 4, instructions.ICONST_1, instructions.RETURN}, nil, 0,
+			"inconsistent stack height at offset 5"},
+		{"underflow", []byte{instructions.POP, instructions.RETURN}, nil, 0, "stack underflow at offset 0"},
+		{"end of code", []byte{instructions.NOP}, nil, 0, "falls off the end of the code"},
+		{"invalid jump", []byte{instructions.GOTO, 0, 2, instructions.RETURN}, nil, 0, "jump to invalid offset 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			maxStack, err := class.computeMaxStack(test.code, test.handlers)
+			if test.err == "" && (err != nil || maxStack != test.maxStack) {
+				t.Errorf("expected max stack %v but got %v (%v)", test.maxStack, maxStack, err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("expected the error '%v' but got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCodeAttribute(t *testing.T) {
+	class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+	code := []byte{instructions.ICONST_1, instructions.I2L, instructions.LCONST_1, instructions.LADD, instructions.LRETURN}
+	if err := class.AddMethod(ACC_PUBLIC|ACC_STATIC, "two", "()J", code, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := class.ConvertToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseClass(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, ok := parsed.FindMethod("two", "()J")
+	if !ok {
+		t.Fatal("the method was not written")
+	}
+	if method.Code.MaxStack != 4 || len(method.Code.Code) != len(code) || len(method.Code.ExceptionTable) != 0 || len(method.Code.Attributes) != 0 {
+		t.Errorf("expected max stack 4, %v bytes of code and no exception handlers or attributes but got %v, %v, %v and %v",
+			len(code), method.Code.MaxStack, len(method.Code.Code), len(method.Code.ExceptionTable), len(method.Code.Attributes))
+	}
+}
@@ -0,0 +1,370 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const (
+	SAME_FRAME_MAX          = 63
+	SAME_LOCALS_1_STACK     = 64
+	SAME_LOCALS_1_STACK_MAX = 127
+	SAME_LOCALS_1_STACK_EXT = 247
+	CHOP_FRAME              = 251
+	SAME_FRAME_EXTENDED     = 251
+	APPEND_FRAME            = 251
+	FULL_FRAME              = 255
+)
+
+type stackMapFrame struct {
+	offset int
+	frame  Frame
+}
+
+// initialFrame builds the frame at the start of a method from its flags and descriptor
+func (c *Class) initialFrame(m *Method) (Frame, error) {
+	md, err := ParseMethodDescriptor(m.Descriptor)
+	if err != nil {
+		return Frame{}, err
+	}
+	frame := Frame{Locals: make([]VerificationType, m.Code.MaxLocals), Stack: make([]VerificationType, 0)}
+	index := 0
+	if m.Flags&ACC_STATIC == 0 {
+		if len(frame.Locals) == 0 {
+			return Frame{}, fmt.Errorf("error: max locals of method %v is too small for its arguments", m.Name)
+		}
+		frame.Locals[0] = objectOf(c.name)
+		if m.Name == "<init>" && c.name != "java/lang/Object" {
+			frame.Locals[0] = VerificationType{Tag: ITEM_UNINITIALIZEDTHIS}
+		}
+		index++
+	}
+	for _, arg := range md.Args {
+		if err := frame.store(index, typeOfDescriptor(arg)); err != nil {
+			return Frame{}, fmt.Errorf("error: max locals of method %v is too small for its arguments", m.Name)
+		}
+		index += SlotSize(arg)
+	}
+	return frame, nil
+}
+
+// computeFrames runs a data flow analysis over the control flow graph of a method and returns the
+// frames at the start of every instruction that can be reached
+func (c *Class) computeFrames(m *Method, insts []Instruction) (map[int]*Frame, error) {
+	initial, err := c.initialFrame(m)
+	if err != nil {
+		return nil, err
+	}
+	indexOfOffset := make(map[int]int)
+	for index, inst := range insts {
+		indexOfOffset[inst.Offset] = index
+	}
+	frames := make(map[int]*Frame)
+	worklist := make([]int, 0)
+	propagate := func(offset int, frame Frame) error {
+		if _, ok := indexOfOffset[offset]; !ok {
+			return fmt.Errorf("error: jump to invalid offset %v", offset)
+		}
+		known, ok := frames[offset]
+		if !ok {
+			copied := frame.copy()
+			frames[offset] = &copied
+			worklist = append(worklist, offset)
+			return nil
+		}
+		changed, err := known.merge(frame)
+		if err != nil {
+			return fmt.Errorf("error: at offset %v: %v", offset, err)
+		}
+		if changed {
+			worklist = append(worklist, offset)
+		}
+		return nil
+	}
+	if len(insts) > 0 {
+		if err := propagate(0, initial); err != nil {
+			return nil, err
+		}
+	}
+	for len(worklist) > 0 {
+		offset := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		inst := insts[indexOfOffset[offset]]
+		for _, handler := range m.Code.ExceptionTable {
+			if offset >= int(handler.StartPC) && offset < int(handler.EndPC) {
+				caught := throwable
+				if handler.CatchType != 0 {
+					class, err := c.constPool.className(handler.CatchType)
+					if err != nil {
+						return nil, err
+					}
+					caught = objectOf(class)
+				}
+				handlerFrame := Frame{Locals: frames[offset].Locals, Stack: []VerificationType{caught}}
+				if err := propagate(int(handler.HandlerPC), handlerFrame); err != nil {
+					return nil, err
+				}
+			}
+		}
+		frame := frames[offset].copy()
+		if err := c.execute(inst, &frame); err != nil {
+			return nil, fmt.Errorf("error: at offset %v: %v", offset, err)
+		}
+		for _, target := range inst.Targets {
+			if err := propagate(target, frame); err != nil {
+				return nil, err
+			}
+		}
+		if inst.IsUnconditionalJump() {
+			continue
+		}
+		if offset+inst.Length() >= len(m.Code.Code) {
+			return nil, fmt.Errorf("error: execution falls off the end of the code at offset %v", offset)
+		}
+		if err := propagate(offset+inst.Length(), frame); err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+// blockStarts returns the offsets that need a stack map frame
+func blockStarts(insts []Instruction, handlers []ExceptionHandler) []int {
+	starts := make(map[int]bool)
+	for index, inst := range insts {
+		for _, target := range inst.Targets {
+			starts[target] = true
+		}
+		if inst.IsUnconditionalJump() && index+1 < len(insts) {
+			starts[insts[index+1].Offset] = true
+		}
+	}
+	for _, handler := range handlers {
+		starts[int(handler.HandlerPC)] = true
+	}
+	offsets := make([]int, 0, len(starts))
+	for offset := range starts {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// eraseDeadCode replaces unreachable instructions with nops followed by an athrow so that the
+// verifier can check them with a frame that only holds a Throwable. It reports whether code was erased.
+func eraseDeadCode(code []byte, insts []Instruction, frames map[int]*Frame) bool {
+	erased := false
+	for index := 0; index < len(insts); index++ {
+		if _, ok := frames[insts[index].Offset]; ok {
+			continue
+		}
+		start := insts[index].Offset
+		for index+1 < len(insts) && frames[insts[index+1].Offset] == nil {
+			index++
+		}
+		end := insts[index].Offset + insts[index].Length()
+		for i := start; i < end-1; i++ {
+			code[i] = instructions.NOP
+		}
+		code[end-1] = instructions.ATHROW
+		frames[start] = &Frame{Locals: make([]VerificationType, 0), Stack: []VerificationType{throwable}}
+		erased = true
+	}
+	return erased
+}
+
+// computeStackMapTable generates the StackMapTable attribute of a method. It may rewrite dead code
+// in place and returns nil if the method does not need any frames.
+func (c *Class) computeStackMapTable(m *Method) (*Attribute, bool, error) {
+	insts, err := DecodeInstructions(m.Code.Code)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(blockStarts(insts, m.Code.ExceptionTable)) == 0 {
+		return nil, false, nil
+	}
+	frames, err := c.computeFrames(m, insts)
+	if err != nil {
+		return nil, false, err
+	}
+	erased := eraseDeadCode(m.Code.Code, insts, frames)
+	if erased {
+		insts, _ = DecodeInstructions(m.Code.Code)
+	}
+	offsets := blockStarts(insts, m.Code.ExceptionTable)
+	initial, err := c.initialFrame(m)
+	if err != nil {
+		return nil, false, err
+	}
+	data := binary.BigEndian.AppendUint16(make([]byte, 0), uint16(len(offsets)))
+	previous := stackMapFrame{offset: -1, frame: initial}
+	for _, offset := range offsets {
+		current := stackMapFrame{offset: offset, frame: *frames[offset]}
+		data = append(data, c.convertFrameToBytes(previous, current)...)
+		previous = current
+	}
+	return &Attribute{Name: "StackMapTable", Data: data}, erased, nil
+}
+
+// convertFrameToBytes picks the most compact frame type that describes current relative to previous
+func (c *Class) convertFrameToBytes(previous, current stackMapFrame) []byte {
+	delta := current.offset - previous.offset - 1
+	previousLocals, locals := entries(previous.frame.Locals), entries(current.frame.Locals)
+	stack := current.frame.Stack
+	common := 0
+	for common < len(previousLocals) && common < len(locals) && previousLocals[common] == locals[common] {
+		common++
+	}
+	frameAsBytes := make([]byte, 0)
+	switch {
+	case len(stack) == 0 && common == len(locals) && common == len(previousLocals):
+		if delta <= SAME_FRAME_MAX {
+			return append(frameAsBytes, byte(delta))
+		}
+		frameAsBytes = append(frameAsBytes, SAME_FRAME_EXTENDED)
+		return binary.BigEndian.AppendUint16(frameAsBytes, uint16(delta))
+	case len(stack) == 1 && common == len(locals) && common == len(previousLocals):
+		if delta <= SAME_FRAME_MAX {
+			frameAsBytes = append(frameAsBytes, byte(SAME_LOCALS_1_STACK+delta))
+		} else {
+			frameAsBytes = append(frameAsBytes, SAME_LOCALS_1_STACK_EXT)
+			frameAsBytes = binary.BigEndian.AppendUint16(frameAsBytes, uint16(delta))
+		}
+		return append(frameAsBytes, c.convertVerificationTypeToBytes(stack[0])...)
+	case len(stack) == 0 && common == len(locals) && len(previousLocals)-common <= 3:
+		frameAsBytes = append(frameAsBytes, byte(CHOP_FRAME-(len(previousLocals)-common)))
+		return binary.BigEndian.AppendUint16(frameAsBytes, uint16(delta))
+	case len(stack) == 0 && common == len(previousLocals) && len(locals)-common <= 3:
+		frameAsBytes = append(frameAsBytes, byte(APPEND_FRAME+len(locals)-common))
+		frameAsBytes = binary.BigEndian.AppendUint16(frameAsBytes, uint16(delta))
+		for _, local := range locals[common:] {
+			frameAsBytes = append(frameAsBytes, c.convertVerificationTypeToBytes(local)...)
+		}
+		return frameAsBytes
+	}
+	frameAsBytes = append(frameAsBytes, FULL_FRAME)
+	frameAsBytes = binary.BigEndian.AppendUint16(frameAsBytes, uint16(delta))
+	frameAsBytes = binary.BigEndian.AppendUint16(frameAsBytes, uint16(len(locals)))
+	for _, local := range locals {
+		frameAsBytes = append(frameAsBytes, c.convertVerificationTypeToBytes(local)...)
+	}
+	frameAsBytes = binary.BigEndian.AppendUint16(frameAsBytes, uint16(len(stack)))
+	for _, item := range stack {
+		frameAsBytes = append(frameAsBytes, c.convertVerificationTypeToBytes(item)...)
+	}
+	return frameAsBytes
+}
+
+func (c *Class) convertVerificationTypeToBytes(vt VerificationType) []byte {
+	typeAsBytes := []byte{vt.Tag}
+	switch vt.Tag {
+	case ITEM_OBJECT:
+		typeAsBytes = binary.BigEndian.AppendUint16(typeAsBytes, c.constPool.Class(vt.Class))
+	case ITEM_UNINITIALIZED:
+		typeAsBytes = binary.BigEndian.AppendUint16(typeAsBytes, vt.Offset)
+	}
+	return typeAsBytes
+}
+
+// decodeStackMapTable expands the compressed frames of a StackMapTable attribute back into full frames
+func (c *Class) decodeStackMapTable(data []byte, initial Frame) ([]stackMapFrame, error) {
+	r := &classReader{data: data}
+	count := int(r.u2())
+	frames := make([]stackMapFrame, 0, count)
+	previous := stackMapFrame{offset: -1, frame: initial}
+	readTypes := func(n int) ([]VerificationType, error) {
+		types := make([]VerificationType, 0, n)
+		for i := 0; i < n; i++ {
+			vt := VerificationType{Tag: r.u1()}
+			switch vt.Tag {
+			case ITEM_OBJECT:
+				class, err := c.constPool.className(r.u2())
+				if err != nil {
+					return nil, err
+				}
+				vt.Class = class
+			case ITEM_UNINITIALIZED:
+				vt.Offset = r.u2()
+			default:
+				if vt.Tag > ITEM_UNINITIALIZED {
+					return nil, fmt.Errorf("error: invalid verification type tag %v", vt.Tag)
+				}
+			}
+			types = append(types, vt)
+		}
+		return types, r.err
+	}
+	for i := 0; i < count; i++ {
+		frameType := r.u1()
+		delta := int(frameType)
+		locals := entries(previous.frame.Locals)
+		stack := make([]VerificationType, 0)
+		var err error
+		switch {
+		case frameType <= SAME_FRAME_MAX:
+		case frameType <= SAME_LOCALS_1_STACK_MAX:
+			delta = int(frameType - SAME_LOCALS_1_STACK)
+			stack, err = readTypes(1)
+		case frameType < SAME_LOCALS_1_STACK_EXT:
+			return nil, fmt.Errorf("error: reserved stack map frame type %v", frameType)
+		case frameType == SAME_LOCALS_1_STACK_EXT:
+			delta = int(r.u2())
+			stack, err = readTypes(1)
+		case frameType < CHOP_FRAME:
+			delta = int(r.u2())
+			chopped := int(CHOP_FRAME - frameType)
+			if chopped > len(locals) {
+				return nil, fmt.Errorf("error: chop frame removes more locals than there are")
+			}
+			locals = locals[:len(locals)-chopped]
+		case frameType == SAME_FRAME_EXTENDED:
+			delta = int(r.u2())
+		case frameType < FULL_FRAME:
+			delta = int(r.u2())
+			var appended []VerificationType
+			appended, err = readTypes(int(frameType - APPEND_FRAME))
+			locals = append(locals, appended...)
+		default:
+			delta = int(r.u2())
+			if locals, err = readTypes(int(r.u2())); err == nil {
+				stack, err = readTypes(int(r.u2()))
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		current := stackMapFrame{offset: previous.offset + delta + 1, frame: Frame{Stack: stack}}
+		if current.frame.Locals, err = expandLocals(locals, len(initial.Locals)); err != nil {
+			return nil, fmt.Errorf("error: stack map frame at offset %v: %v", current.offset, err)
+		}
+		frames = append(frames, current)
+		previous = current
+	}
+	if r.offset != len(data) {
+		return nil, fmt.Errorf("error: StackMapTable attribute has the wrong length")
+	}
+	return frames, nil
+}
+
+// expandLocals is the inverse of entries and spreads long and double values over two slots
+func expandLocals(locals []VerificationType, maxLocals int) ([]VerificationType, error) {
+	slots := make([]VerificationType, maxLocals)
+	index := 0
+	for _, local := range locals {
+		size := 1
+		if local.IsWide() {
+			size = 2
+		}
+		if index+size > maxLocals {
+			return nil, fmt.Errorf("more locals than max_locals (%v)", maxLocals)
+		}
+		slots[index] = local
+		index += size
+	}
+	return slots, nil
+}
@@ -0,0 +1,145 @@
+package classfile
+
+import (
+	"bytes"
+	"compiler/instructions"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestStackMapFrameTypes(t *testing.T) {
+	class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+	str := class.constPool.Class("java/lang/String")
+	locals := func(types ...VerificationType) []VerificationType {
+		return append(types, make([]VerificationType, 3-len(types))...)
+	}
+	tests := []struct {
+		name     string
+		previous []VerificationType
+		offset   int
+		current  Frame
+		data     []byte
+	}{
+		{"same", locals(intType), 5, Frame{Locals: locals(intType)}, []byte{5}},
+		{"same extended", locals(intType), 100, Frame{Locals: locals(intType)}, []byte{SAME_FRAME_EXTENDED, 0, 100}},
+		{"same locals and one stack item", locals(intType), 3, Frame{Locals: locals(intType), Stack: []VerificationType{intType}},
+			[]byte{SAME_LOCALS_1_STACK + 3, ITEM_INTEGER}},
+		{"same locals and one object", locals(), 5, Frame{Locals: locals(), Stack: []VerificationType{stringType}},
+			[]byte{SAME_LOCALS_1_STACK + 5, ITEM_OBJECT, byte(str >> 8), byte(str)}},
+		{"same locals and one stack item extended", locals(intType), 70, Frame{Locals: locals(intType), Stack: []VerificationType{longType}},
+			[]byte{SAME_LOCALS_1_STACK_EXT, 0, 70, ITEM_LONG}},
+		{"append", locals(intType), 5, Frame{Locals: locals(intType, longType, topType)}, []byte{APPEND_FRAME + 1, 0, 5, ITEM_LONG}},
+		{"chop", locals(intType, intType, intType), 5, Frame{Locals: locals(intType)}, []byte{CHOP_FRAME - 2, 0, 5}},
+		{"chop of a long", locals(intType, longType, topType), 5, Frame{Locals: locals(intType)}, []byte{CHOP_FRAME - 1, 0, 5}},
+		{"full", locals(intType), 5, Frame{Locals: locals(floatType), Stack: []VerificationType{intType, intType}},
+			[]byte{FULL_FRAME, 0, 5, 0, 1, ITEM_FLOAT, 0, 2, ITEM_INTEGER, ITEM_INTEGER}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous := stackMapFrame{offset: -1, frame: Frame{Locals: test.previous}}
+			data := class.convertFrameToBytes(previous, stackMapFrame{offset: test.offset, frame: test.current})
+			if !bytes.Equal(data, test.data) {
+				t.Fatalf("expected the frame %v but got %v", test.data, data)
+			}
+			decoded, err := class.decodeStackMapTable(append([]byte{0, 1}, data...), previous.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current := (stackMapFrame{offset: test.offset, frame: test.current}); len(decoded) != 1 || decoded[0].offset != current.offset ||
+				!reflect.DeepEqual(decoded[0].frame.Locals, current.frame.Locals) || len(decoded[0].frame.Stack) != len(current.frame.Stack) {
+				t.Errorf("the frame is decoded as %v instead of %v", decoded, current)
+			}
+		})
+	}
+}
+
+func TestStackMapTable(t *testing.T) {
+	class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+	// for (int i = 0; i < n; i++) { long l = i; } with a string argument and a branch that leaves a value on the stack
+	assemble(t, class, "loop", "(Ljava/lang/String;I)I", 5, func(a *instructions.Assembler) {
+		condition, end, other, join := a.NewLabel(), a.NewLabel(), a.NewLabel(), a.NewLabel()
+		a.Emit(instructions.ICONST_0)
+		a.Emit(instructions.ISTORE_2)
+		a.Jump(instructions.GOTO, condition)
+		body := a.NewLabel()
+		a.Mark(body)
+		a.Emit(instructions.ILOAD_2)
+		a.Emit(instructions.I2L)
+		a.Emit(instructions.LSTORE_3)
+		a.Emit(instructions.IINC, 2, 1)
+		a.Mark(condition)
+		a.Emit(instructions.ILOAD_2)
+		a.Emit(instructions.ILOAD_1)
+		a.Jump(instructions.IF_ICMPLT, body)
+		a.Mark(end)
+		a.Emit(instructions.ILOAD_1)
+		a.Jump(instructions.IFEQ, other)
+		a.Emit(instructions.ICONST_1)
+		a.Jump(instructions.GOTO, join)
+		a.Mark(other)
+		a.Emit(instructions.ICONST_2)
+		a.Mark(join)
+		a.Emit(instructions.IRETURN)
+	})
+	method := class.Methods()[0]
+	if len(method.Code.Attributes) != 1 || method.Code.Attributes[0].Name != "StackMapTable" {
+		t.Fatalf("expected a StackMapTable attribute but got %v", method.Code.Attributes)
+	}
+	data := method.Code.Attributes[0].Data
+	if count := binary.BigEndian.Uint16(data); count != 4 {
+		t.Errorf("expected frames at the loop body, the condition and both branches but got %v frames", count)
+	}
+	initial, err := class.initialFrame(&method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := class.decodeStackMapTable(data, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the long of the loop body is not part of the frame at the condition, which is also reached from before the loop
+	expected := []Frame{
+		{Locals: []VerificationType{stringType, intType, intType, topType, topType}, Stack: []VerificationType{}},
+		{Locals: []VerificationType{stringType, intType, intType, topType, topType}, Stack: []VerificationType{}},
+		{Locals: []VerificationType{stringType, intType, intType, topType, topType}, Stack: []VerificationType{}},
+		{Locals: []VerificationType{stringType, intType, intType, topType, topType}, Stack: []VerificationType{intType}},
+	}
+	for i, frame := range frames {
+		if i < len(expected) && !reflect.DeepEqual(frame.frame, expected[i]) {
+			t.Errorf("expected the frame %v at offset %v but got %v", expected[i], frame.offset, frame.frame)
+		}
+	}
+}
+
+func TestStackMapTableOfConstructor(t *testing.T) {
+	class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+	a := instructions.NewAssembler()
+	skip := a.NewLabel()
+	a.Emit(instructions.ILOAD_1)
+	a.Jump(instructions.IFEQ, skip)
+	a.Mark(skip)
+	a.Emit(instructions.ALOAD_0)
+	a.Invoke(instructions.INVOKESPECIAL, class.AddMethodRef("<init>", "()V", "java/lang/Object"), "()V")
+	a.Emit(instructions.RETURN)
+	code, err := a.Assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := class.AddMethod(ACC_PUBLIC, "<init>", "(I)V", code, 2); err != nil {
+		t.Fatal(err)
+	}
+	method := class.Methods()[0]
+	initial, err := class.initialFrame(&method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := class.decodeStackMapTable(method.Code.Attributes[0].Data, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uninitializedThis := VerificationType{Tag: ITEM_UNINITIALIZEDTHIS}
+	if len(frames) != 1 || !reflect.DeepEqual(frames[0].frame.Locals, []VerificationType{uninitializedThis, intType}) {
+		t.Errorf("expected a frame with uninitialized this before the super constructor is called but got %v", frames)
+	}
+}
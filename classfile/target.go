@@ -0,0 +1,67 @@
+package classfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAGIC         = 0xCAFEBABE
+	MIN_RELEASE   = 8
+	MAX_RELEASE   = 21
+	JAVA8_MAJOR   = 52
+	DEFAULT_MINOR = 0
+)
+
+type Target struct {
+	Release int
+	Major   uint16
+	Minor   uint16
+}
+
+var DefaultTarget Target = NewTarget(8)
+
+func NewTarget(release int) Target {
+	return Target{Release: release, Major: uint16(JAVA8_MAJOR + release - 8), Minor: DEFAULT_MINOR}
+}
+
+// ParseTarget accepts a java release number like "8", "1.8", "11" or "21". Only releases up to 8
+// were also named 1.x
+func ParseTarget(value string) (Target, error) {
+	release, err := strconv.Atoi(strings.TrimPrefix(value, "1."))
+	if err != nil || (strings.HasPrefix(value, "1.") && release > 8) {
+		return Target{}, fmt.Errorf("error: invalid target '%v'", value)
+	}
+	if release < MIN_RELEASE || release > MAX_RELEASE {
+		return Target{}, fmt.Errorf("error: unsupported target %v (supported are %v to %v)", release, MIN_RELEASE, MAX_RELEASE)
+	}
+	return NewTarget(release), nil
+}
+
+// SupportsStackMapTable reports whether methods carry StackMapTable attributes, they were added in java 6
+func (t Target) SupportsStackMapTable() bool {
+	return t.Major >= 50
+}
+
+// SupportsInvokeDynamic reports whether the class can contain invokedynamic instructions and
+// method handle constants, which were added in java 7
+func (t Target) SupportsInvokeDynamic() bool {
+	return t.Major >= 51
+}
+
+// SupportsNestmates reports whether private methods can be called with invokevirtual and
+// invokeinterface, which nestmates allow since java 11
+func (t Target) SupportsNestmates() bool {
+	return t.Major >= 55
+}
+
+// SupportsStringConcatFactory reports whether strings can be concatenated with invokedynamic and
+// java.lang.invoke.StringConcatFactory, which was added in java 9
+func (t Target) SupportsStringConcatFactory() bool {
+	return t.SupportsInvokeDynamic() && t.Release >= 9
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("java %v (class file version %v.%v)", t.Release, t.Major, t.Minor)
+}
@@ -0,0 +1,113 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"strings"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		value string
+		// the release of the target, 0 if the value is invalid
+		release int
+	}{
+		{"8", 8},
+		{"1.8", 8},
+		{"11", 11},
+		{"21", 21},
+		{"1.7", 0},
+		{"7", 0},
+		{"22", 0},
+		{"1.11", 0},
+		{"1.21", 0},
+		{"1.", 0},
+		{"eleven", 0},
+		{"", 0},
+	}
+	for _, test := range tests {
+		target, err := ParseTarget(test.value)
+		if test.release == 0 && err == nil {
+			t.Errorf("expected %q to be invalid but got %v", test.value, target)
+		}
+		if test.release != 0 && (err != nil || target != NewTarget(test.release)) {
+			t.Errorf("expected %q to be java %v but got %v (%v)", test.value, test.release, target, err)
+		}
+	}
+}
+
+func TestTargetGates(t *testing.T) {
+	tests := []struct {
+		release                                                      int
+		stackMapTable, invokeDynamic, nestmates, stringConcatFactory bool
+	}{
+		{5, false, false, false, false},
+		{6, true, false, false, false},
+		{7, true, true, false, false},
+		{8, true, true, false, false},
+		{9, true, true, false, true},
+		{11, true, true, true, true},
+		{21, true, true, true, true},
+	}
+	for _, test := range tests {
+		target := NewTarget(test.release)
+		if target.SupportsStackMapTable() != test.stackMapTable || target.SupportsInvokeDynamic() != test.invokeDynamic ||
+			target.SupportsNestmates() != test.nestmates || target.SupportsStringConcatFactory() != test.stringConcatFactory {
+			t.Errorf("wrong features of %v", target)
+		}
+	}
+}
+
+func TestInvokeDynamicGate(t *testing.T) {
+	class := NewClass("Old", "java/lang/Object", NewTarget(6))
+	class.AddInvokeDynamic(BootstrapMethod{}, "makeConcatWithConstants", "()Ljava/lang/String;")
+	if _, err := class.ConvertToBytes(); err == nil || !strings.Contains(err.Error(), "invokedynamic is not supported by java 6") {
+		t.Errorf("expected an error for invokedynamic in a java 6 class but got %v", err)
+	}
+}
+
+func TestStackMapTableGate(t *testing.T) {
+	// releases before MIN_RELEASE only occur in parsed class files
+	for _, release := range []int{5, MIN_RELEASE} {
+		class := NewClass("Branch", "java/lang/Object", NewTarget(release))
+		assemble(t, class, "main", "(I)V", 1, func(a *instructions.Assembler) {
+			loop := a.NewLabel()
+			a.Mark(loop)
+			a.Emit(instructions.ILOAD_0)
+			a.Jump(instructions.IFNE, loop)
+			a.Emit(instructions.RETURN)
+		})
+		attributes := len(class.Methods()[0].Code.Attributes)
+		if expected := map[bool]int{true: 1, false: 0}[release >= 6]; attributes != expected {
+			t.Errorf("java %v: expected %v code attributes but got %v", release, expected, attributes)
+		}
+	}
+}
+
+func TestNestmatesGate(t *testing.T) {
+	for _, release := range []int{MIN_RELEASE, 11} {
+		class := NewClass("Nest", "java/lang/Object", NewTarget(release))
+		assemble(t, class, "test", "()V", 0, func(a *instructions.Assembler) { a.Emit(instructions.RETURN) })
+		// a private instance method that calls itself with invokevirtual
+		index := class.AddMethodRef("self", "()V", "Nest")
+		byteCode := []byte{instructions.ALOAD_0, instructions.INVOKEVIRTUAL, byte(index >> 8), byte(index), instructions.RETURN}
+		if err := class.AddMethod(ACC_PRIVATE, "self", "()V", byteCode, 1); err != nil {
+			t.Fatal(err)
+		}
+		data, err := class.ConvertToBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseClass(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errors := parsed.Verify()
+		if release < 11 && (len(errors) != 1 || !strings.Contains(errors[0].Error(), "must be called with invokespecial before java 11")) {
+			t.Errorf("java %v: expected an invokespecial error but got %v", release, errors)
+		}
+		if release >= 11 && len(errors) > 0 {
+			t.Errorf("java %v: unexpected verify errors %v", release, errors)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// the verifier type checks instructions with its own rules instead of the ones the stack map frames
+// are computed with, so that a mistake in the frame computation shows up as a verify error
+
+// effects describes how the instructions that need no operands to be type checked change the operand
+// stack: the types before the '>' are popped and the ones after it are pushed. I is int, J long,
+// F float, D double, A any reference and N null
+var effects = make(map[byte]string)
+
+// knownSuperTypes lists every super class and interface of the library classes the compiler uses,
+// classes are not loaded so values of other classes are only assignable to their own type and Object
+var knownSuperTypes = map[string][]string{
+	"java/lang/Object":        {},
+	"java/lang/String":        {"java/lang/Object", "java/io/Serializable", "java/lang/Comparable", "java/lang/CharSequence", "java/lang/constant/Constable", "java/lang/constant/ConstantDesc"},
+	"java/lang/StringBuilder": {"java/lang/AbstractStringBuilder", "java/lang/Object", "java/io/Serializable", "java/lang/Comparable", "java/lang/CharSequence", "java/lang/Appendable"},
+	"java/io/PrintStream":     {"java/io/FilterOutputStream", "java/io/OutputStream", "java/lang/Object", "java/io/Closeable", "java/io/Flushable", "java/lang/AutoCloseable", "java/lang/Appendable"},
+	"java/lang/Throwable":     {"java/lang/Object", "java/io/Serializable"},
+}
+
+// stackForm is one form of an instruction that copies or removes stack values regardless of their type.
+// categories are the categories of the values it works on with the top of the stack last, result lists
+// the indices of these values in the order they are pushed back
+type stackForm struct {
+	categories []int
+	result     []int
+}
+
+var stackForms = map[byte][]stackForm{
+	instructions.POP:     {{[]int{1}, []int{}}},
+	instructions.POP2:    {{[]int{1, 1}, []int{}}, {[]int{2}, []int{}}},
+	instructions.DUP:     {{[]int{1}, []int{0, 0}}},
+	instructions.DUP_X1:  {{[]int{1, 1}, []int{1, 0, 1}}},
+	instructions.DUP_X2:  {{[]int{1, 1, 1}, []int{2, 0, 1, 2}}, {[]int{2, 1}, []int{1, 0, 1}}},
+	instructions.DUP2:    {{[]int{1, 1}, []int{0, 1, 0, 1}}, {[]int{2}, []int{0, 0}}},
+	instructions.DUP2_X1: {{[]int{1, 1, 1}, []int{1, 2, 0, 1, 2}}, {[]int{1, 2}, []int{1, 0, 1}}},
+	instructions.DUP2_X2: {{[]int{1, 1, 1, 1}, []int{2, 3, 0, 1, 2, 3}}, {[]int{1, 1, 2}, []int{2, 0, 1, 2}}, {[]int{2, 1, 1}, []int{1, 2, 0, 1, 2}}, {[]int{2, 2}, []int{1, 0, 1}}},
+	instructions.SWAP:    {{[]int{1, 1}, []int{1, 0}}},
+}
+
+func init() {
+	set := func(first byte, effect ...string) {
+		for i, e := range effect {
+			effects[first+byte(i)] = e
+		}
+	}
+	set(instructions.NOP, ">", ">N", ">I", ">I", ">I", ">I", ">I", ">I", ">I", ">J", ">J", ">F", ">F", ">F", ">D", ">D", ">I", ">I")
+	set(instructions.IALOAD, "AI>I", "AI>J", "AI>F", "AI>D")
+	set(instructions.BALOAD, "AI>I", "AI>I", "AI>I")
+	set(instructions.IASTORE, "AII>", "AIJ>", "AIF>", "AID>", "AIA>", "AII>", "AII>", "AII>")
+	primitives := "IJFD"
+	for i, t := range primitives {
+		for operation := 0; operation < 5; operation++ {
+			effects[instructions.IADD+byte(4*operation+i)] = fmt.Sprintf("%c%c>%c", t, t, t)
+		}
+		effects[instructions.INEG+byte(i)] = fmt.Sprintf("%c>%c", t, t)
+	}
+	set(instructions.ISHL, "II>I", "JI>J", "II>I", "JI>J", "II>I", "JI>J", "II>I", "JJ>J", "II>I", "JJ>J", "II>I", "JJ>J")
+	set(instructions.I2L, "I>J", "I>F", "I>D", "J>I", "J>F", "J>D", "F>I", "F>J", "F>D", "D>I", "D>J", "D>F", "I>I", "I>I", "I>I")
+	set(instructions.LCMP, "JJ>I", "FF>I", "FF>I", "DD>I", "DD>I")
+	set(instructions.IFEQ, "I>", "I>", "I>", "I>", "I>", "I>", "II>", "II>", "II>", "II>", "II>", "II>", "AA>", "AA>", ">")
+	set(instructions.TABLESWITCH, "I>", "I>", "I>", "J>", "F>", "D>", "A>", ">")
+	set(instructions.ARRAYLENGTH, "A>I", "A>")
+	set(instructions.MONITORENTER, "A>", "A>")
+	set(instructions.IFNULL, "A>", "A>", ">")
+}
+
+// verificationTypeOf maps the letters of effects to types
+func verificationTypeOf(letter byte) VerificationType {
+	switch letter {
+	case 'I':
+		return intType
+	case 'J':
+		return longType
+	case 'F':
+		return floatType
+	case 'D':
+		return doubleType
+	case 'N':
+		return nullType
+	}
+	return anyRefType
+}
+
+// descriptorType returns the type a value of a field descriptor has on the stack
+func descriptorType(descriptor string) VerificationType {
+	switch descriptor[0] {
+	case 'Z', 'B', 'C', 'S', 'I':
+		return intType
+	case 'J':
+		return longType
+	case 'F':
+		return floatType
+	case 'D':
+		return doubleType
+	case 'L':
+		return objectOf(strings.TrimSuffix(descriptor[1:], ";"))
+	}
+	return objectOf(descriptor)
+}
+
+// isAssignable reports whether a value of type from can be used where type to is expected
+func (v *methodVerifier) isAssignable(from, to VerificationType) bool {
+	switch {
+	case to.Tag == ITEM_TOP, from == to:
+		return true
+	case to == anyRefType:
+		return from.IsReference()
+	case to.Tag != ITEM_OBJECT:
+		return false
+	case from == nullType:
+		return true
+	case from.Tag == ITEM_OBJECT:
+		return v.isSubtype(from.Class, to.Class)
+	}
+	return false
+}
+
+// isSubtype reports whether a class or array type is the same as or a subtype of another one
+func (v *methodVerifier) isSubtype(from, to string) bool {
+	if from == to || to == "java/lang/Object" {
+		return true
+	}
+	if strings.HasPrefix(from, "[") {
+		if to == "java/lang/Cloneable" || to == "java/io/Serializable" {
+			return true
+		}
+		// arrays of references are covariant, arrays of primitives are only assignable to themselves
+		isReference := func(element string) bool { return element[0] == 'L' || element[0] == '[' }
+		if !strings.HasPrefix(to, "[") || !isReference(from[1:]) || !isReference(to[1:]) {
+			return false
+		}
+		return v.isSubtype(descriptorType(from[1:]).Class, descriptorType(to[1:]).Class)
+	}
+	if from == v.class.name {
+		supers := append([]string{v.class.super}, v.class.interfaces...)
+		return slices.Contains(supers, to) || v.isSubtype(v.class.super, to)
+	}
+	return slices.Contains(knownSuperTypes[from], to)
+}
+
+func (v *methodVerifier) isFrameAssignable(from, to Frame) bool {
+	if len(from.Stack) != len(to.Stack) {
+		return false
+	}
+	for i := range from.Stack {
+		if !v.isAssignable(from.Stack[i], to.Stack[i]) {
+			return false
+		}
+	}
+	for i := range to.Locals {
+		local := topType
+		if i < len(from.Locals) {
+			local = from.Locals[i]
+		}
+		if !v.isAssignable(local, to.Locals[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// initialFrame contains this and the arguments of the method
+func (v *methodVerifier) initialFrame() (Frame, error) {
+	frame := Frame{Locals: make([]VerificationType, v.method.Code.MaxLocals), Stack: make([]VerificationType, 0)}
+	arguments := make([]VerificationType, 0)
+	if v.method.Flags&ACC_STATIC == 0 {
+		if v.method.Name == "<init>" && v.class.name != "java/lang/Object" {
+			arguments = append(arguments, VerificationType{Tag: ITEM_UNINITIALIZEDTHIS})
+		} else {
+			arguments = append(arguments, objectOf(v.class.name))
+		}
+	}
+	for _, arg := range v.descriptor.Args {
+		arguments = append(arguments, descriptorType(arg))
+	}
+	index := 0
+	for _, argument := range arguments {
+		if err := v.store(&frame, index, argument); err != nil {
+			return Frame{}, fmt.Errorf("error: max locals %v is too small for the arguments", v.method.Code.MaxLocals)
+		}
+		index++
+		if argument.IsWide() {
+			index++
+		}
+	}
+	return frame, nil
+}
+
+func (v *methodVerifier) pop(f *Frame, expected VerificationType) (VerificationType, error) {
+	if len(f.Stack) == 0 {
+		return topType, fmt.Errorf("expected %v but the stack is empty", expected)
+	}
+	vt := f.Stack[len(f.Stack)-1]
+	f.Stack = f.Stack[:len(f.Stack)-1]
+	if !v.isAssignable(vt, expected) {
+		return vt, fmt.Errorf("expected %v on the stack but found %v", expected, vt)
+	}
+	return vt, nil
+}
+
+func (v *methodVerifier) load(f *Frame, index int, expected VerificationType) (VerificationType, error) {
+	if index >= len(f.Locals) || expected.IsWide() && index+1 >= len(f.Locals) {
+		return topType, fmt.Errorf("local variable %v is out of range", index)
+	}
+	vt := f.Locals[index]
+	if vt.Tag == ITEM_TOP || !v.isAssignable(vt, expected) {
+		return vt, fmt.Errorf("expected %v in local variable %v but found %v", expected, index, vt)
+	}
+	return vt, nil
+}
+
+// store replaces the local and the second slot of a long or double, a long or double the local
+// was the second slot of becomes unusable
+func (v *methodVerifier) store(f *Frame, index int, vt VerificationType) error {
+	last := index
+	if vt.IsWide() {
+		last++
+	}
+	if last >= len(f.Locals) {
+		return fmt.Errorf("local variable %v is out of range", last)
+	}
+	if index > 0 && f.Locals[index-1].IsWide() {
+		f.Locals[index-1] = topType
+	}
+	f.Locals[index] = vt
+	if last != index {
+		f.Locals[last] = topType
+	}
+	return nil
+}
+
+// step applies the effect of an instruction to the frame
+func (v *methodVerifier) step(inst Instruction, f *Frame) error {
+	op := inst.Opcode
+	cp := v.class.constPool
+	if effect, ok := effects[op]; ok {
+		popped, pushed, _ := strings.Cut(effect, ">")
+		for i := len(popped) - 1; i >= 0; i-- {
+			if _, err := v.pop(f, verificationTypeOf(popped[i])); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < len(pushed); i++ {
+			f.Stack = append(f.Stack, verificationTypeOf(pushed[i]))
+		}
+		return nil
+	}
+	if forms, ok := stackForms[op]; ok {
+		return v.stackOperation(forms, f)
+	}
+	switch {
+	case op == instructions.LDC, op == instructions.LDC_W, op == instructions.LDC2_W:
+		index := uint16(inst.U8())
+		if op != instructions.LDC {
+			index = inst.U16()
+		}
+		c, err := cp.get(index)
+		if err != nil {
+			return err
+		}
+		isWide := c.Tag == CONSTANT_LONG || c.Tag == CONSTANT_DOUBLE
+		if isWide != (op == instructions.LDC2_W) {
+			return fmt.Errorf("cannot load constant pool entry #%v of type %v", index, constantNames[c.Tag])
+		}
+		vt, ok := map[byte]VerificationType{CONSTANT_INTEGER: intType, CONSTANT_FLOAT: floatType, CONSTANT_LONG: longType,
+			CONSTANT_DOUBLE: doubleType, CONSTANT_STRING: stringType, CONSTANT_CLASS: objectOf("java/lang/Class"),
+			CONSTANT_METHODHANDLE: objectOf("java/lang/invoke/MethodHandle"), CONSTANT_METHODTYPE: objectOf("java/lang/invoke/MethodType")}[c.Tag]
+		if !ok {
+			return fmt.Errorf("cannot load constant pool entry #%v of type %v", index, constantNames[c.Tag])
+		}
+		f.Stack = append(f.Stack, vt)
+	case op >= instructions.ILOAD && op <= instructions.ALOAD, op >= instructions.ILOAD_0 && op <= instructions.ALOAD_3:
+		var kind, index int
+		if op >= instructions.ILOAD_0 {
+			kind, index = int(op-instructions.ILOAD_0)/4, int(op-instructions.ILOAD_0)%4
+		} else {
+			kind, index = int(op-instructions.ILOAD), inst.LocalIndex()
+		}
+		vt, err := v.load(f, index, verificationTypeOf("IJFDA"[kind]))
+		if err != nil {
+			return err
+		}
+		f.Stack = append(f.Stack, vt)
+	case op >= instructions.ISTORE && op <= instructions.ASTORE, op >= instructions.ISTORE_0 && op <= instructions.ASTORE_3:
+		var kind, index int
+		if op >= instructions.ISTORE_0 {
+			kind, index = int(op-instructions.ISTORE_0)/4, int(op-instructions.ISTORE_0)%4
+		} else {
+			kind, index = int(op-instructions.ISTORE), inst.LocalIndex()
+		}
+		vt, err := v.pop(f, verificationTypeOf("IJFDA"[kind]))
+		if err != nil {
+			return err
+		}
+		return v.store(f, index, vt)
+	case op == instructions.IINC:
+		_, err := v.load(f, inst.LocalIndex(), intType)
+		return err
+	case op == instructions.AALOAD:
+		if _, err := v.pop(f, intType); err != nil {
+			return err
+		}
+		array, err := v.pop(f, anyRefType)
+		if err != nil {
+			return err
+		}
+		if array == nullType {
+			f.Stack = append(f.Stack, nullType)
+		} else if array.Tag == ITEM_OBJECT && len(array.Class) > 1 && (array.Class[1] == 'L' || array.Class[1] == '[') {
+			f.Stack = append(f.Stack, descriptorType(array.Class[1:]))
+		} else {
+			return fmt.Errorf("expected an array of references but found %v", array)
+		}
+	case op >= instructions.GETSTATIC && op <= instructions.PUTFIELD:
+		class, _, descriptor, err := cp.MemberRef(inst.U16())
+		if err != nil {
+			return err
+		}
+		value := descriptorType(descriptor)
+		if op == instructions.PUTSTATIC || op == instructions.PUTFIELD {
+			if _, err := v.pop(f, value); err != nil {
+				return err
+			}
+		}
+		if op == instructions.GETFIELD || op == instructions.PUTFIELD {
+			if _, err := v.pop(f, objectOf(class)); err != nil {
+				return err
+			}
+		}
+		if op == instructions.GETSTATIC || op == instructions.GETFIELD {
+			f.Stack = append(f.Stack, value)
+		}
+	case op >= instructions.INVOKEVIRTUAL && op <= instructions.INVOKEDYNAMIC:
+		return v.invoke(inst, f)
+	case op == instructions.NEW:
+		f.Stack = append(f.Stack, VerificationType{Tag: ITEM_UNINITIALIZED, Offset: uint16(inst.Offset)})
+	case op == instructions.NEWARRAY:
+		if inst.U8() < 4 || inst.U8() > 11 {
+			return fmt.Errorf("invalid array type %v", inst.U8())
+		}
+		if _, err := v.pop(f, intType); err != nil {
+			return err
+		}
+		f.Stack = append(f.Stack, objectOf("["+"ZCFDBSIJ"[inst.U8()-4:inst.U8()-3]))
+	case op == instructions.ANEWARRAY, op == instructions.CHECKCAST, op == instructions.INSTANCEOF:
+		class, err := cp.className(inst.U16())
+		if err != nil {
+			return err
+		}
+		expected := anyRefType
+		if op == instructions.ANEWARRAY {
+			expected = intType
+		}
+		if _, err := v.pop(f, expected); err != nil {
+			return err
+		}
+		switch {
+		case op == instructions.INSTANCEOF:
+			f.Stack = append(f.Stack, intType)
+		case op == instructions.CHECKCAST:
+			f.Stack = append(f.Stack, objectOf(class))
+		case strings.HasPrefix(class, "["):
+			f.Stack = append(f.Stack, objectOf("["+class))
+		default:
+			f.Stack = append(f.Stack, objectOf("[L"+class+";"))
+		}
+	case op == instructions.MULTIANEWARRAY:
+		class, err := cp.className(inst.U16())
+		if err != nil {
+			return err
+		}
+		dimensions := int(inst.Operands[2])
+		if dimensions == 0 || len(class) < dimensions || strings.Count(class[:dimensions], "[") != dimensions {
+			return fmt.Errorf("%v has less than %v dimensions", class, dimensions)
+		}
+		for i := 0; i < dimensions; i++ {
+			if _, err := v.pop(f, intType); err != nil {
+				return err
+			}
+		}
+		f.Stack = append(f.Stack, objectOf(class))
+	default:
+		return fmt.Errorf("unsupported opcode 0x%x", op)
+	}
+	return nil
+}
+
+// stackOperation applies the first form that matches the categories of the values on the stack
+func (v *methodVerifier) stackOperation(forms []stackForm, f *Frame) error {
+	for _, form := range forms {
+		count := len(form.categories)
+		if count > len(f.Stack) {
+			continue
+		}
+		values := f.Stack[len(f.Stack)-count:]
+		matches := true
+		for i, value := range values {
+			category := 1
+			if value.IsWide() {
+				category = 2
+			}
+			matches = matches && category == form.categories[i] && value.Tag != ITEM_TOP
+		}
+		if !matches {
+			continue
+		}
+		values = slices.Clone(values)
+		f.Stack = f.Stack[:len(f.Stack)-count]
+		for _, index := range form.result {
+			f.Stack = append(f.Stack, values[index])
+		}
+		return nil
+	}
+	return fmt.Errorf("the values on the stack %v do not match any form of the instruction", f.Stack)
+}
+
+// invoke pops the receiver and arguments of a call and pushes its result, calling <init> initializes
+// every copy of the receiver
+func (v *methodVerifier) invoke(inst Instruction, f *Frame) error {
+	op := inst.Opcode
+	var class, name, descriptor string
+	var err error
+	if op == instructions.INVOKEDYNAMIC {
+		_, name, descriptor, err = v.class.constPool.InvokeDynamicRef(inst.U16())
+	} else {
+		class, name, descriptor, err = v.class.constPool.MemberRef(inst.U16())
+	}
+	if err != nil {
+		return err
+	}
+	md, err := ParseMethodDescriptor(descriptor)
+	if err != nil {
+		return err
+	}
+	if err := v.resolveMethod(op, class, name, descriptor); err != nil {
+		return err
+	}
+	if strings.HasPrefix(name, "<") && (op != instructions.INVOKESPECIAL || name != "<init>") {
+		return fmt.Errorf("%v cannot be called with %v", name, Mnemonic(op))
+	}
+	for i := len(md.Args) - 1; i >= 0; i-- {
+		if _, err := v.pop(f, descriptorType(md.Args[i])); err != nil {
+			return err
+		}
+	}
+	if name == "<init>" {
+		receiver, err := v.pop(f, anyRefType)
+		if err != nil {
+			return err
+		}
+		initialized, err := v.initializedType(receiver, class)
+		if err != nil {
+			return err
+		}
+		for _, types := range [][]VerificationType{f.Locals, f.Stack} {
+			for i := range types {
+				if types[i] == receiver {
+					types[i] = initialized
+				}
+			}
+		}
+	} else if op != instructions.INVOKESTATIC && op != instructions.INVOKEDYNAMIC {
+		if _, err := v.pop(f, objectOf(class)); err != nil {
+			return err
+		}
+	}
+	if md.ReturnType != "V" {
+		f.Stack = append(f.Stack, descriptorType(md.ReturnType))
+	}
+	return nil
+}
+
+// initializedType returns the type an uninitialized receiver of a constructor of class gets
+func (v *methodVerifier) initializedType(receiver VerificationType, class string) (VerificationType, error) {
+	switch receiver.Tag {
+	case ITEM_UNINITIALIZEDTHIS:
+		if class != v.class.name && class != v.class.super {
+			return topType, fmt.Errorf("constructor of %v called on this of %v", class, v.class.name)
+		}
+		return objectOf(v.class.name), nil
+	case ITEM_UNINITIALIZED:
+		created, ok := v.newClasses[int(receiver.Offset)]
+		if !ok {
+			return topType, fmt.Errorf("no new instruction at offset %v", receiver.Offset)
+		}
+		if created != class {
+			return topType, fmt.Errorf("constructor of %v called on a new %v", class, created)
+		}
+		return objectOf(class), nil
+	}
+	return topType, fmt.Errorf("<init> called on already initialized %v", receiver)
+}
+
+// resolveMethod checks that methods of this class exist with the descriptor they are called with
+// and that static methods are called with invokestatic and only with it
+func (v *methodVerifier) resolveMethod(op byte, class, name, descriptor string) error {
+	if class != v.class.name {
+		return nil
+	}
+	method, ok := v.class.FindMethod(name, descriptor)
+	if !ok {
+		if op != instructions.INVOKESTATIC {
+			// instance methods can be inherited from a class we do not know
+			return nil
+		}
+		for _, other := range v.class.methods {
+			if other.Name == name {
+				return fmt.Errorf("method %v is declared as %v%v but called as %v%v", name, name, other.Descriptor, name, descriptor)
+			}
+		}
+		return fmt.Errorf("method %v%v is not declared in %v", name, descriptor, class)
+	}
+	isStatic := method.Flags&ACC_STATIC != 0
+	if isStatic && op != instructions.INVOKESTATIC {
+		return fmt.Errorf("static method %v%v must be called with invokestatic", name, descriptor)
+	}
+	if !isStatic && op == instructions.INVOKESTATIC {
+		return fmt.Errorf("instance method %v%v cannot be called with invokestatic", name, descriptor)
+	}
+	if method.Flags&ACC_PRIVATE != 0 && op != instructions.INVOKESPECIAL && !isStatic && !v.class.target.SupportsNestmates() {
+		return fmt.Errorf("private method %v%v must be called with invokespecial before java 11", name, descriptor)
+	}
+	return nil
+}
@@ -2,26 +2,19 @@ package classfile
 
 import (
 	"encoding/binary"
-	"log"
+	"fmt"
+	"strings"
 )
 
-type Const struct {
-	Tag              byte
-	NameIndex        uint16
-	ClassIndex       uint16
-	NameAndTypeIndex uint16
-	StringIndex      uint16
-	DescIndex        uint16
-	Float            float32
-	String           string
-}
-
-type ConstPool []Const
-
-func (cp *ConstPool) AddConst(c Const) uint16 {
-	*cp = append(*cp, c)
-	return uint16(len(*cp))
-}
+const (
+	ACC_PUBLIC    = 0x0001
+	ACC_PRIVATE   = 0x0002
+	ACC_STATIC    = 0x0008
+	ACC_SUPER     = 0x0020
+	ACC_NATIVE    = 0x0100
+	ACC_ABSTRACT  = 0x0400
+	ACC_SYNTHETIC = 0x1000
+)
 
 type Attribute struct {
 	Name string
@@ -35,76 +28,200 @@ type Field struct {
 	Attributes []Attribute
 }
 
+type ExceptionHandler struct {
+	StartPC   uint16
+	EndPC     uint16
+	HandlerPC uint16
+	CatchType uint16
+}
+
+type Code struct {
+	MaxStack       uint16
+	MaxLocals      uint16
+	Code           []byte
+	ExceptionTable []ExceptionHandler
+	Attributes     []Attribute
+}
+
+type Method struct {
+	Flags      uint16
+	Name       string
+	Descriptor string
+	Code       *Code
+	Attributes []Attribute
+}
+
 type Class struct {
-	constPool  ConstPool
+	constPool  *ConstPool
 	name       string
 	super      string
 	flags      uint16
 	interfaces []string
 	fields     []Field
-	methods    []Field
+	methods    []Method
 	attributes []Attribute
+	// written as BootstrapMethods attribute
+	bootstrapMethods []BootstrapMethod
+	target           Target
 }
 
-func NewClass(name string, super string) *Class {
+func NewClass(name string, super string, target Target) *Class {
 	class := Class{
-		name:       name,
-		super:      super,
-		constPool:  make(ConstPool, 0),
-		flags:      uint16(0),
-		interfaces: make([]string, 0),
-		fields:     make([]Field, 0),
-		methods:    make([]Field, 0),
-		attributes: make([]Attribute, 0),
+		name:             name,
+		super:            super,
+		target:           target,
+		constPool:        NewConstPool(),
+		flags:            ACC_PUBLIC | ACC_SUPER,
+		interfaces:       make([]string, 0),
+		fields:           make([]Field, 0),
+		methods:          make([]Method, 0),
+		attributes:       make([]Attribute, 0),
+		bootstrapMethods: make([]BootstrapMethod, 0),
 	}
 	return &class
 }
 
+func (c *Class) Target() Target {
+	return c.target
+}
+
+func (c *Class) ConstPool() *ConstPool {
+	return c.constPool
+}
+
+func (c *Class) Name() string {
+	return c.name
+}
+
+func (c *Class) Super() string {
+	return c.super
+}
+
+func (c *Class) Methods() []Method {
+	return c.methods
+}
+
+func (c *Class) FindMethod(name, descriptor string) (*Method, bool) {
+	for i := range c.methods {
+		if c.methods[i].Name == name && c.methods[i].Descriptor == descriptor {
+			return &c.methods[i], true
+		}
+	}
+	return nil, false
+}
+
+// AddMethodRef adds a reference to a method, an invalid descriptor is recorded as error of the constant pool
 func (c *Class) AddMethodRef(name, descriptor, class string) uint16 {
-	name_index := c.constPool.AddConst(Const{Tag: 0x01, String: class})
-	class_index := c.constPool.AddConst(Const{Tag: 0x07, NameIndex: name_index})
+	if _, err := ParseMethodDescriptor(descriptor); err != nil {
+		c.constPool.fail("%v (method %v)", strings.TrimPrefix(err.Error(), "error: "), name)
+		return 0
+	}
+	return c.constPool.Methodref(class, name, descriptor)
+}
+
+func (c *Class) AddFieldRef(name, descriptor, class string) uint16 {
+	return c.constPool.Fieldref(class, name, descriptor)
+}
+
+func (c *Class) AddClassRef(name string) uint16 {
+	return c.constPool.Class(name)
+}
+
+func (c *Class) AddString(value string) uint16 {
+	return c.constPool.String(value)
+}
+
+func (c *Class) AddInteger(value int32) uint16 {
+	return c.constPool.Integer(value)
+}
 
-	name_index = c.constPool.AddConst(Const{Tag: 0x01, String: name})
-	desc_index := c.constPool.AddConst(Const{Tag: 0x01, String: descriptor})
-	name_and_type := c.constPool.AddConst(Const{Tag: 0x0c, NameIndex: name_index, DescIndex: desc_index})
+func (c *Class) AddLong(value int64) uint16 {
+	return c.constPool.Long(value)
+}
 
-	methodRefIndex := c.constPool.AddConst(Const{Tag: 0x0a, ClassIndex: class_index, NameAndTypeIndex: name_and_type})
-	return methodRefIndex
+func (c *Class) AddFloat(value float32) uint16 {
+	return c.constPool.Float(value)
 }
 
-func (c *Class) AddMethod(name string, descriptor string, byteCode []byte, maxLocalVariables uint16) {
-	codeData := make([]byte, 0)
-	codeData = binary.BigEndian.AppendUint16(codeData, uint16(0))
-	codeData = binary.BigEndian.AppendUint16(codeData, maxLocalVariables)
-	codeData = binary.BigEndian.AppendUint32(codeData, uint32(0))
-	codeData = append(codeData, byteCode...)
-	codeAttribute := Attribute{Name: "Code", Data: codeData}
-	c.methods = append(c.methods, Field{Flags: uint16(0), Name: name, Descriptor: descriptor, Attributes: []Attribute{codeAttribute}})
+func (c *Class) AddDouble(value float64) uint16 {
+	return c.constPool.Double(value)
+}
+
+func (c *Class) AddMethod(flags uint16, name string, descriptor string, byteCode []byte, maxLocalVariables uint16) error {
+	if len(byteCode) == 0 || len(byteCode) > 0xffff {
+		return fmt.Errorf("error: invalid code length %v for method %v", len(byteCode), name)
+	}
+	code := Code{MaxLocals: maxLocalVariables, Code: byteCode, ExceptionTable: make([]ExceptionHandler, 0), Attributes: make([]Attribute, 0)}
+	method := Method{Flags: flags, Name: name, Descriptor: descriptor, Code: &code, Attributes: make([]Attribute, 0)}
+	erasedDeadCode := false
+	if c.target.SupportsStackMapTable() {
+		stackMapTable, erased, err := c.computeStackMapTable(&method)
+		if err != nil {
+			return fmt.Errorf("error: cannot compute stack map frames of method %v (%v)", name, err)
+		}
+		if stackMapTable != nil {
+			code.Attributes = append(code.Attributes, *stackMapTable)
+		}
+		erasedDeadCode = erased
+	}
+	maxStack, err := c.computeMaxStack(code.Code, code.ExceptionTable)
+	if err != nil {
+		return fmt.Errorf("error: cannot compute max stack of method %v (%v)", name, err)
+	}
+	// erased dead code is verified with a Throwable on the stack
+	if erasedDeadCode && maxStack == 0 {
+		maxStack = 1
+	}
+	code.MaxStack = maxStack
+	c.methods = append(c.methods, method)
+	return nil
 }
 
-func (c *Class) ConvertToBytes() []byte {
+// ConvertToBytes writes the class file, it fails if a constant could not be added to the class
+func (c *Class) ConvertToBytes() ([]byte, error) {
 	classfile := make([]byte, 0)
-	nameIndex := c.constPool.AddConst(Const{Tag: 0x01, String: c.name})
 	//setting the access flags
-	classfile = binary.BigEndian.AppendUint16(classfile, uint16(0))
+	classfile = binary.BigEndian.AppendUint16(classfile, c.flags)
 	//setting the class index for this and the super class
-	classfile = binary.BigEndian.AppendUint16(classfile, nameIndex)
-	nameIndex = c.constPool.AddConst(Const{Tag: 0x01, String: c.super})
-	classfile = binary.BigEndian.AppendUint16(classfile, nameIndex)
-	//setting the length of the interface and field table
-	classfile = binary.BigEndian.AppendUint16(classfile, uint16(0))
-	classfile = binary.BigEndian.AppendUint16(classfile, uint16(0))
+	classfile = binary.BigEndian.AppendUint16(classfile, c.constPool.Class(c.name))
+	if c.super == "" {
+		classfile = binary.BigEndian.AppendUint16(classfile, uint16(0))
+	} else {
+		classfile = binary.BigEndian.AppendUint16(classfile, c.constPool.Class(c.super))
+	}
+	//setting the interface and field table
+	classfile = binary.BigEndian.AppendUint16(classfile, uint16(len(c.interfaces)))
+	for _, i := range c.interfaces {
+		classfile = binary.BigEndian.AppendUint16(classfile, c.constPool.Class(i))
+	}
+	classfile = binary.BigEndian.AppendUint16(classfile, uint16(len(c.fields)))
+	for _, f := range c.fields {
+		classfile = binary.BigEndian.AppendUint16(classfile, f.Flags)
+		classfile = binary.BigEndian.AppendUint16(classfile, c.constPool.Utf8(f.Name))
+		classfile = binary.BigEndian.AppendUint16(classfile, c.constPool.Utf8(f.Descriptor))
+		classfile = append(classfile, c.convertAttributesToBytes(f.Attributes)...)
+	}
 	classfile = binary.BigEndian.AppendUint16(classfile, uint16(len(c.methods)))
 	classfile = append(classfile, c.convertMethodsToBytes()...)
-	classfile = binary.BigEndian.AppendUint16(classfile, uint16(0))
+	attributes := c.attributes
+	if len(c.bootstrapMethods) > 0 {
+		attributes = append(attributes[:len(attributes):len(attributes)], Attribute{Name: BOOTSTRAP_METHODS, Data: c.convertBootstrapMethodsToBytes()})
+	}
+	classfile = append(classfile, c.convertAttributesToBytes(attributes)...)
 	finalClassfile := make([]byte, 0)
-	constPoolLen := len(c.constPool) + 1
-	constPool := c.convertConstPoolToBytes()
-	finalClassfile = binary.BigEndian.AppendUint64(finalClassfile, uint64(0))
+	constPoolLen := c.constPool.Count()
+	constPool, err := c.constPool.convertToBytes()
+	if err != nil {
+		return nil, err
+	}
+	//setting the magic number and the class file version
+	finalClassfile = binary.BigEndian.AppendUint32(finalClassfile, MAGIC)
+	finalClassfile = binary.BigEndian.AppendUint16(finalClassfile, c.target.Minor)
+	finalClassfile = binary.BigEndian.AppendUint16(finalClassfile, c.target.Major)
 	finalClassfile = binary.BigEndian.AppendUint16(finalClassfile, uint16(constPoolLen))
 	finalClassfile = append(finalClassfile, constPool...)
 	finalClassfile = append(finalClassfile, classfile...)
-	return finalClassfile
+	return finalClassfile, nil
 }
 
 func (c *Class) convertMethodsToBytes() []byte {
@@ -112,46 +229,42 @@ func (c *Class) convertMethodsToBytes() []byte {
 	for _, m := range c.methods {
 		methodAsBytes := make([]byte, 0)
 		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, m.Flags)
-		nameIndex := c.constPool.AddConst(Const{Tag: 0x01, String: m.Name})
-		descIndex := c.constPool.AddConst(Const{Tag: 0x01, String: m.Descriptor})
-		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, nameIndex)
-		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, descIndex)
-		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, uint16(len(m.Attributes)))
-		for _, a := range m.Attributes {
-			attributeAsBytes := make([]byte, 0)
-			nameIndex = c.constPool.AddConst(Const{Tag: 0x01, String: a.Name})
-			attributeAsBytes = binary.BigEndian.AppendUint16(attributeAsBytes, nameIndex)
-			attributeAsBytes = binary.BigEndian.AppendUint32(attributeAsBytes, uint32(len(a.Data)))
-			attributeAsBytes = append(attributeAsBytes, a.Data...)
-			methodAsBytes = append(methodAsBytes, attributeAsBytes...)
+		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, c.constPool.Utf8(m.Name))
+		methodAsBytes = binary.BigEndian.AppendUint16(methodAsBytes, c.constPool.Utf8(m.Descriptor))
+		attributes := m.Attributes
+		if m.Code != nil {
+			attributes = append([]Attribute{{Name: "Code", Data: c.convertCodeToBytes(m.Code)}}, attributes...)
 		}
+		methodAsBytes = append(methodAsBytes, c.convertAttributesToBytes(attributes)...)
 		allMethodsAsBytes = append(allMethodsAsBytes, methodAsBytes...)
 	}
 	return allMethodsAsBytes
 }
 
-func (c *Class) convertConstPoolToBytes() []byte {
-	constPoolAsBytes := make([]byte, 0)
-	for _, co := range c.constPool {
-		constAsBytes := make([]byte, 0)
-		constAsBytes = append(constAsBytes, co.Tag)
-		switch co.Tag {
-		case 0x01:
-			valueInBytes := []byte(co.String)
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, uint16(len(valueInBytes)))
-			constAsBytes = append(constAsBytes, valueInBytes...)
-		case 0x07:
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameIndex)
-		case 0x0c:
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameIndex)
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.DescIndex)
-		case 0x0a:
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.ClassIndex)
-			constAsBytes = binary.BigEndian.AppendUint16(constAsBytes, co.NameAndTypeIndex)
-		default:
-			log.Fatalf("error: unsupported const pool tag")
-		}
-		constPoolAsBytes = append(constPoolAsBytes, constAsBytes...)
+func (c *Class) convertCodeToBytes(code *Code) []byte {
+	codeAsBytes := make([]byte, 0)
+	codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, code.MaxStack)
+	codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, code.MaxLocals)
+	codeAsBytes = binary.BigEndian.AppendUint32(codeAsBytes, uint32(len(code.Code)))
+	codeAsBytes = append(codeAsBytes, code.Code...)
+	codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, uint16(len(code.ExceptionTable)))
+	for _, handler := range code.ExceptionTable {
+		codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, handler.StartPC)
+		codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, handler.EndPC)
+		codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, handler.HandlerPC)
+		codeAsBytes = binary.BigEndian.AppendUint16(codeAsBytes, handler.CatchType)
+	}
+	codeAsBytes = append(codeAsBytes, c.convertAttributesToBytes(code.Attributes)...)
+	return codeAsBytes
+}
+
+func (c *Class) convertAttributesToBytes(attributes []Attribute) []byte {
+	attributesAsBytes := make([]byte, 0)
+	attributesAsBytes = binary.BigEndian.AppendUint16(attributesAsBytes, uint16(len(attributes)))
+	for _, a := range attributes {
+		attributesAsBytes = binary.BigEndian.AppendUint16(attributesAsBytes, c.constPool.Utf8(a.Name))
+		attributesAsBytes = binary.BigEndian.AppendUint32(attributesAsBytes, uint32(len(a.Data)))
+		attributesAsBytes = append(attributesAsBytes, a.Data...)
 	}
-	return constPoolAsBytes
+	return attributesAsBytes
 }
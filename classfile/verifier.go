@@ -0,0 +1,320 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"fmt"
+	"strings"
+)
+
+type VerifyError struct {
+	Method  string
+	Offset  int
+	Message string
+}
+
+func (e VerifyError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("error: %v: %v", e.Method, e.Message)
+	}
+	return fmt.Sprintf("error: %v at offset %v: %v", e.Method, e.Offset, e.Message)
+}
+
+type methodVerifier struct {
+	class      *Class
+	method     *Method
+	descriptor MethodDescriptor
+	errors     []VerifyError
+	// the classes created by the new instructions by their offset
+	newClasses map[int]string
+}
+
+func (v *methodVerifier) report(offset int, format string, args ...any) {
+	message := strings.TrimPrefix(fmt.Sprintf(format, args...), "error: ")
+	v.errors = append(v.errors, VerifyError{Method: v.method.Name + v.method.Descriptor, Offset: offset, Message: message})
+}
+
+// Verify type checks the bytecode of every method against its descriptor, the constant pool and the
+// stack map frames, similar to what the jvm does when it loads the class
+func (c *Class) Verify() []VerifyError {
+	errors := make([]VerifyError, 0)
+	for i := range c.methods {
+		v := methodVerifier{class: c, method: &c.methods[i], errors: make([]VerifyError, 0)}
+		v.verify()
+		errors = append(errors, v.errors...)
+	}
+	return errors
+}
+
+func (v *methodVerifier) verify() {
+	md, err := ParseMethodDescriptor(v.method.Descriptor)
+	if err != nil {
+		v.report(-1, "invalid method descriptor '%v'", v.method.Descriptor)
+		return
+	}
+	v.descriptor = md
+	isAbstract := v.method.Flags&(ACC_ABSTRACT|ACC_NATIVE) != 0
+	if v.method.Code == nil {
+		if !isAbstract {
+			v.report(-1, "method has no code")
+		}
+		return
+	}
+	if isAbstract {
+		v.report(-1, "abstract or native method must not have code")
+		return
+	}
+	code := v.method.Code
+	if len(code.Code) == 0 {
+		v.report(-1, "method has empty code")
+		return
+	}
+	insts, err := DecodeInstructions(code.Code)
+	if err != nil {
+		v.report(-1, "%v", err)
+		return
+	}
+	starts := make(map[int]bool)
+	v.newClasses = make(map[int]string)
+	for _, inst := range insts {
+		starts[inst.Offset] = true
+		if inst.Opcode == instructions.NEW {
+			if class, err := v.class.constPool.className(inst.U16()); err == nil {
+				v.newClasses[inst.Offset] = class
+			}
+		}
+	}
+	for _, inst := range insts {
+		v.checkOperands(inst, starts)
+	}
+	for _, handler := range code.ExceptionTable {
+		if !starts[int(handler.StartPC)] || !starts[int(handler.HandlerPC)] || handler.StartPC >= handler.EndPC ||
+			(int(handler.EndPC) != len(code.Code) && !starts[int(handler.EndPC)]) {
+			v.report(-1, "invalid exception handler range %v-%v -> %v", handler.StartPC, handler.EndPC, handler.HandlerPC)
+		}
+		if handler.CatchType != 0 {
+			if _, err := v.class.constPool.className(handler.CatchType); err != nil {
+				v.report(int(handler.HandlerPC), "invalid catch type: %v", err)
+			}
+		}
+	}
+	if len(v.errors) > 0 {
+		return
+	}
+	initial, err := v.initialFrame()
+	if err != nil {
+		v.report(-1, "%v", err)
+		return
+	}
+	frames, ok := v.stackMapFrames(insts, initial)
+	if ok {
+		v.typeCheck(insts, initial, frames)
+	}
+}
+
+// stackMapFrames returns the frames of the StackMapTable attribute or infers them for old class files
+func (v *methodVerifier) stackMapFrames(insts []Instruction, initial Frame) (map[int]Frame, bool) {
+	frames := make(map[int]Frame)
+	for _, attribute := range v.method.Code.Attributes {
+		if attribute.Name != "StackMapTable" {
+			continue
+		}
+		decoded, err := v.class.decodeStackMapTable(attribute.Data, initial)
+		if err != nil {
+			v.report(-1, "%v", err)
+			return nil, false
+		}
+		for _, frame := range decoded {
+			frames[frame.offset] = frame.frame
+		}
+		return frames, true
+	}
+	starts := blockStarts(insts, v.method.Code.ExceptionTable)
+	if len(starts) == 0 {
+		return frames, true
+	}
+	if v.class.target.Major >= 51 {
+		v.report(-1, "missing StackMapTable attribute (required since class file version 51)")
+		return nil, false
+	}
+	inferred, err := v.class.computeFrames(v.method, insts)
+	if err != nil {
+		v.report(-1, "%v", err)
+		return nil, false
+	}
+	for _, offset := range starts {
+		if frame, ok := inferred[offset]; ok {
+			frames[offset] = *frame
+		}
+	}
+	return frames, true
+}
+
+func (v *methodVerifier) typeCheck(insts []Instruction, initial Frame, frames map[int]Frame) {
+	code := v.method.Code
+	current := initial.copy()
+	reachable := true
+	for _, inst := range insts {
+		name := Mnemonic(inst.Opcode)
+		if frame, ok := frames[inst.Offset]; ok {
+			if reachable && !v.isFrameAssignable(current, frame) {
+				v.report(inst.Offset, "current frame %v does not match the stack map frame %v", describeFrame(current), describeFrame(frame))
+				return
+			}
+			current = frame.copy()
+			reachable = true
+		} else if !reachable {
+			v.report(inst.Offset, "missing stack map frame after unconditional branch")
+			return
+		}
+		for _, handler := range code.ExceptionTable {
+			if inst.Offset < int(handler.StartPC) || inst.Offset >= int(handler.EndPC) {
+				continue
+			}
+			target, ok := frames[int(handler.HandlerPC)]
+			if !ok || !v.isFrameAssignable(Frame{Locals: current.Locals, Stack: target.Stack}, target) {
+				v.report(inst.Offset, "locals are not compatible with the frame of the exception handler at %v", handler.HandlerPC)
+				return
+			}
+		}
+		before := current.copy()
+		if err := v.step(inst, &current); err != nil {
+			v.report(inst.Offset, "%v: %v", name, err)
+			return
+		}
+		if depth := stackSlots(current.Stack); depth > int(code.MaxStack) {
+			v.report(inst.Offset, "%v: operand stack depth %v exceeds max_stack %v", name, depth, code.MaxStack)
+			return
+		}
+		if !v.checkReturn(inst, before) {
+			return
+		}
+		for _, target := range inst.Targets {
+			frame, ok := frames[target]
+			if !ok {
+				v.report(inst.Offset, "%v: missing stack map frame at branch target %v", name, target)
+				return
+			}
+			if !v.isFrameAssignable(current, frame) {
+				v.report(inst.Offset, "%v: frame %v does not match the stack map frame %v at branch target %v", name, describeFrame(current), describeFrame(frame), target)
+				return
+			}
+		}
+		reachable = !inst.IsUnconditionalJump()
+	}
+	if reachable {
+		v.report(len(code.Code), "execution falls off the end of the code")
+	}
+}
+
+func (v *methodVerifier) checkReturn(inst Instruction, before Frame) bool {
+	op := inst.Opcode
+	if op < instructions.IRETURN || op > instructions.RETURN {
+		return true
+	}
+	if op == instructions.RETURN {
+		if v.descriptor.ReturnType != "V" {
+			v.report(inst.Offset, "return: method must return a value of type %v", javaTypeName(v.descriptor.ReturnType))
+			return false
+		}
+		return true
+	}
+	if v.descriptor.ReturnType == "V" {
+		v.report(inst.Offset, "%v: void method must not return a value", Mnemonic(op))
+		return false
+	}
+	expected := descriptorType(v.descriptor.ReturnType)
+	returned := before.Stack[len(before.Stack)-1]
+	if !v.isAssignable(returned, expected) {
+		v.report(inst.Offset, "%v: returns %v but the method returns %v", Mnemonic(op), returned, javaTypeName(v.descriptor.ReturnType))
+		return false
+	}
+	return true
+}
+
+// checkOperands validates branch targets, local variable indices and constant pool references
+func (v *methodVerifier) checkOperands(inst Instruction, starts map[int]bool) {
+	op := inst.Opcode
+	name := Mnemonic(op)
+	cp := v.class.constPool
+	for _, target := range inst.Targets {
+		if !starts[target] {
+			v.report(inst.Offset, "%v: jump to %v which is not the start of an instruction", name, target)
+		}
+	}
+	expectTag := func(index uint16, tags ...byte) {
+		c, err := cp.get(index)
+		if err != nil {
+			v.report(inst.Offset, "%v: %v", name, err)
+			return
+		}
+		for _, tag := range tags {
+			if c.Tag == tag {
+				return
+			}
+		}
+		v.report(inst.Offset, "%v: constant pool entry #%v is a %v", name, index, constantNames[c.Tag])
+	}
+	switch {
+	case op == instructions.LDC:
+		expectTag(uint16(inst.U8()), CONSTANT_INTEGER, CONSTANT_FLOAT, CONSTANT_STRING, CONSTANT_CLASS, CONSTANT_METHODHANDLE, CONSTANT_METHODTYPE)
+	case op == instructions.LDC_W:
+		expectTag(inst.U16(), CONSTANT_INTEGER, CONSTANT_FLOAT, CONSTANT_STRING, CONSTANT_CLASS, CONSTANT_METHODHANDLE, CONSTANT_METHODTYPE)
+	case op == instructions.LDC2_W:
+		expectTag(inst.U16(), CONSTANT_LONG, CONSTANT_DOUBLE)
+	case op >= instructions.GETSTATIC && op <= instructions.PUTFIELD:
+		expectTag(inst.U16(), CONSTANT_FIELDREF)
+	case op == instructions.INVOKEVIRTUAL:
+		expectTag(inst.U16(), CONSTANT_METHODREF)
+	case op == instructions.INVOKESPECIAL || op == instructions.INVOKESTATIC:
+		expectTag(inst.U16(), CONSTANT_METHODREF, CONSTANT_INTERFACEMETHODREF)
+	case op == instructions.INVOKEINTERFACE:
+		expectTag(inst.U16(), CONSTANT_INTERFACEMETHODREF)
+		if descriptor, err := cp.memberDescriptor(inst.U16()); err == nil {
+			if md, err := ParseMethodDescriptor(descriptor); err == nil && int(inst.Operands[2]) != md.ArgSlots()+1 {
+				v.report(inst.Offset, "%v: argument count %v does not match the descriptor %v", name, inst.Operands[2], descriptor)
+			}
+		}
+	case op == instructions.INVOKEDYNAMIC:
+		expectTag(inst.U16(), CONSTANT_INVOKEDYNAMIC)
+		if c, err := cp.get(inst.U16()); err == nil && c.Tag == CONSTANT_INVOKEDYNAMIC && int(c.BootstrapMethodAttrIndex) >= len(v.class.bootstrapMethods) {
+			v.report(inst.Offset, "%v: bootstrap method %v does not exist", name, c.BootstrapMethodAttrIndex)
+		}
+		if inst.Operands[2] != 0 || inst.Operands[3] != 0 {
+			v.report(inst.Offset, "%v: the last two operand bytes must be zero", name)
+		}
+	case op == instructions.NEW, op == instructions.ANEWARRAY, op == instructions.CHECKCAST, op == instructions.INSTANCEOF, op == instructions.MULTIANEWARRAY:
+		expectTag(inst.U16(), CONSTANT_CLASS)
+	}
+	if op >= instructions.INVOKEVIRTUAL && op <= instructions.INVOKEDYNAMIC {
+		if descriptor, err := cp.memberDescriptor(inst.U16()); err == nil {
+			if _, err := ParseMethodDescriptor(descriptor); err != nil {
+				v.report(inst.Offset, "%v: %v", name, err)
+			}
+		}
+	}
+	if op >= instructions.GETSTATIC && op <= instructions.PUTFIELD {
+		if descriptor, err := cp.memberDescriptor(inst.U16()); err == nil {
+			if length, err := fieldDescriptorLength(descriptor); err != nil || length != len(descriptor) {
+				v.report(inst.Offset, "%v: invalid field descriptor '%v'", name, descriptor)
+			}
+		}
+	}
+	if instructions.Opcodes[op].CanBeWide() && inst.LocalIndex() >= int(v.method.Code.MaxLocals) {
+		v.report(inst.Offset, "%v: local variable index %v exceeds max_locals %v", name, inst.LocalIndex(), v.method.Code.MaxLocals)
+	}
+}
+
+func stackSlots(stack []VerificationType) int {
+	slots := 0
+	for _, vt := range stack {
+		slots++
+		if vt.IsWide() {
+			slots++
+		}
+	}
+	return slots
+}
+
+func describeFrame(f Frame) string {
+	return fmt.Sprintf("{locals: %v, stack: %v}", entries(f.Locals), f.Stack)
+}
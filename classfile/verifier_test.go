@@ -0,0 +1,115 @@
+package classfile
+
+import (
+	"compiler/instructions"
+	"strings"
+	"testing"
+)
+
+const PRINT_STREAM = "java/io/PrintStream"
+
+// assemble adds a static method to the class, code writes its instructions
+func assemble(t *testing.T, class *Class, name, descriptor string, maxLocals uint16, code func(a *instructions.Assembler)) {
+	t.Helper()
+	a := instructions.NewAssembler()
+	code(a)
+	byteCode, err := a.Assemble()
+	if err != nil {
+		t.Fatalf("cannot assemble %v: %v", name, err)
+	}
+	if err := class.AddMethod(ACC_PUBLIC|ACC_STATIC, name, descriptor, byteCode, maxLocals); err != nil {
+		t.Fatalf("cannot add %v: %v", name, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		code func(c *Class, a *instructions.Assembler)
+		// a part of the expected error message, empty if the method is valid
+		err string
+	}{
+		{"println", func(c *Class, a *instructions.Assembler) {
+			a.Field(instructions.GETSTATIC, c.AddFieldRef("out", "L"+PRINT_STREAM+";", "java/lang/System"), "L"+PRINT_STREAM+";")
+			a.Emit(instructions.LDC, int(c.AddString("hello")))
+			a.Invoke(instructions.INVOKEVIRTUAL, c.AddMethodRef("println", "(Ljava/lang/String;)V", PRINT_STREAM), "(Ljava/lang/String;)V")
+			a.Emit(instructions.RETURN)
+		}, ""},
+		{"string builder", func(c *Class, a *instructions.Assembler) {
+			a.Emit(instructions.NEW, int(c.AddClassRef("java/lang/StringBuilder")))
+			a.Emit(instructions.DUP)
+			a.Invoke(instructions.INVOKESPECIAL, c.AddMethodRef("<init>", "()V", "java/lang/StringBuilder"), "()V")
+			a.Emit(instructions.LCONST_1)
+			a.Invoke(instructions.INVOKEVIRTUAL, c.AddMethodRef("append", "(J)Ljava/lang/StringBuilder;", "java/lang/StringBuilder"), "(J)Ljava/lang/StringBuilder;")
+			a.Emit(instructions.DUP)
+			a.Invoke(instructions.INVOKESTATIC, c.AddMethodRef("equals", "(Ljava/lang/Object;Ljava/lang/Object;)Z", "java/util/Objects"), "(Ljava/lang/Object;Ljava/lang/Object;)Z")
+			a.Emit(instructions.POP)
+			a.Emit(instructions.RETURN)
+		}, ""},
+		{"call of a method of the class", func(c *Class, a *instructions.Assembler) {
+			a.Emit(instructions.ICONST_1)
+			a.Invoke(instructions.INVOKESTATIC, c.AddMethodRef("twice", "(I)I", c.Name()), "(I)I")
+			a.Emit(instructions.POP)
+			a.Emit(instructions.RETURN)
+		}, ""},
+		{"wrong receiver", func(c *Class, a *instructions.Assembler) {
+			a.Emit(instructions.LDC, int(c.AddString("not a print stream")))
+			a.Emit(instructions.LDC, int(c.AddString("hello")))
+			a.Invoke(instructions.INVOKEVIRTUAL, c.AddMethodRef("println", "(Ljava/lang/String;)V", PRINT_STREAM), "(Ljava/lang/String;)V")
+			a.Emit(instructions.RETURN)
+		}, "expected java/io/PrintStream on the stack but found java/lang/String"},
+		{"wrong argument", func(c *Class, a *instructions.Assembler) {
+			a.Field(instructions.GETSTATIC, c.AddFieldRef("out", "L"+PRINT_STREAM+";", "java/lang/System"), "L"+PRINT_STREAM+";")
+			a.Emit(instructions.DUP)
+			a.Invoke(instructions.INVOKEVIRTUAL, c.AddMethodRef("println", "(Ljava/lang/String;)V", PRINT_STREAM), "(Ljava/lang/String;)V")
+			a.Emit(instructions.RETURN)
+		}, "expected java/lang/String on the stack but found java/io/PrintStream"},
+		{"missing method", func(c *Class, a *instructions.Assembler) {
+			a.Invoke(instructions.INVOKESTATIC, c.AddMethodRef("missing", "()V", c.Name()), "()V")
+			a.Emit(instructions.RETURN)
+		}, "method missing()V is not declared in Test"},
+		{"wrong descriptor", func(c *Class, a *instructions.Assembler) {
+			a.Emit(instructions.LCONST_1)
+			a.Invoke(instructions.INVOKESTATIC, c.AddMethodRef("twice", "(J)J", c.Name()), "(J)J")
+			a.Emit(instructions.POP2)
+			a.Emit(instructions.RETURN)
+		}, "method twice is declared as twice(I)I but called as twice(J)J"},
+		{"static method called as instance method", func(c *Class, a *instructions.Assembler) {
+			a.Emit(instructions.ACONST_NULL)
+			a.Emit(instructions.ICONST_1)
+			a.Invoke(instructions.INVOKEVIRTUAL, c.AddMethodRef("twice", "(I)I", c.Name()), "(I)I")
+			a.Emit(instructions.POP)
+			a.Emit(instructions.RETURN)
+		}, "static method twice(I)I must be called with invokestatic"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			class := NewClass("Test", "java/lang/Object", NewTarget(MIN_RELEASE))
+			assemble(t, class, "twice", "(I)I", 1, func(a *instructions.Assembler) {
+				a.Emit(instructions.ILOAD_0)
+				a.Emit(instructions.ICONST_2)
+				a.Emit(instructions.IMUL)
+				a.Emit(instructions.IRETURN)
+			})
+			assemble(t, class, "test", "()V", 0, func(a *instructions.Assembler) { test.code(class, a) })
+			data, err := class.ConvertToBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseClass(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errors := parsed.Verify()
+			if test.err == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected verify errors %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 || !strings.Contains(errors[0].Error(), test.err) {
+				t.Fatalf("expected the verify error '%v' but got %v", test.err, errors)
+			}
+		})
+	}
+}
@@ -1,19 +1,97 @@
 package command
 
 import (
+	"compiler/classfile"
 	"log"
 	"os"
+	"strings"
 )
 
+const (
+	COMPILE = "compile"
+	DISASM  = "disasm"
+	RUN     = "run"
+)
+
+var positionalArgs []string
+var options map[string]string
+var subcommand string = COMPILE
+var booleanOptions map[string]bool = map[string]bool{"verify": true}
+
+func parseArgs() {
+	if options != nil {
+		return
+	}
+	options = make(map[string]string)
+	positionalArgs = make([]string, 0)
+	allArgs := os.Args[1:]
+	for i := 0; i < len(allArgs); i++ {
+		arg := allArgs[i]
+		if !strings.HasPrefix(arg, "--") {
+			positionalArgs = append(positionalArgs, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue && booleanOptions[name] {
+			value = "true"
+		} else if !hasValue {
+			if i+1 >= len(allArgs) {
+				log.Fatalf("error: expected value for option '--%v'", name)
+			}
+			i++
+			value = allArgs[i]
+		}
+		options[name] = value
+	}
+	if len(positionalArgs) > 0 && (positionalArgs[0] == DISASM || positionalArgs[0] == RUN) {
+		subcommand = positionalArgs[0]
+		positionalArgs = positionalArgs[1:]
+	}
+}
+
+func GetSubcommand() string {
+	parseArgs()
+	return subcommand
+}
+
+func GetClassFile() string {
+	parseArgs()
+	if len(positionalArgs) < 1 {
+		log.Fatalf("error: expected class file")
+	}
+	return positionalArgs[0]
+}
+
 func GetSourceFile() string {
-	if len(os.Args) <= 1 {
+	parseArgs()
+	if len(positionalArgs) < 2 {
 		log.Fatalf("error: expected input and output file")
 	}
-	allArgs := os.Args[1:]
-	return allArgs[0]
+	return positionalArgs[0]
 }
 
 func GetOutFile() string {
-	allArgs := os.Args[1:]
-	return allArgs[1]
+	parseArgs()
+	if len(positionalArgs) < 2 {
+		log.Fatalf("error: expected input and output file")
+	}
+	return positionalArgs[1]
+}
+
+func ShouldVerify() bool {
+	parseArgs()
+	return options["verify"] == "true"
+}
+
+func GetTarget() classfile.Target {
+	parseArgs()
+	value, ok := options["target"]
+	if !ok {
+		return classfile.DefaultTarget
+	}
+	target, err := classfile.ParseTarget(value)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return target
 }
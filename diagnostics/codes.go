@@ -0,0 +1,46 @@
+package diagnostics
+
+// error codes, grouped by the phase that reports them
+const (
+	// tokenizer
+	UNRECOGNIZED_TOKEN   = "E0001"
+	UNTERMINATED_COMMENT = "E0002"
+	UNTERMINATED_STRING  = "E0003"
+	INVALID_ESCAPE       = "E0004"
+
+	// parser
+	UNEXPECTED_END_OF_INPUT = "E0100"
+	EXPECTED_TOKEN          = "E0101"
+	EXPECTED_IDENTIFIER     = "E0102"
+	EXPECTED_TYPE           = "E0103"
+	EXPECTED_EXPRESSION     = "E0104"
+	EXPECTED_STATEMENT      = "E0105"
+	NESTED_FUNCTION         = "E0106"
+	DUPLICATE_FUNCTION      = "E0107"
+
+	// warnings
+	UNUSED_DOC_COMMENT = "W0001"
+
+	// semantic analysis
+	UNDECLARED_VARIABLE        = "E0200"
+	REDECLARED_VARIABLE        = "E0201"
+	UNDEFINED_FUNCTION         = "E0202"
+	ARGUMENT_COUNT             = "E0203"
+	TYPE_MISMATCH              = "E0204"
+	UNKNOWN_TYPE               = "E0205"
+	INVALID_NUMBER             = "E0206"
+	UNSUPPORTED                = "E0207"
+	INVALID_MAIN               = "E0208"
+	MISSING_RETURN             = "E0209"
+	BREAK_OUTSIDE_LOOP         = "E0210"
+	UNKNOWN_LABEL              = "E0211"
+	DIVISION_BY_ZERO           = "E0212"
+	STATEMENT_OUTSIDE_FUNCTION = "E0213"
+	STRING_TOO_LONG            = "E0214"
+
+	// class file, like exceeding a limit of the format
+	INVALID_CLASS_FILE = "E0300"
+
+	// problems in the compiler itself, like invalid bytecode
+	INTERNAL_ERROR = "E0900"
+)
@@ -0,0 +1,112 @@
+package diagnostics
+
+import (
+	"compiler/source"
+	"fmt"
+)
+
+type Severity int
+
+const (
+	ERROR Severity = iota
+	WARNING
+	NOTE
+)
+
+func (s Severity) String() string {
+	switch s {
+	case ERROR:
+		return "error"
+	case WARNING:
+		return "warning"
+	}
+	return "note"
+}
+
+// Suggestion proposes to replace the code in Span with Replacement, an empty span inserts it
+type Suggestion struct {
+	Message     string
+	Span        source.Span
+	Replacement string
+}
+
+type Diagnostic struct {
+	Severity    Severity
+	Code        string
+	Message     string
+	Span        source.Span
+	Notes       []string
+	Suggestions []Suggestion
+}
+
+func (d *Diagnostic) Note(format string, args ...any) *Diagnostic {
+	d.Notes = append(d.Notes, fmt.Sprintf(format, args...))
+	return d
+}
+
+func (d *Diagnostic) Suggest(span source.Span, replacement string, format string, args ...any) *Diagnostic {
+	d.Suggestions = append(d.Suggestions, Suggestion{Message: fmt.Sprintf(format, args...), Span: span, Replacement: replacement})
+	return d
+}
+
+func (d Diagnostic) Error() string {
+	message := fmt.Sprintf("%v[%v]: %v", d.Severity, d.Code, d.Message)
+	if d.Span.Start.IsValid() {
+		return fmt.Sprintf("%v: %v", d.Span.Start, message)
+	}
+	return message
+}
+
+// Diagnostics collects the problems every phase of the compiler finds, so they can
+// all be reported at the end instead of stopping at the first one
+type Diagnostics struct {
+	list    []*Diagnostic
+	sources map[string]string
+}
+
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{list: make([]*Diagnostic, 0), sources: make(map[string]string)}
+}
+
+// AddSource registers the content of a file so diagnostics in it can be rendered with a snippet
+func (d *Diagnostics) AddSource(file, content string) {
+	d.sources[file] = content
+}
+
+func (d *Diagnostics) Report(severity Severity, code string, span source.Span, format string, args ...any) *Diagnostic {
+	diagnostic := &Diagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), Span: span,
+		Notes: make([]string, 0), Suggestions: make([]Suggestion, 0)}
+	d.list = append(d.list, diagnostic)
+	return diagnostic
+}
+
+func (d *Diagnostics) Error(code string, span source.Span, format string, args ...any) *Diagnostic {
+	return d.Report(ERROR, code, span, format, args...)
+}
+
+func (d *Diagnostics) Warning(code string, span source.Span, format string, args ...any) *Diagnostic {
+	return d.Report(WARNING, code, span, format, args...)
+}
+
+func (d *Diagnostics) ErrorCount() int {
+	count := 0
+	for _, diagnostic := range d.list {
+		if diagnostic.Severity == ERROR {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *Diagnostics) HasErrors() bool {
+	return d.ErrorCount() > 0
+}
+
+// List returns the reported diagnostics in the order they were found
+func (d *Diagnostics) List() []Diagnostic {
+	list := make([]Diagnostic, 0, len(d.list))
+	for _, diagnostic := range d.list {
+		list = append(list, *diagnostic)
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package diagnostics
+
+import (
+	"compiler/source"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Render prints all diagnostics sorted by their position with a snippet of the source code they point at, similar to rustc:
+//
+//	error[E0101]: expected ';'
+//	 --> main.e:3:1
+//	  |
+//	3 | }
+//	  | ^
+//	help: add ';' here
+//	  |
+//	2 |     let x int = 4;
+//	  |                  +
+func (d *Diagnostics) Render(w io.Writer) {
+	// the checker does not always visit the code in the order it was written
+	sorted := append([]*Diagnostic{}, d.list...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Span.Start, sorted[j].Span.Start
+		if a.IsValid() != b.IsValid() {
+			return a.IsValid()
+		}
+		return a.File < b.File || (a.File == b.File && a.Offset < b.Offset)
+	})
+	for _, diagnostic := range sorted {
+		d.render(w, *diagnostic)
+		fmt.Fprintln(w)
+	}
+}
+
+func (d *Diagnostics) render(w io.Writer, diagnostic Diagnostic) {
+	fmt.Fprintf(w, "%v[%v]: %v\n", diagnostic.Severity, diagnostic.Code, diagnostic.Message)
+	width := len(strconv.Itoa(diagnostic.Span.Start.Line))
+	for _, suggestion := range diagnostic.Suggestions {
+		width = max(width, len(strconv.Itoa(suggestion.Span.Start.Line)))
+	}
+	gutter := strings.Repeat(" ", width)
+	line, ok := d.line(diagnostic.Span.Start)
+	if ok {
+		fmt.Fprintf(w, "%v--> %v\n", gutter, diagnostic.Span.Start)
+		fmt.Fprintf(w, "%v |\n", gutter)
+		fmt.Fprintf(w, "%*v | %v\n", width, diagnostic.Span.Start.Line, line)
+		fmt.Fprintf(w, "%v | %v%v\n", gutter, indentation(line, diagnostic.Span.Start.Column), strings.Repeat("^", markerLength(line, diagnostic.Span)))
+	} else if diagnostic.Span.Start.IsValid() {
+		fmt.Fprintf(w, "%v--> %v\n", gutter, diagnostic.Span.Start)
+	}
+	if len(diagnostic.Notes) > 0 && ok {
+		fmt.Fprintf(w, "%v |\n", gutter)
+	}
+	for _, note := range diagnostic.Notes {
+		fmt.Fprintf(w, "%v = note: %v\n", gutter, note)
+	}
+	for _, suggestion := range diagnostic.Suggestions {
+		d.renderSuggestion(w, suggestion, width)
+	}
+}
+
+func (d *Diagnostics) renderSuggestion(w io.Writer, suggestion Suggestion, width int) {
+	gutter := strings.Repeat(" ", width)
+	span := suggestion.Span
+	line, ok := d.line(span.Start)
+	if !ok || span.End.Line != span.Start.Line {
+		fmt.Fprintf(w, "%v = help: %v: `%v`\n", gutter, suggestion.Message, suggestion.Replacement)
+		return
+	}
+	runes := []rune(line)
+	start := min(span.Start.Column-1, len(runes))
+	end := min(max(span.End.Column-1, start), len(runes))
+	replaced := string(runes[:start]) + suggestion.Replacement + string(runes[end:])
+	marker := "~"
+	if start == end {
+		marker = "+"
+	}
+	fmt.Fprintf(w, "help: %v\n", suggestion.Message)
+	fmt.Fprintf(w, "%v |\n", gutter)
+	fmt.Fprintf(w, "%*v | %v\n", width, span.Start.Line, replaced)
+	fmt.Fprintf(w, "%v | %v%v\n", gutter, indentation(line, span.Start.Column), strings.Repeat(marker, max(len([]rune(suggestion.Replacement)), 1)))
+}
+
+// line returns the source line a position points into
+func (d *Diagnostics) line(pos source.Position) (string, bool) {
+	content, ok := d.sources[pos.File]
+	if !ok || !pos.IsValid() {
+		return "", false
+	}
+	lines := strings.Split(content, "\n")
+	if pos.Line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[pos.Line-1], "\r"), true
+}
+
+// indentation returns the whitespace that moves a marker below the given column, keeping tabs so it lines up
+func indentation(line string, column int) string {
+	indent := ""
+	for i, c := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if c == '\t' {
+			indent += "\t"
+		} else {
+			indent += " "
+		}
+	}
+	return indent
+}
+
+// markerLength returns the amount of carets below a span, spans over multiple lines are marked until the end of the first one
+func markerLength(line string, span source.Span) int {
+	if span.End.Line == span.Start.Line {
+		return max(span.End.Column-span.Start.Column, 1)
+	}
+	return max(len([]rune(line))-span.Start.Column+1, 1)
+}
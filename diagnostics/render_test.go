@@ -0,0 +1,141 @@
+package diagnostics
+
+import (
+	"compiler/source"
+	"strings"
+	"testing"
+)
+
+func at(line, column int) source.Position {
+	return source.Position{File: "main.e", Line: line, Column: column}
+}
+
+func span(startLine, startColumn, endLine, endColumn int) source.Span {
+	return source.Span{Start: at(startLine, startColumn), End: at(endLine, endColumn)}
+}
+
+func TestRender(t *testing.T) {
+	src := "fun main() {\n    let x int = 4\n}\n\tlet y = \"ä\" + z;\nlet a int = 1 +\n    2;\nx\nx\nx\nx\n"
+	tests := []struct {
+		name   string
+		report func(d *Diagnostics)
+		output []string
+	}{
+		{"insert suggestion", func(d *Diagnostics) {
+			d.Error(EXPECTED_TOKEN, span(3, 1, 3, 2), "expected ';'").Suggest(span(2, 18, 2, 18), ";", "add ';' here")
+		}, []string{
+			"error[E0101]: expected ';'",
+			" --> main.e:3:1",
+			"  |",
+			"3 | }",
+			"  | ^",
+			"help: add ';' here",
+			"  |",
+			"2 |     let x int = 4;",
+			"  |                  +",
+		}},
+		{"replace suggestion and notes", func(d *Diagnostics) {
+			d.Error(TYPE_MISMATCH, span(2, 11, 2, 14), "unknown type").
+				Note("first note").
+				Note("second note").
+				Suggest(span(2, 11, 2, 14), "long", "use long")
+		}, []string{
+			"error[E0204]: unknown type",
+			" --> main.e:2:11",
+			"  |",
+			"2 |     let x int = 4",
+			"  |           ^^^",
+			"  |",
+			"  = note: first note",
+			"  = note: second note",
+			"help: use long",
+			"  |",
+			"2 |     let x long = 4",
+			"  |           ~~~~",
+		}},
+		{"tabs and multi-byte runes before the span", func(d *Diagnostics) {
+			d.Error(UNDECLARED_VARIABLE, span(4, 16, 4, 17), "cannot use undeclared variable 'z'")
+		}, []string{
+			"error[E0200]: cannot use undeclared variable 'z'",
+			" --> main.e:4:16",
+			"  |",
+			"4 | \tlet y = \"ä\" + z;",
+			"  | \t              ^",
+		}},
+		{"span over multiple lines", func(d *Diagnostics) {
+			d.Warning(UNUSED_DOC_COMMENT, span(5, 13, 6, 6), "marked until the end of the line")
+		}, []string{
+			"warning[W0001]: marked until the end of the line",
+			" --> main.e:5:13",
+			"  |",
+			"5 | let a int = 1 +",
+			"  |             ^^^",
+		}},
+		{"gutter of a suggestion on a longer line number", func(d *Diagnostics) {
+			d.Error(EXPECTED_TOKEN, span(9, 1, 9, 2), "expected ';'").Suggest(span(10, 2, 10, 2), ";", "add ';' here")
+		}, []string{
+			"error[E0101]: expected ';'",
+			"  --> main.e:9:1",
+			"   |",
+			" 9 | x",
+			"   | ^",
+			"help: add ';' here",
+			"   |",
+			"10 | x;",
+			"   |  +",
+		}},
+		{"suggestion over multiple lines", func(d *Diagnostics) {
+			d.Error(EXPECTED_EXPRESSION, span(5, 15, 6, 6), "expected expression").Suggest(span(5, 15, 6, 6), "+ 2", "write it on one line")
+		}, []string{
+			"error[E0104]: expected expression",
+			" --> main.e:5:15",
+			"  |",
+			"5 | let a int = 1 +",
+			"  |               ^",
+			"  = help: write it on one line: `+ 2`",
+		}},
+		{"without source", func(d *Diagnostics) {
+			d.Error(INVALID_CLASS_FILE, source.Span{}, "too many constants").Note("split the program")
+			d.Error(UNKNOWN_TYPE, source.Span{Start: source.Position{File: "other.e", Line: 1, Column: 1}}, "unknown type")
+		}, []string{
+			"error[E0205]: unknown type",
+			" --> other.e:1:1",
+			"",
+			"error[E0300]: too many constants",
+			"  = note: split the program",
+		}},
+		{"sorted by position", func(d *Diagnostics) {
+			second := span(7, 1, 7, 2)
+			second.Start.Offset = 50
+			d.Error(UNDECLARED_VARIABLE, second, "second")
+			first := span(2, 9, 2, 10)
+			first.Start.Offset = 20
+			d.Error(UNDECLARED_VARIABLE, first, "first")
+		}, []string{
+			"error[E0200]: first",
+			" --> main.e:2:9",
+			"  |",
+			"2 |     let x int = 4",
+			"  |         ^",
+			"",
+			"error[E0200]: second",
+			" --> main.e:7:1",
+			"  |",
+			"7 | x",
+			"  | ^",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diags := NewDiagnostics()
+			diags.AddSource("main.e", src)
+			test.report(diags)
+			var output strings.Builder
+			diags.Render(&output)
+			expected := strings.Join(test.output, "\n") + "\n\n"
+			if output.String() != expected {
+				t.Errorf("expected the output\n%v\nbut got\n%v", expected, output.String())
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"compiler/classfile"
+	"compiler/diagnostics"
 	"compiler/parser"
 )
 
@@ -13,11 +14,15 @@ func NewGenerator(program parser.Program) Generator {
 	return Generator{programAsAST: program}
 }
 
-func (g Generator) GenerateByteCode(class *classfile.Class) {
-	maxLocals := 0
-	var variables map[string]parser.Variable = make(map[string]parser.Variable)
-	genContext := parser.GeneratorContext{Class: class, MaxLocals: &maxLocals, Variables: variables}
+// GenerateByteCode adds the functions of the program to the class, the program has to be annotated by the checker
+func (g Generator) GenerateByteCode(class *classfile.Class, diags *diagnostics.Diagnostics) {
+	genContext := parser.GeneratorContext{Class: class, Diagnostics: diags}
 	for _, stmt := range g.programAsAST.Statements {
+		// the checker reports statements outside of functions, every function starts its own code
+		if _, ok := stmt.(parser.FunctionDefinition); !ok {
+			diags.Error(diagnostics.INTERNAL_ERROR, stmt.GetSpan(), "statement outside of a function")
+			continue
+		}
 		stmt.GenerateByteCode(&genContext)
 	}
 }
@@ -0,0 +1,429 @@
+package instructions
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Label is a position in the code of an Assembler, jumps can use it before it is marked
+type Label int
+
+type label struct {
+	// index of the instruction the label points to, -1 until it is marked
+	position int
+	// stack depth at the label, -1 until the first jump to it or its mark
+	stack int
+	// whether a jump to the label was emitted
+	used bool
+}
+
+type instruction struct {
+	op       byte
+	operands []byte
+	wide     bool
+	isJump   bool
+	target   Label
+	// jumps that are too far for a 16 bit offset are promoted to goto_w
+	far bool
+}
+
+// Assembler collects the instructions of a method. Jumps are resolved once all labels are marked,
+// so code can jump forward to labels whose position is not known yet. Local variable instructions
+// use the short and wide forms when possible or needed and the depth of the stack is tracked
+type Assembler struct {
+	insts     []instruction
+	labels    []label
+	stack     int
+	maxStack  int
+	reachable bool
+	err       error
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{insts: make([]instruction, 0), labels: make([]label, 0), reachable: true}
+}
+
+func (a *Assembler) fail(format string, args ...any) {
+	if a.err == nil {
+		a.err = fmt.Errorf("error: "+format, args...)
+	}
+}
+
+// Stack returns the current depth of the operand stack in slots
+func (a *Assembler) Stack() int {
+	return a.stack
+}
+
+func (a *Assembler) MaxStack() int {
+	return a.maxStack
+}
+
+// Reachable reports whether execution can reach the next emitted instruction
+func (a *Assembler) Reachable() bool {
+	return a.reachable
+}
+
+// Emit appends an instruction. The operands are local variable indices, constants or constant pool
+// indices, depending on the instruction. Jumps are emitted with Jump, invokes with Invoke and
+// field instructions with Field because their effect on the stack depends on a descriptor
+func (a *Assembler) Emit(op byte, operands ...int) {
+	inst, err := encode(op, operands)
+	if err != nil {
+		a.fail("%v", err)
+		return
+	}
+	pop, push := Opcodes[op].Pop, Opcodes[op].Push
+	if op == MULTIANEWARRAY {
+		// the dimensions are popped from the stack
+		pop = operands[1]
+	}
+	a.add(inst, pop, push)
+}
+
+// Invoke appends an invoke instruction for a method with the given descriptor
+func (a *Assembler) Invoke(op byte, index uint16, descriptor string) {
+	args, ret, err := descriptorSlots(descriptor)
+	if err != nil {
+		a.fail("%v", err)
+		return
+	}
+	inst := instruction{op: op, operands: binary.BigEndian.AppendUint16(nil, index)}
+	switch op {
+	case INVOKEVIRTUAL, INVOKESPECIAL:
+		args++
+	case INVOKEINTERFACE:
+		args++
+		inst.operands = append(inst.operands, uint8(args), 0)
+	case INVOKEDYNAMIC:
+		inst.operands = append(inst.operands, 0, 0)
+	case INVOKESTATIC:
+	default:
+		a.fail("opcode 0x%x is not an invoke instruction", op)
+		return
+	}
+	a.add(inst, args, ret)
+}
+
+// Field appends a get or put instruction for a field with the given descriptor
+func (a *Assembler) Field(op byte, index uint16, descriptor string) {
+	_, size, err := descriptorSlots("()" + descriptor)
+	if err != nil {
+		a.fail("%v", err)
+		return
+	}
+	inst := instruction{op: op, operands: binary.BigEndian.AppendUint16(nil, index)}
+	switch op {
+	case GETSTATIC:
+		a.add(inst, 0, size)
+	case PUTSTATIC:
+		a.add(inst, size, 0)
+	case GETFIELD:
+		a.add(inst, 1, size)
+	case PUTFIELD:
+		a.add(inst, 1+size, 0)
+	default:
+		a.fail("opcode 0x%x is not a field instruction", op)
+	}
+}
+
+func (a *Assembler) NewLabel() Label {
+	a.labels = append(a.labels, label{position: -1, stack: -1})
+	return Label(len(a.labels) - 1)
+}
+
+// Mark places a label before the next emitted instruction
+func (a *Assembler) Mark(l Label) {
+	target := &a.labels[l]
+	if target.position != -1 {
+		a.fail("label %v is marked twice", l)
+		return
+	}
+	target.position = len(a.insts)
+	if !a.reachable && target.stack != -1 {
+		a.stack = target.stack
+	} else if !a.reachable {
+		a.stack = 0
+	}
+	// code after an unconditional jump is only reachable through a jump to the label
+	a.reachable = a.reachable || target.used
+	a.join(l)
+}
+
+// Jump appends a jump to a label, jumps that do not fit into 16 bits are promoted to goto_w
+func (a *Assembler) Jump(op byte, l Label) {
+	opcode := Opcodes[op]
+	// jsr pushes a return address, which the verifier of current class files rejects
+	if (opcode.Operands != BRANCH && opcode.Operands != BRANCH_W) || op == JSR || op == JSR_W {
+		a.fail("opcode 0x%x is not a jump", op)
+		return
+	}
+	a.add(instruction{op: op, isJump: true, target: l}, opcode.Pop, opcode.Push)
+	a.join(l)
+	a.labels[l].used = true
+}
+
+// join records the current stack depth at a label, every path to a label has to have the same depth
+func (a *Assembler) join(l Label) {
+	target := &a.labels[l]
+	if target.stack == -1 {
+		target.stack = a.stack
+	} else if target.stack != a.stack {
+		a.fail("stack depth %v at label %v differs from %v", a.stack, l, target.stack)
+	}
+}
+
+func (a *Assembler) add(inst instruction, pop, push int) {
+	if a.stack < pop {
+		a.fail("stack underflow at instruction %v (opcode 0x%x)", len(a.insts), inst.op)
+	}
+	a.stack = max(a.stack-pop, 0) + push
+	a.maxStack = max(a.maxStack, a.stack)
+	a.insts = append(a.insts, inst)
+	if Opcodes[inst.op].Unconditional {
+		a.reachable = false
+	}
+}
+
+// Assemble resolves all jumps and returns the bytecode
+func (a *Assembler) Assemble() ([]byte, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	for i, l := range a.labels {
+		if l.used && l.position == -1 {
+			return nil, fmt.Errorf("error: jump to label %v that is never marked", i)
+		}
+	}
+	offsets := a.layout()
+	for changed := true; changed; {
+		changed = false
+		for i, inst := range a.insts {
+			if !inst.isJump || inst.far {
+				continue
+			}
+			offset := offsets[a.labels[inst.target].position] - offsets[i]
+			if offset > math.MaxInt16 || offset < math.MinInt16 {
+				a.insts[i].far = true
+				changed = true
+			}
+		}
+		// promoting a jump moves the following code, which can push other jumps out of range
+		offsets = a.layout()
+	}
+	code := make([]byte, 0, offsets[len(a.insts)])
+	for i, inst := range a.insts {
+		switch {
+		case inst.isJump:
+			offset := offsets[a.labels[inst.target].position] - offsets[i]
+			if !inst.far {
+				code = binary.BigEndian.AppendUint16(append(code, inst.op), uint16(int16(offset)))
+			} else if inst.op == GOTO || inst.op == GOTO_W {
+				code = binary.BigEndian.AppendUint32(append(code, GOTO_W), uint32(int32(offset)))
+			} else {
+				// the negated jump skips the goto_w that follows it
+				code = binary.BigEndian.AppendUint16(append(code, Negate(inst.op)), 8)
+				code = binary.BigEndian.AppendUint32(append(code, GOTO_W), uint32(int32(offset-3)))
+			}
+		case inst.wide:
+			code = append(append(code, WIDE, inst.op), inst.operands...)
+		default:
+			code = append(append(code, inst.op), inst.operands...)
+		}
+	}
+	return code, nil
+}
+
+// layout returns the offset of every instruction and the length of the code as the last element
+func (a *Assembler) layout() []int {
+	offsets := make([]int, 0, len(a.insts)+1)
+	offset := 0
+	for _, inst := range a.insts {
+		offsets = append(offsets, offset)
+		switch {
+		case inst.isJump && inst.far && (inst.op == GOTO || inst.op == GOTO_W):
+			offset += 5
+		case inst.isJump && inst.far:
+			offset += 8
+		case inst.isJump:
+			offset += 3
+		case inst.wide:
+			offset += 2 + len(inst.operands)
+		default:
+			offset += 1 + len(inst.operands)
+		}
+	}
+	return append(offsets, offset)
+}
+
+// Negate returns the conditional jump that jumps in exactly the cases the given one does not
+func Negate(op byte) byte {
+	switch {
+	case op >= IFEQ && op <= IF_ACMPNE:
+		// the conditions come in pairs of a condition and its negation
+		return IFEQ + ((op - IFEQ) ^ 1)
+	case op == IFNULL:
+		return IFNONNULL
+	case op == IFNONNULL:
+		return IFNULL
+	}
+	return op
+}
+
+// encode checks the operands of an instruction and picks its short or wide form
+func encode(op byte, operands []int) (instruction, error) {
+	inst := instruction{op: op}
+	opcode, ok := Lookup(op)
+	if !ok {
+		return inst, fmt.Errorf("unknown opcode 0x%x", op)
+	}
+	expected := 1
+	switch opcode.Operands {
+	case NO_OPERANDS:
+		expected = 0
+	case LOCAL_CONSTANT, MULTI_ARRAY:
+		expected = 2
+	case BRANCH, BRANCH_W, SWITCH, WIDE_PREFIX, INTERFACE_CALL, DYNAMIC_CALL:
+		return inst, fmt.Errorf("%v cannot be emitted directly", opcode.Mnemonic)
+	}
+	if opcode.Operands == CONSTANT && opcode.Pop == VARIABLE_EFFECT {
+		return inst, fmt.Errorf("%v cannot be emitted directly", opcode.Mnemonic)
+	}
+	if len(operands) != expected {
+		return inst, fmt.Errorf("%v takes %v operands, got %v", opcode.Mnemonic, expected, len(operands))
+	}
+	switch opcode.Operands {
+	case LOCAL:
+		index := operands[0]
+		if index < 0 || index > math.MaxUint16 {
+			return inst, fmt.Errorf("invalid local variable index %v", index)
+		}
+		if short, ok := shortForm(op, index); ok {
+			inst.op = short
+		} else if index > math.MaxUint8 {
+			inst.wide = true
+			inst.operands = binary.BigEndian.AppendUint16(nil, uint16(index))
+		} else {
+			inst.operands = []byte{uint8(index)}
+		}
+	case LOCAL_CONSTANT:
+		index, amount := operands[0], operands[1]
+		if index < 0 || index > math.MaxUint16 || amount < math.MinInt16 || amount > math.MaxInt16 {
+			return inst, fmt.Errorf("invalid %v operands %v, %v", opcode.Mnemonic, index, amount)
+		}
+		if index > math.MaxUint8 || amount < math.MinInt8 || amount > math.MaxInt8 {
+			inst.wide = true
+			inst.operands = binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, uint16(index)), uint16(int16(amount)))
+		} else {
+			inst.operands = []byte{uint8(index), uint8(int8(amount))}
+		}
+	case BYTE:
+		if operands[0] < math.MinInt8 || operands[0] > math.MaxInt8 {
+			return inst, fmt.Errorf("%v operand %v out of range", opcode.Mnemonic, operands[0])
+		}
+		inst.operands = []byte{uint8(int8(operands[0]))}
+	case SHORT:
+		if operands[0] < math.MinInt16 || operands[0] > math.MaxInt16 {
+			return inst, fmt.Errorf("%v operand %v out of range", opcode.Mnemonic, operands[0])
+		}
+		inst.operands = binary.BigEndian.AppendUint16(nil, uint16(int16(operands[0])))
+	case ARRAY_TYPE:
+		if operands[0] < 4 || operands[0] > 11 {
+			return inst, fmt.Errorf("invalid array type %v", operands[0])
+		}
+		inst.operands = []byte{uint8(operands[0])}
+	case CONSTANT_U1, CONSTANT, MULTI_ARRAY:
+		if operands[0] < 1 || operands[0] > math.MaxUint16 {
+			return inst, fmt.Errorf("invalid constant pool index %v", operands[0])
+		}
+		// ldc can only address the first 256 entries of the constant pool
+		if opcode.Operands == CONSTANT_U1 && operands[0] <= math.MaxUint8 {
+			inst.operands = []byte{uint8(operands[0])}
+			break
+		}
+		if opcode.Operands == CONSTANT_U1 {
+			inst.op = LDC_W
+		}
+		inst.operands = binary.BigEndian.AppendUint16(nil, uint16(operands[0]))
+		if opcode.Operands == MULTI_ARRAY {
+			if operands[1] < 1 || operands[1] > math.MaxUint8 {
+				return inst, fmt.Errorf("invalid array dimensions %v", operands[1])
+			}
+			inst.operands = append(inst.operands, uint8(operands[1]))
+		}
+	}
+	return inst, nil
+}
+
+// shortForm returns the load or store instruction that encodes the local variable index in its opcode
+func shortForm(op byte, index int) (byte, bool) {
+	if index > 3 {
+		return 0, false
+	}
+	// the short forms come in groups of four for every type, in the same order as the long forms
+	switch {
+	case op >= ILOAD && op <= ALOAD:
+		return ILOAD_0 + (op-ILOAD)*4 + byte(index), true
+	case op >= ISTORE && op <= ASTORE:
+		return ISTORE_0 + (op-ISTORE)*4 + byte(index), true
+	}
+	return 0, false
+}
+
+// descriptorSlots returns the amount of slots the arguments and the return value of a method descriptor take
+func descriptorSlots(descriptor string) (int, int, error) {
+	if len(descriptor) == 0 || descriptor[0] != '(' {
+		return 0, 0, fmt.Errorf("invalid method descriptor %v", descriptor)
+	}
+	args := 0
+	i := 1
+	for i < len(descriptor) && descriptor[i] != ')' {
+		size, length := fieldSlots(descriptor[i:])
+		if length == 0 {
+			return 0, 0, fmt.Errorf("invalid method descriptor %v", descriptor)
+		}
+		args += size
+		i += length
+	}
+	if i+1 >= len(descriptor) {
+		return 0, 0, fmt.Errorf("invalid method descriptor %v", descriptor)
+	}
+	if descriptor[i+1:] == "V" {
+		return args, 0, nil
+	}
+	size, length := fieldSlots(descriptor[i+1:])
+	if length != len(descriptor)-i-1 {
+		return 0, 0, fmt.Errorf("invalid method descriptor %v", descriptor)
+	}
+	return args, size, nil
+}
+
+// fieldSlots returns the slots of the field descriptor at the start of a string and its length, the length is 0 if it is invalid
+func fieldSlots(descriptor string) (int, int) {
+	dimensions := 0
+	for dimensions < len(descriptor) && descriptor[dimensions] == '[' {
+		dimensions++
+	}
+	if dimensions == len(descriptor) {
+		return 0, 0
+	}
+	switch descriptor[dimensions] {
+	case 'B', 'C', 'F', 'I', 'S', 'Z':
+		if dimensions > 0 {
+			return 1, dimensions + 1
+		}
+		return 1, 1
+	case 'J', 'D':
+		if dimensions > 0 {
+			return 1, dimensions + 1
+		}
+		return 2, 1
+	case 'L':
+		for i := dimensions; i < len(descriptor); i++ {
+			if descriptor[i] == ';' {
+				return 1, i + 1
+			}
+		}
+	}
+	return 0, 0
+}
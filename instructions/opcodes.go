@@ -0,0 +1,296 @@
+package instructions
+
+// OperandKind describes the layout of the operand bytes that follow an opcode
+type OperandKind int
+
+const (
+	NO_OPERANDS OperandKind = iota
+	// u1 local variable index, u2 after wide
+	LOCAL
+	// u1 local variable index and s1 constant, u2 and s2 after wide
+	LOCAL_CONSTANT
+	// s1 constant
+	BYTE
+	// s2 constant
+	SHORT
+	// u1 constant pool index
+	CONSTANT_U1
+	// u2 constant pool index
+	CONSTANT
+	// u1 primitive array type
+	ARRAY_TYPE
+	// s2 branch offset
+	BRANCH
+	// s4 branch offset
+	BRANCH_W
+	// u2 constant pool index, u1 count of argument slots and a zero byte
+	INTERFACE_CALL
+	// u2 constant pool index and two zero bytes
+	DYNAMIC_CALL
+	// u2 constant pool index and u1 dimensions
+	MULTI_ARRAY
+	// padding to a multiple of 4 followed by a jump table of variable length
+	SWITCH
+	// the opcode of the widened instruction
+	WIDE_PREFIX
+)
+
+// VARIABLE_EFFECT is the stack effect of instructions whose effect depends on a descriptor or an operand
+const VARIABLE_EFFECT = -1
+
+type Opcode struct {
+	Mnemonic string
+	Operands OperandKind
+	// slots popped from and pushed onto the operand stack
+	Pop, Push int
+	// execution never continues with the following instruction
+	Unconditional bool
+}
+
+// Opcodes describes every instruction of the jvm, the entries of unused opcodes are empty
+var Opcodes [256]Opcode = [256]Opcode{
+	NOP:             {"nop", NO_OPERANDS, 0, 0, false},
+	ACONST_NULL:     {"aconst_null", NO_OPERANDS, 0, 1, false},
+	ICONST_M1:       {"iconst_m1", NO_OPERANDS, 0, 1, false},
+	ICONST_0:        {"iconst_0", NO_OPERANDS, 0, 1, false},
+	ICONST_1:        {"iconst_1", NO_OPERANDS, 0, 1, false},
+	ICONST_2:        {"iconst_2", NO_OPERANDS, 0, 1, false},
+	ICONST_3:        {"iconst_3", NO_OPERANDS, 0, 1, false},
+	ICONST_4:        {"iconst_4", NO_OPERANDS, 0, 1, false},
+	ICONST_5:        {"iconst_5", NO_OPERANDS, 0, 1, false},
+	LCONST_0:        {"lconst_0", NO_OPERANDS, 0, 2, false},
+	LCONST_1:        {"lconst_1", NO_OPERANDS, 0, 2, false},
+	FCONST_0:        {"fconst_0", NO_OPERANDS, 0, 1, false},
+	FCONST_1:        {"fconst_1", NO_OPERANDS, 0, 1, false},
+	FCONST_2:        {"fconst_2", NO_OPERANDS, 0, 1, false},
+	DCONST_0:        {"dconst_0", NO_OPERANDS, 0, 2, false},
+	DCONST_1:        {"dconst_1", NO_OPERANDS, 0, 2, false},
+	BIPUSH:          {"bipush", BYTE, 0, 1, false},
+	SIPUSH:          {"sipush", SHORT, 0, 1, false},
+	LDC:             {"ldc", CONSTANT_U1, 0, 1, false},
+	LDC_W:           {"ldc_w", CONSTANT, 0, 1, false},
+	LDC2_W:          {"ldc2_w", CONSTANT, 0, 2, false},
+	ILOAD:           {"iload", LOCAL, 0, 1, false},
+	LLOAD:           {"lload", LOCAL, 0, 2, false},
+	FLOAD:           {"fload", LOCAL, 0, 1, false},
+	DLOAD:           {"dload", LOCAL, 0, 2, false},
+	ALOAD:           {"aload", LOCAL, 0, 1, false},
+	ILOAD_0:         {"iload_0", NO_OPERANDS, 0, 1, false},
+	ILOAD_1:         {"iload_1", NO_OPERANDS, 0, 1, false},
+	ILOAD_2:         {"iload_2", NO_OPERANDS, 0, 1, false},
+	ILOAD_3:         {"iload_3", NO_OPERANDS, 0, 1, false},
+	LLOAD_0:         {"lload_0", NO_OPERANDS, 0, 2, false},
+	LLOAD_1:         {"lload_1", NO_OPERANDS, 0, 2, false},
+	LLOAD_2:         {"lload_2", NO_OPERANDS, 0, 2, false},
+	LLOAD_3:         {"lload_3", NO_OPERANDS, 0, 2, false},
+	FLOAD_0:         {"fload_0", NO_OPERANDS, 0, 1, false},
+	FLOAD_1:         {"fload_1", NO_OPERANDS, 0, 1, false},
+	FLOAD_2:         {"fload_2", NO_OPERANDS, 0, 1, false},
+	FLOAD_3:         {"fload_3", NO_OPERANDS, 0, 1, false},
+	DLOAD_0:         {"dload_0", NO_OPERANDS, 0, 2, false},
+	DLOAD_1:         {"dload_1", NO_OPERANDS, 0, 2, false},
+	DLOAD_2:         {"dload_2", NO_OPERANDS, 0, 2, false},
+	DLOAD_3:         {"dload_3", NO_OPERANDS, 0, 2, false},
+	ALOAD_0:         {"aload_0", NO_OPERANDS, 0, 1, false},
+	ALOAD_1:         {"aload_1", NO_OPERANDS, 0, 1, false},
+	ALOAD_2:         {"aload_2", NO_OPERANDS, 0, 1, false},
+	ALOAD_3:         {"aload_3", NO_OPERANDS, 0, 1, false},
+	IALOAD:          {"iaload", NO_OPERANDS, 2, 1, false},
+	LALOAD:          {"laload", NO_OPERANDS, 2, 2, false},
+	FALOAD:          {"faload", NO_OPERANDS, 2, 1, false},
+	DALOAD:          {"daload", NO_OPERANDS, 2, 2, false},
+	AALOAD:          {"aaload", NO_OPERANDS, 2, 1, false},
+	BALOAD:          {"baload", NO_OPERANDS, 2, 1, false},
+	CALOAD:          {"caload", NO_OPERANDS, 2, 1, false},
+	SALOAD:          {"saload", NO_OPERANDS, 2, 1, false},
+	ISTORE:          {"istore", LOCAL, 1, 0, false},
+	LSTORE:          {"lstore", LOCAL, 2, 0, false},
+	FSTORE:          {"fstore", LOCAL, 1, 0, false},
+	DSTORE:          {"dstore", LOCAL, 2, 0, false},
+	ASTORE:          {"astore", LOCAL, 1, 0, false},
+	ISTORE_0:        {"istore_0", NO_OPERANDS, 1, 0, false},
+	ISTORE_1:        {"istore_1", NO_OPERANDS, 1, 0, false},
+	ISTORE_2:        {"istore_2", NO_OPERANDS, 1, 0, false},
+	ISTORE_3:        {"istore_3", NO_OPERANDS, 1, 0, false},
+	LSTORE_0:        {"lstore_0", NO_OPERANDS, 2, 0, false},
+	LSTORE_1:        {"lstore_1", NO_OPERANDS, 2, 0, false},
+	LSTORE_2:        {"lstore_2", NO_OPERANDS, 2, 0, false},
+	LSTORE_3:        {"lstore_3", NO_OPERANDS, 2, 0, false},
+	FSTORE_0:        {"fstore_0", NO_OPERANDS, 1, 0, false},
+	FSTORE_1:        {"fstore_1", NO_OPERANDS, 1, 0, false},
+	FSTORE_2:        {"fstore_2", NO_OPERANDS, 1, 0, false},
+	FSTORE_3:        {"fstore_3", NO_OPERANDS, 1, 0, false},
+	DSTORE_0:        {"dstore_0", NO_OPERANDS, 2, 0, false},
+	DSTORE_1:        {"dstore_1", NO_OPERANDS, 2, 0, false},
+	DSTORE_2:        {"dstore_2", NO_OPERANDS, 2, 0, false},
+	DSTORE_3:        {"dstore_3", NO_OPERANDS, 2, 0, false},
+	ASTORE_0:        {"astore_0", NO_OPERANDS, 1, 0, false},
+	ASTORE_1:        {"astore_1", NO_OPERANDS, 1, 0, false},
+	ASTORE_2:        {"astore_2", NO_OPERANDS, 1, 0, false},
+	ASTORE_3:        {"astore_3", NO_OPERANDS, 1, 0, false},
+	IASTORE:         {"iastore", NO_OPERANDS, 3, 0, false},
+	LASTORE:         {"lastore", NO_OPERANDS, 4, 0, false},
+	FASTORE:         {"fastore", NO_OPERANDS, 3, 0, false},
+	DASTORE:         {"dastore", NO_OPERANDS, 4, 0, false},
+	AASTORE:         {"aastore", NO_OPERANDS, 3, 0, false},
+	BASTORE:         {"bastore", NO_OPERANDS, 3, 0, false},
+	CASTORE:         {"castore", NO_OPERANDS, 3, 0, false},
+	SASTORE:         {"sastore", NO_OPERANDS, 3, 0, false},
+	POP:             {"pop", NO_OPERANDS, 1, 0, false},
+	POP2:            {"pop2", NO_OPERANDS, 2, 0, false},
+	DUP:             {"dup", NO_OPERANDS, 1, 2, false},
+	DUP_X1:          {"dup_x1", NO_OPERANDS, 2, 3, false},
+	DUP_X2:          {"dup_x2", NO_OPERANDS, 3, 4, false},
+	DUP2:            {"dup2", NO_OPERANDS, 2, 4, false},
+	DUP2_X1:         {"dup2_x1", NO_OPERANDS, 3, 5, false},
+	DUP2_X2:         {"dup2_x2", NO_OPERANDS, 4, 6, false},
+	SWAP:            {"swap", NO_OPERANDS, 2, 2, false},
+	IADD:            {"iadd", NO_OPERANDS, 2, 1, false},
+	LADD:            {"ladd", NO_OPERANDS, 4, 2, false},
+	FADD:            {"fadd", NO_OPERANDS, 2, 1, false},
+	DADD:            {"dadd", NO_OPERANDS, 4, 2, false},
+	ISUB:            {"isub", NO_OPERANDS, 2, 1, false},
+	LSUB:            {"lsub", NO_OPERANDS, 4, 2, false},
+	FSUB:            {"fsub", NO_OPERANDS, 2, 1, false},
+	DSUB:            {"dsub", NO_OPERANDS, 4, 2, false},
+	IMUL:            {"imul", NO_OPERANDS, 2, 1, false},
+	LMUL:            {"lmul", NO_OPERANDS, 4, 2, false},
+	FMUL:            {"fmul", NO_OPERANDS, 2, 1, false},
+	DMUL:            {"dmul", NO_OPERANDS, 4, 2, false},
+	IDIV:            {"idiv", NO_OPERANDS, 2, 1, false},
+	LDIV:            {"ldiv", NO_OPERANDS, 4, 2, false},
+	FDIV:            {"fdiv", NO_OPERANDS, 2, 1, false},
+	DDIV:            {"ddiv", NO_OPERANDS, 4, 2, false},
+	IREM:            {"irem", NO_OPERANDS, 2, 1, false},
+	LREM:            {"lrem", NO_OPERANDS, 4, 2, false},
+	FREM:            {"frem", NO_OPERANDS, 2, 1, false},
+	DREM:            {"drem", NO_OPERANDS, 4, 2, false},
+	INEG:            {"ineg", NO_OPERANDS, 1, 1, false},
+	LNEG:            {"lneg", NO_OPERANDS, 2, 2, false},
+	FNEG:            {"fneg", NO_OPERANDS, 1, 1, false},
+	DNEG:            {"dneg", NO_OPERANDS, 2, 2, false},
+	ISHL:            {"ishl", NO_OPERANDS, 2, 1, false},
+	LSHL:            {"lshl", NO_OPERANDS, 3, 2, false},
+	ISHR:            {"ishr", NO_OPERANDS, 2, 1, false},
+	LSHR:            {"lshr", NO_OPERANDS, 3, 2, false},
+	IUSHR:           {"iushr", NO_OPERANDS, 2, 1, false},
+	LUSHR:           {"lushr", NO_OPERANDS, 3, 2, false},
+	IAND:            {"iand", NO_OPERANDS, 2, 1, false},
+	LAND:            {"land", NO_OPERANDS, 4, 2, false},
+	IOR:             {"ior", NO_OPERANDS, 2, 1, false},
+	LOR:             {"lor", NO_OPERANDS, 4, 2, false},
+	IXOR:            {"ixor", NO_OPERANDS, 2, 1, false},
+	LXOR:            {"lxor", NO_OPERANDS, 4, 2, false},
+	IINC:            {"iinc", LOCAL_CONSTANT, 0, 0, false},
+	I2L:             {"i2l", NO_OPERANDS, 1, 2, false},
+	I2F:             {"i2f", NO_OPERANDS, 1, 1, false},
+	I2D:             {"i2d", NO_OPERANDS, 1, 2, false},
+	L2I:             {"l2i", NO_OPERANDS, 2, 1, false},
+	L2F:             {"l2f", NO_OPERANDS, 2, 1, false},
+	L2D:             {"l2d", NO_OPERANDS, 2, 2, false},
+	F2I:             {"f2i", NO_OPERANDS, 1, 1, false},
+	F2L:             {"f2l", NO_OPERANDS, 1, 2, false},
+	F2D:             {"f2d", NO_OPERANDS, 1, 2, false},
+	D2I:             {"d2i", NO_OPERANDS, 2, 1, false},
+	D2L:             {"d2l", NO_OPERANDS, 2, 2, false},
+	D2F:             {"d2f", NO_OPERANDS, 2, 1, false},
+	I2B:             {"i2b", NO_OPERANDS, 1, 1, false},
+	I2C:             {"i2c", NO_OPERANDS, 1, 1, false},
+	I2S:             {"i2s", NO_OPERANDS, 1, 1, false},
+	LCMP:            {"lcmp", NO_OPERANDS, 4, 1, false},
+	FCMPL:           {"fcmpl", NO_OPERANDS, 2, 1, false},
+	FCMPG:           {"fcmpg", NO_OPERANDS, 2, 1, false},
+	DCMPL:           {"dcmpl", NO_OPERANDS, 4, 1, false},
+	DCMPG:           {"dcmpg", NO_OPERANDS, 4, 1, false},
+	IFEQ:            {"ifeq", BRANCH, 1, 0, false},
+	IFNE:            {"ifne", BRANCH, 1, 0, false},
+	IFLT:            {"iflt", BRANCH, 1, 0, false},
+	IFGE:            {"ifge", BRANCH, 1, 0, false},
+	IFGT:            {"ifgt", BRANCH, 1, 0, false},
+	IFLE:            {"ifle", BRANCH, 1, 0, false},
+	IF_ICMPEQ:       {"if_icmpeq", BRANCH, 2, 0, false},
+	IF_ICMPNE:       {"if_icmpne", BRANCH, 2, 0, false},
+	IF_ICMPLT:       {"if_icmplt", BRANCH, 2, 0, false},
+	IF_ICMPGE:       {"if_icmpge", BRANCH, 2, 0, false},
+	IF_ICMPGT:       {"if_icmpgt", BRANCH, 2, 0, false},
+	IF_ICMPLE:       {"if_icmple", BRANCH, 2, 0, false},
+	IF_ACMPEQ:       {"if_acmpeq", BRANCH, 2, 0, false},
+	IF_ACMPNE:       {"if_acmpne", BRANCH, 2, 0, false},
+	GOTO:            {"goto", BRANCH, 0, 0, true},
+	JSR:             {"jsr", BRANCH, 0, 1, false},
+	RET:             {"ret", LOCAL, 0, 0, true},
+	TABLESWITCH:     {"tableswitch", SWITCH, 1, 0, true},
+	LOOKUPSWITCH:    {"lookupswitch", SWITCH, 1, 0, true},
+	IRETURN:         {"ireturn", NO_OPERANDS, 1, 0, true},
+	LRETURN:         {"lreturn", NO_OPERANDS, 2, 0, true},
+	FRETURN:         {"freturn", NO_OPERANDS, 1, 0, true},
+	DRETURN:         {"dreturn", NO_OPERANDS, 2, 0, true},
+	ARETURN:         {"areturn", NO_OPERANDS, 1, 0, true},
+	RETURN:          {"return", NO_OPERANDS, 0, 0, true},
+	GETSTATIC:       {"getstatic", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	PUTSTATIC:       {"putstatic", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	GETFIELD:        {"getfield", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	PUTFIELD:        {"putfield", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	INVOKEVIRTUAL:   {"invokevirtual", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	INVOKESPECIAL:   {"invokespecial", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	INVOKESTATIC:    {"invokestatic", CONSTANT, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	INVOKEINTERFACE: {"invokeinterface", INTERFACE_CALL, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	INVOKEDYNAMIC:   {"invokedynamic", DYNAMIC_CALL, VARIABLE_EFFECT, VARIABLE_EFFECT, false},
+	NEW:             {"new", CONSTANT, 0, 1, false},
+	NEWARRAY:        {"newarray", ARRAY_TYPE, 1, 1, false},
+	ANEWARRAY:       {"anewarray", CONSTANT, 1, 1, false},
+	ARRAYLENGTH:     {"arraylength", NO_OPERANDS, 1, 1, false},
+	ATHROW:          {"athrow", NO_OPERANDS, 1, 0, true},
+	CHECKCAST:       {"checkcast", CONSTANT, 1, 1, false},
+	INSTANCEOF:      {"instanceof", CONSTANT, 1, 1, false},
+	MONITORENTER:    {"monitorenter", NO_OPERANDS, 1, 0, false},
+	MONITOREXIT:     {"monitorexit", NO_OPERANDS, 1, 0, false},
+	WIDE:            {"wide", WIDE_PREFIX, 0, 0, false},
+	MULTIANEWARRAY:  {"multianewarray", MULTI_ARRAY, VARIABLE_EFFECT, 1, false},
+	IFNULL:          {"ifnull", BRANCH, 1, 0, false},
+	IFNONNULL:       {"ifnonnull", BRANCH, 1, 0, false},
+	GOTO_W:          {"goto_w", BRANCH_W, 0, 0, true},
+	JSR_W:           {"jsr_w", BRANCH_W, 0, 1, false},
+}
+
+// Lookup returns the description of an opcode and whether the opcode exists
+func Lookup(op byte) (Opcode, bool) {
+	return Opcodes[op], Opcodes[op].Mnemonic != ""
+}
+
+// OperandLength returns the number of operand bytes, -1 for switches whose length depends on the code
+func (o Opcode) OperandLength(wide bool) int {
+	switch o.Operands {
+	case LOCAL:
+		if wide {
+			return 2
+		}
+		return 1
+	case LOCAL_CONSTANT:
+		if wide {
+			return 4
+		}
+		return 2
+	case BYTE, CONSTANT_U1, ARRAY_TYPE, WIDE_PREFIX:
+		return 1
+	case SHORT, CONSTANT, BRANCH:
+		return 2
+	case MULTI_ARRAY:
+		return 3
+	case BRANCH_W, INTERFACE_CALL, DYNAMIC_CALL:
+		return 4
+	case SWITCH:
+		return -1
+	}
+	return 0
+}
+
+// CanBeWide reports whether the instruction has a wide form for local variable indices above 255
+func (o Opcode) CanBeWide() bool {
+	return o.Operands == LOCAL || o.Operands == LOCAL_CONSTANT
+}
+
+// IsBranch reports whether the operands of the instruction contain jump offsets
+func (o Opcode) IsBranch() bool {
+	return o.Operands == BRANCH || o.Operands == BRANCH_W || o.Operands == SWITCH
+}
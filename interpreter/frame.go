@@ -0,0 +1,490 @@
+package interpreter
+
+import (
+	"compiler/classfile"
+	"compiler/instructions"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+type frame struct {
+	vm     *VM
+	class  *classfile.Class
+	method *classfile.Method
+	insts  []classfile.Instruction
+	locals []Value
+	stack  []Value
+}
+
+func (f *frame) push(value Value) {
+	if len(f.stack) >= int(f.method.Code.MaxStack) {
+		throw("error: operand stack overflow in %v%v", f.method.Name, f.method.Descriptor)
+	}
+	f.stack = append(f.stack, value)
+}
+
+func (f *frame) pop() Value {
+	if len(f.stack) == 0 {
+		throw("error: operand stack underflow in %v%v", f.method.Name, f.method.Descriptor)
+	}
+	value := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+	return value
+}
+
+func (f *frame) popMany(count int) []Value {
+	values := make([]Value, count)
+	for i := count - 1; i >= 0; i-- {
+		values[i] = f.pop()
+	}
+	return values
+}
+
+func (f *frame) popInt() int32 {
+	value, ok := f.pop().(int32)
+	if !ok {
+		throw("error: expected int on the operand stack in %v%v", f.method.Name, f.method.Descriptor)
+	}
+	return value
+}
+
+func (f *frame) load(index int) Value {
+	if index >= len(f.locals) {
+		throw("error: local variable index %v exceeds max_locals in %v%v", index, f.method.Name, f.method.Descriptor)
+	}
+	return f.locals[index]
+}
+
+func (f *frame) store(index int, value Value) {
+	if index+slotSize(value) > len(f.locals) {
+		throw("error: local variable index %v exceeds max_locals in %v%v", index, f.method.Name, f.method.Descriptor)
+	}
+	f.locals[index] = value
+}
+
+func (f *frame) loadInt(index int) int32 {
+	value, ok := f.load(index).(int32)
+	if !ok {
+		throw("error: local variable %v is not an int in %v%v", index, f.method.Name, f.method.Descriptor)
+	}
+	return value
+}
+
+// loadTyped loads a local variable for a typed load instruction, kind is the distance of the
+// instruction from its int variant
+func (f *frame) loadTyped(index int, kind byte) Value {
+	value := f.load(index)
+	if !hasKind(value, kind) {
+		throw("error: local variable %v is not %v in %v%v", index, kindNames[kind], f.method.Name, f.method.Descriptor)
+	}
+	return value
+}
+
+// popTyped is loadTyped for the operand stack
+func (f *frame) popTyped(kind byte) Value {
+	value := f.pop()
+	if !hasKind(value, kind) {
+		throw("error: expected %v on the operand stack in %v%v", kindNames[kind], f.method.Name, f.method.Descriptor)
+	}
+	return value
+}
+
+// run executes the method until it returns and gives back the returned value
+func (f *frame) run() Value {
+	// maps code offsets to instruction indices for jumps
+	indices := make(map[int]int, len(f.insts))
+	for i, inst := range f.insts {
+		indices[inst.Offset] = i
+	}
+	cp := f.class.ConstPool()
+	pc := 0
+	for pc < len(f.insts) {
+		inst := f.insts[pc]
+		op := inst.Opcode
+		pc++
+		jump := func(condition bool) {
+			if !condition {
+				return
+			}
+			target, ok := indices[inst.Targets[0]]
+			if !ok {
+				throw("error: invalid jump target %v in %v%v", inst.Targets[0], f.method.Name, f.method.Descriptor)
+			}
+			pc = target
+		}
+		switch {
+		case op == instructions.NOP:
+		case op == instructions.ACONST_NULL:
+			f.push(nil)
+		case op >= instructions.ICONST_M1 && op <= instructions.ICONST_5:
+			f.push(int32(op) - instructions.ICONST_0)
+		case op == instructions.BIPUSH:
+			f.push(int32(int8(inst.Operands[0])))
+		case op == instructions.SIPUSH:
+			f.push(int32(int16(inst.U16())))
+		case op == instructions.LDC || op == instructions.LDC_W || op == instructions.LDC2_W:
+			var index uint16
+			if op == instructions.LDC {
+				index = uint16(inst.U8())
+			} else {
+				index = inst.U16()
+			}
+			f.push(constant(cp, index))
+		case op == instructions.LCONST_0 || op == instructions.LCONST_1:
+			f.push(int64(op - instructions.LCONST_0))
+		case op >= instructions.FCONST_0 && op <= instructions.FCONST_2:
+			f.push(float32(op - instructions.FCONST_0))
+		case op == instructions.DCONST_0 || op == instructions.DCONST_1:
+			f.push(float64(op - instructions.DCONST_0))
+		case op >= instructions.ILOAD && op <= instructions.ALOAD:
+			f.push(f.loadTyped(inst.LocalIndex(), op-instructions.ILOAD))
+		case op >= instructions.ILOAD_0 && op <= instructions.ALOAD_3:
+			// the short forms are grouped by type, four per type
+			f.push(f.loadTyped(int((op-instructions.ILOAD_0)%4), (op-instructions.ILOAD_0)/4))
+		case op >= instructions.ISTORE && op <= instructions.ASTORE:
+			f.store(inst.LocalIndex(), f.popTyped(op-instructions.ISTORE))
+		case op >= instructions.ISTORE_0 && op <= instructions.ASTORE_3:
+			f.store(int((op-instructions.ISTORE_0)%4), f.popTyped((op-instructions.ISTORE_0)/4))
+		case op == instructions.POP:
+			f.pop()
+		case op == instructions.POP2:
+			if slotSize(f.pop()) == 1 {
+				f.pop()
+			}
+		case op == instructions.DUP:
+			value := f.pop()
+			f.push(value)
+			f.push(value)
+		case op == instructions.DUP_X1:
+			values := f.popMany(2)
+			f.push(values[1])
+			f.push(values[0])
+			f.push(values[1])
+		case op == instructions.SWAP:
+			values := f.popMany(2)
+			f.push(values[1])
+			f.push(values[0])
+		case op == instructions.INEG:
+			f.push(-f.popInt())
+		case op >= instructions.LNEG && op <= instructions.DNEG:
+			f.push(negate(f.popTyped(op - instructions.INEG)))
+		case isIntArithmetic(op):
+			right := f.popInt()
+			left := f.popInt()
+			f.push(intArithmetic(op, left, right))
+		case op == instructions.LSHL || op == instructions.LSHR || op == instructions.LUSHR:
+			// the shift distance is an int
+			distance := f.popInt()
+			f.push(longArithmetic(op, f.popTyped(1).(int64), int64(distance)))
+		case op >= instructions.IADD && op <= instructions.LXOR:
+			kind := (op - instructions.IADD) % 4
+			if op >= instructions.ISHL {
+				kind = (op - instructions.ISHL) % 2
+			}
+			right := f.popTyped(kind)
+			f.push(arithmetic(op, f.popTyped(kind), right))
+		case op >= instructions.I2L && op <= instructions.D2F:
+			f.push(convert(op, f.popTyped((op-instructions.I2L)/3)))
+		case op == instructions.LCMP:
+			right := f.popTyped(1).(int64)
+			left := f.popTyped(1).(int64)
+			f.push(threeWayCompare(left, right))
+		case op >= instructions.FCMPL && op <= instructions.DCMPG:
+			kind := 2 + (op-instructions.FCMPL)/2
+			right := toFloat64(f.popTyped(kind))
+			left := toFloat64(f.popTyped(kind))
+			if math.IsNaN(left) || math.IsNaN(right) {
+				// fcmpg and dcmpg push 1 for NaN, fcmpl and dcmpl push -1
+				f.push(int32((op-instructions.FCMPL)%2)*2 - 1)
+				break
+			}
+			f.push(threeWayCompare(left, right))
+		case op == instructions.IINC:
+			index := inst.LocalIndex()
+			increment := int32(int8(inst.Operands[1]))
+			if inst.Wide {
+				increment = int32(int16(binary.BigEndian.Uint16(inst.Operands[2:])))
+			}
+			f.store(index, f.loadInt(index)+increment)
+		case op >= instructions.IFEQ && op <= instructions.IFLE:
+			jump(compare(op-instructions.IFEQ, f.popInt(), 0))
+		case op >= instructions.IF_ICMPEQ && op <= instructions.IF_ICMPLE:
+			right := f.popInt()
+			left := f.popInt()
+			jump(compare(op-instructions.IF_ICMPEQ, left, right))
+		case op == instructions.IF_ACMPEQ || op == instructions.IF_ACMPNE:
+			right := f.pop()
+			left := f.pop()
+			jump((left == right) == (op == instructions.IF_ACMPEQ))
+		case op == instructions.IFNULL || op == instructions.IFNONNULL:
+			jump((f.pop() == nil) == (op == instructions.IFNULL))
+		case op == instructions.GOTO || op == instructions.GOTO_W:
+			jump(true)
+		case op >= instructions.IRETURN && op <= instructions.ARETURN:
+			return f.popTyped(op - instructions.IRETURN)
+		case op == instructions.RETURN:
+			return nil
+		case op == instructions.GETSTATIC:
+			className, name, _, err := cp.MemberRef(inst.U16())
+			if err != nil {
+				throw("%v", err)
+			}
+			f.push(getStatic(className, name))
+		case op >= instructions.INVOKEVIRTUAL && op <= instructions.INVOKEINTERFACE:
+			className, name, descriptor, err := cp.MemberRef(inst.U16())
+			if err != nil {
+				throw("%v", err)
+			}
+			f.vm.invoke(f, className, name, descriptor, op != instructions.INVOKESTATIC)
+		case op == instructions.INVOKEDYNAMIC:
+			f.vm.invokeDynamic(f, inst.U16())
+		case op == instructions.NEW:
+			name, err := classNameAt(cp, inst.U16())
+			if err != nil {
+				throw("%v", err)
+			}
+			f.push(&Object{Class: name})
+		case op == instructions.ATHROW:
+			throw("error: uncaught exception thrown in %v%v", f.method.Name, f.method.Descriptor)
+		default:
+			throw("error: unsupported instruction %v at offset %v in %v%v", classfile.Mnemonic(op), inst.Offset, f.method.Name, f.method.Descriptor)
+		}
+	}
+	throw("error: execution fell off the end of %v%v", f.method.Name, f.method.Descriptor)
+	return nil
+}
+
+func isIntArithmetic(op byte) bool {
+	switch op {
+	case instructions.IADD, instructions.ISUB, instructions.IMUL, instructions.IDIV, instructions.IREM,
+		instructions.ISHL, instructions.ISHR, instructions.IUSHR, instructions.IAND, instructions.IOR, instructions.IXOR:
+		return true
+	}
+	return false
+}
+
+func intArithmetic(op byte, left, right int32) int32 {
+	switch op {
+	case instructions.IADD:
+		return left + right
+	case instructions.ISUB:
+		return left - right
+	case instructions.IMUL:
+		return left * right
+	case instructions.IDIV, instructions.IREM:
+		if right == 0 {
+			throw("error: java.lang.ArithmeticException: / by zero")
+		}
+		if op == instructions.IDIV {
+			return left / right
+		}
+		return left % right
+	case instructions.ISHL:
+		return left << (right & 0x1f)
+	case instructions.ISHR:
+		return left >> (right & 0x1f)
+	case instructions.IUSHR:
+		return int32(uint32(left) >> (right & 0x1f))
+	case instructions.IAND:
+		return left & right
+	case instructions.IOR:
+		return left | right
+	}
+	return left ^ right
+}
+
+// kindNames contains the types of the typed instruction variants, ordered by their distance from the int variant
+var kindNames []string = []string{"an int", "a long", "a float", "a double", "a reference"}
+
+func hasKind(value Value, kind byte) bool {
+	switch value.(type) {
+	case int32:
+		return kind == 0
+	case int64:
+		return kind == 1
+	case float32:
+		return kind == 2
+	case float64:
+		return kind == 3
+	}
+	return kind == 4
+}
+
+func negate(value Value) Value {
+	switch v := value.(type) {
+	case int64:
+		return -v
+	case float32:
+		return -v
+	}
+	return -value.(float64)
+}
+
+// arithmetic executes the add, sub, mul, div, rem and bitwise instructions of longs, floats and doubles
+func arithmetic(op byte, left, right Value) Value {
+	switch l := left.(type) {
+	case int64:
+		return longArithmetic(op, l, right.(int64))
+	case float32:
+		return float32(floatArithmetic(op, float64(l), float64(right.(float32))))
+	case float64:
+		return floatArithmetic(op, l, right.(float64))
+	}
+	throw("error: unsupported arithmetic instruction %v", classfile.Mnemonic(op))
+	return nil
+}
+
+func longArithmetic(op byte, left, right int64) int64 {
+	switch op {
+	case instructions.LADD:
+		return left + right
+	case instructions.LSUB:
+		return left - right
+	case instructions.LMUL:
+		return left * right
+	case instructions.LDIV, instructions.LREM:
+		if right == 0 {
+			throw("error: java.lang.ArithmeticException: / by zero")
+		}
+		if op == instructions.LDIV {
+			return left / right
+		}
+		return left % right
+	case instructions.LSHL:
+		return left << (right & 0x3f)
+	case instructions.LSHR:
+		return left >> (right & 0x3f)
+	case instructions.LUSHR:
+		return int64(uint64(left) >> (right & 0x3f))
+	case instructions.LAND:
+		return left & right
+	case instructions.LOR:
+		return left | right
+	}
+	return left ^ right
+}
+
+// floatArithmetic computes float operations with float64, the result of a float operation is exact
+// after it is rounded back to float32
+func floatArithmetic(op byte, left, right float64) float64 {
+	switch (op - instructions.IADD) / 4 {
+	case 0:
+		return left + right
+	case 1:
+		return left - right
+	case 2:
+		return left * right
+	case 3:
+		return left / right
+	}
+	return math.Mod(left, right)
+}
+
+// convert executes the i2l, l2f, d2i etc. instructions, conversions to int and long saturate and
+// turn NaN into 0 like on the jvm
+func convert(op byte, value Value) Value {
+	switch op {
+	case instructions.I2L:
+		return int64(value.(int32))
+	case instructions.I2F:
+		return float32(value.(int32))
+	case instructions.I2D:
+		return float64(value.(int32))
+	case instructions.L2I:
+		return int32(value.(int64))
+	case instructions.L2F:
+		return float32(value.(int64))
+	case instructions.L2D:
+		return float64(value.(int64))
+	case instructions.F2D:
+		return float64(value.(float32))
+	case instructions.D2F:
+		return float32(value.(float64))
+	case instructions.F2I, instructions.D2I:
+		return int32(saturate(toFloat64(value), math.MinInt32, math.MaxInt32))
+	}
+	return saturate(toFloat64(value), math.MinInt64, math.MaxInt64)
+}
+
+func saturate(value float64, min, max int64) int64 {
+	switch {
+	case math.IsNaN(value):
+		return 0
+	case value <= float64(min):
+		return min
+	case value >= float64(max):
+		return max
+	}
+	return int64(value)
+}
+
+func toFloat64(value Value) float64 {
+	if v, ok := value.(float32); ok {
+		return float64(v)
+	}
+	return value.(float64)
+}
+
+func threeWayCompare[T int64 | float64](left, right T) int32 {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	}
+	return 0
+}
+
+// compare evaluates the condition of the if<cond> and if_icmp<cond> families, ordered eq, ne, lt, ge, gt, le
+func compare(condition byte, left, right int32) bool {
+	switch condition {
+	case 0:
+		return left == right
+	case 1:
+		return left != right
+	case 2:
+		return left < right
+	case 3:
+		return left >= right
+	case 4:
+		return left > right
+	}
+	return left <= right
+}
+
+func constant(cp *classfile.ConstPool, index uint16) Value {
+	c, err := cp.Get(index)
+	if err != nil {
+		throw("%v", err)
+	}
+	switch c.Tag {
+	case classfile.CONSTANT_INTEGER:
+		return c.Integer
+	case classfile.CONSTANT_FLOAT:
+		return c.Float
+	case classfile.CONSTANT_LONG:
+		return c.Long
+	case classfile.CONSTANT_DOUBLE:
+		return c.Double
+	case classfile.CONSTANT_STRING:
+		value, err := cp.Utf8Value(c.StringIndex)
+		if err != nil {
+			throw("%v", err)
+		}
+		return value
+	}
+	throw("error: cannot load constant pool entry %v", index)
+	return nil
+}
+
+func classNameAt(cp *classfile.ConstPool, index uint16) (string, error) {
+	c, err := cp.Get(index)
+	if err != nil {
+		return "", err
+	}
+	if c.Tag != classfile.CONSTANT_CLASS {
+		return "", fmt.Errorf("error: constant pool entry %v is not a class", index)
+	}
+	return cp.Utf8Value(c.NameIndex)
+}
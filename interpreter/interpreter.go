@@ -0,0 +1,342 @@
+package interpreter
+
+import (
+	"compiler/classfile"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_CALL_DEPTH = 1024
+	MAIN_METHOD    = "main"
+	MAIN_DESC      = "([Ljava/lang/String;)V"
+)
+
+// Value is a single operand stack or local variable entry. ints are stored as int32,
+// longs as int64, floats as float32, doubles as float64, strings as string and
+// every other reference as *Object or nil
+type Value any
+
+type Object struct {
+	Class string
+	// content of a java/lang/StringBuilder
+	builder strings.Builder
+}
+
+// VM interprets the subset of the jvm instruction set our compiler emits, so the output
+// can be run without a jdk. Calls to java/io/PrintStream.println, java/lang/StringBuilder,
+// java/util/Objects.equals and java/lang/Math.pow are handled as intrinsics, as are invokedynamic
+// call sites bootstrapped by java/lang/invoke/StringConcatFactory.makeConcatWithConstants
+type VM struct {
+	classes map[string]*classfile.Class
+	methods map[*classfile.Method][]classfile.Instruction
+	out     io.Writer
+	depth   int
+}
+
+type runtimeError struct {
+	err error
+}
+
+func NewVM(out io.Writer) *VM {
+	return &VM{classes: make(map[string]*classfile.Class), methods: make(map[*classfile.Method][]classfile.Instruction), out: out}
+}
+
+// Load parses a class file and makes its methods callable
+func (vm *VM) Load(data []byte) (*classfile.Class, error) {
+	class, err := classfile.ParseClass(data)
+	if err != nil {
+		return nil, err
+	}
+	vm.AddClass(class)
+	return class, nil
+}
+
+func (vm *VM) AddClass(class *classfile.Class) {
+	vm.classes[class.Name()] = class
+}
+
+// Run calls public static void main(String[]) of the class or, for the output of older
+// compiler versions, the first method called main that takes no arguments
+func (vm *VM) Run(className string) error {
+	class, ok := vm.classes[className]
+	if !ok {
+		return fmt.Errorf("error: class %v is not loaded", className)
+	}
+	if _, ok := class.FindMethod(MAIN_METHOD, MAIN_DESC); ok {
+		_, err := vm.Invoke(className, MAIN_METHOD, MAIN_DESC, nil)
+		return err
+	}
+	for _, method := range class.Methods() {
+		md, err := classfile.ParseMethodDescriptor(method.Descriptor)
+		if err == nil && method.Name == MAIN_METHOD && len(md.Args) == 0 {
+			_, err := vm.Invoke(className, MAIN_METHOD, method.Descriptor)
+			return err
+		}
+	}
+	return fmt.Errorf("error: class %v has no main method", className)
+}
+
+// Invoke calls a method of a loaded class and returns its result (nil for void methods).
+// Instance methods are called on a new object of the class
+func (vm *VM) Invoke(className, name, descriptor string, args ...Value) (result Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(runtimeError)
+			if !ok {
+				panic(r)
+			}
+			result, err = nil, e.err
+		}
+	}()
+	md, err := classfile.ParseMethodDescriptor(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	if len(md.Args) != len(args) {
+		return nil, fmt.Errorf("error: %v.%v%v expects %v arguments but got %v", className, name, descriptor, len(md.Args), len(args))
+	}
+	class, method := vm.resolveMethod(className, name, descriptor)
+	if method.Flags&classfile.ACC_STATIC == 0 {
+		args = append([]Value{&Object{Class: className}}, args...)
+	}
+	return vm.call(class, method, args), nil
+}
+
+func throw(format string, args ...any) {
+	panic(runtimeError{err: fmt.Errorf(format, args...)})
+}
+
+func (vm *VM) resolveMethod(className, name, descriptor string) (*classfile.Class, *classfile.Method) {
+	class, ok := vm.classes[className]
+	if !ok {
+		throw("error: java.lang.NoClassDefFoundError: %v", className)
+	}
+	method, ok := class.FindMethod(name, descriptor)
+	if !ok {
+		throw("error: java.lang.NoSuchMethodError: %v.%v%v", className, name, descriptor)
+	}
+	if method.Code == nil {
+		throw("error: %v.%v%v has no code", className, name, descriptor)
+	}
+	return class, method
+}
+
+func (vm *VM) call(class *classfile.Class, method *classfile.Method, args []Value) Value {
+	if vm.depth >= MAX_CALL_DEPTH {
+		throw("error: java.lang.StackOverflowError")
+	}
+	vm.depth++
+	defer func() { vm.depth-- }()
+	insts, ok := vm.methods[method]
+	if !ok {
+		var err error
+		if insts, err = classfile.DecodeInstructions(method.Code.Code); err != nil {
+			throw("%v", err)
+		}
+		vm.methods[method] = insts
+	}
+	f := &frame{vm: vm, class: class, method: method, insts: insts,
+		locals: make([]Value, method.Code.MaxLocals), stack: make([]Value, 0, method.Code.MaxStack)}
+	slot := 0
+	for _, arg := range args {
+		if slot >= len(f.locals) {
+			throw("error: max locals of %v%v is too small for its arguments", method.Name, method.Descriptor)
+		}
+		f.locals[slot] = arg
+		slot += slotSize(arg)
+	}
+	return f.run()
+}
+
+// invoke handles invoke instructions, either by calling into a loaded class or with an intrinsic
+func (vm *VM) invoke(f *frame, className, name, descriptor string, hasReceiver bool) {
+	md, err := classfile.ParseMethodDescriptor(descriptor)
+	if err != nil {
+		throw("%v", err)
+	}
+	count := len(md.Args)
+	if hasReceiver {
+		count++
+	}
+	args := f.popMany(count)
+	if result, ok := vm.intrinsic(className, name, md, args); ok {
+		if md.ReturnType != "V" {
+			f.push(result)
+		}
+		return
+	}
+	class, method := vm.resolveMethod(className, name, descriptor)
+	if hasReceiver && args[0] == nil {
+		throw("error: java.lang.NullPointerException: cannot invoke %v.%v%v", className, name, descriptor)
+	}
+	result := vm.call(class, method, args)
+	if md.ReturnType != "V" {
+		f.push(result)
+	}
+}
+
+// invokeDynamic links an invokedynamic call site, only string concatenation is supported
+func (vm *VM) invokeDynamic(f *frame, index uint16) {
+	cp := f.class.ConstPool()
+	bootstrapIndex, name, descriptor, err := cp.InvokeDynamicRef(index)
+	if err != nil {
+		throw("%v", err)
+	}
+	bootstraps := f.class.BootstrapMethods()
+	if int(bootstrapIndex) >= len(bootstraps) {
+		throw("error: call site %v references missing bootstrap method %v", name, bootstrapIndex)
+	}
+	bootstrap := bootstraps[bootstrapIndex]
+	handle, err := cp.Get(bootstrap.MethodHandle)
+	if err != nil {
+		throw("%v", err)
+	}
+	if handle.Tag != classfile.CONSTANT_METHODHANDLE {
+		throw("error: bootstrap method %v is not a method handle", bootstrapIndex)
+	}
+	className, methodName, _, err := cp.MemberRef(handle.ReferenceIndex)
+	if err != nil {
+		throw("%v", err)
+	}
+	if className != "java/lang/invoke/StringConcatFactory" || methodName != "makeConcatWithConstants" || len(bootstrap.Arguments) == 0 {
+		throw("error: unsupported bootstrap method %v.%v for call site %v", className, methodName, name)
+	}
+	recipe, ok := constant(cp, bootstrap.Arguments[0]).(string)
+	if !ok {
+		throw("error: the recipe of call site %v is not a string", name)
+	}
+	md, err := classfile.ParseMethodDescriptor(descriptor)
+	if err != nil {
+		throw("%v", err)
+	}
+	args := f.popMany(len(md.Args))
+	constants := bootstrap.Arguments[1:]
+	var result strings.Builder
+	for _, r := range recipe {
+		switch r {
+		case '\x01':
+			if len(args) == 0 {
+				throw("error: the recipe of call site %v has more arguments than %v", name, descriptor)
+			}
+			result.WriteString(format(args[0], md.Args[len(md.Args)-len(args)]))
+			args = args[1:]
+		case '\x02':
+			if len(constants) == 0 {
+				throw("error: the recipe of call site %v has more constants than its bootstrap method", name)
+			}
+			result.WriteString(format(constant(cp, constants[0]), ""))
+			constants = constants[1:]
+		default:
+			result.WriteRune(r)
+		}
+	}
+	f.push(result.String())
+}
+
+func (vm *VM) intrinsic(className, name string, md classfile.MethodDescriptor, args []Value) (Value, bool) {
+	switch {
+	case (className == "java/lang/Object" || className == "java/lang/StringBuilder") && name == "<init>":
+		return nil, true
+	case className == "java/lang/StringBuilder" && name == "append":
+		builder := args[0].(*Object)
+		builder.builder.WriteString(format(args[1], md.Args[0]))
+		return builder, true
+	case className == "java/lang/StringBuilder" && name == "toString":
+		return args[0].(*Object).builder.String(), true
+	case className == "java/util/Objects" && name == "equals":
+		if args[0] == args[1] {
+			return int32(1), true
+		}
+		return int32(0), true
+	case className == "java/lang/Math" && name == "pow":
+		return math.Pow(args[0].(float64), args[1].(float64)), true
+	case className == "java/io/PrintStream" && (name == "println" || name == "print"):
+		text := ""
+		if len(md.Args) == 1 {
+			text = format(args[1], md.Args[0])
+		}
+		if name == "println" {
+			text += "\n"
+		}
+		if _, err := io.WriteString(vm.out, text); err != nil {
+			throw("error: could not write output (%v)", err)
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func getStatic(className, name string) Value {
+	if className == "java/lang/System" && name == "out" {
+		return &Object{Class: "java/io/PrintStream"}
+	}
+	throw("error: java.lang.NoSuchFieldError: %v.%v", className, name)
+	return nil
+}
+
+// format converts a value to a string the same way String.valueOf does
+func format(value Value, descriptor string) string {
+	switch descriptor {
+	case "Z":
+		if value.(int32) != 0 {
+			return "true"
+		}
+		return "false"
+	case "C":
+		return string(rune(value.(int32)))
+	}
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case *Object:
+		return fmt.Sprintf("%v@%p", v.Class, v)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatFloat formats a float or double like Float.toString and Double.toString, values from 10^-3
+// to 10^7 are written as decimal numbers and every other value in scientific notation like 1.0E10
+func formatFloat(value float64, bits int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0 && math.Signbit(value):
+		return "-0.0"
+	}
+	if abs := math.Abs(value); value == 0 || abs >= 1e-3 && abs < 1e7 {
+		text := strconv.FormatFloat(value, 'f', -1, bits)
+		if !strings.Contains(text, ".") {
+			text += ".0"
+		}
+		return text
+	}
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(value, 'E', -1, bits), "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	// strconv writes the exponent with a sign and at least two digits
+	power, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(power)
+}
+
+func slotSize(value Value) int {
+	switch value.(type) {
+	case int64, float64:
+		return 2
+	}
+	return 1
+}
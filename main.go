@@ -3,26 +3,110 @@ package main
 import (
 	"compiler/classfile"
 	"compiler/command"
+	"compiler/diagnostics"
 	"compiler/generator"
+	"compiler/interpreter"
 	"compiler/parser"
+	"compiler/source"
 	"compiler/tokenizer"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 func main() {
+	if command.GetSubcommand() == command.DISASM {
+		disassemble()
+		return
+	}
+	if command.GetSubcommand() == command.RUN {
+		run()
+		return
+	}
 	file, err := os.ReadFile(command.GetSourceFile())
 	if err != nil {
 		log.Fatalf("error: could not open file (%v)", err)
 	}
-	class := classfile.NewClass(command.GetSourceFile(), "base/Object")
-	log.Println(string(file))
-	tokens := tokenizer.NewTokenizer(string(file)).GetTokens()
-	log.Println(tokens)
-	program := parser.NewParser(tokens, class).ParseProgram()
-	log.Println(program)
-	generator.NewGenerator(program).GenerateByteCode(class)
-	classfile := class.ConvertToBytes()
+	// the jvm expects the class to be named like its file
+	className := strings.TrimSuffix(filepath.Base(command.GetOutFile()), ".class")
+	diags := diagnostics.NewDiagnostics()
+	classfile := compile(command.GetSourceFile(), string(file), className, command.GetTarget(), diags)
+	diags.Render(os.Stderr)
+	if diags.HasErrors() {
+		log.Fatalf("error: could not compile %v due to %v previous errors", command.GetSourceFile(), diags.ErrorCount())
+	}
 	log.Println(classfile)
+	if command.ShouldVerify() {
+		verify(classfile)
+	}
 	os.WriteFile(command.GetOutFile(), classfile, 0666)
 }
+
+// compile translates a program into a class file, the class file is nil if there are errors in the diagnostics
+func compile(file, src, className string, target classfile.Target, diags *diagnostics.Diagnostics) []byte {
+	class := classfile.NewClass(className, "java/lang/Object", target)
+	log.Println(src)
+	diags.AddSource(file, src)
+	tokens := tokenizer.NewTokenizer(file, src, diags).GetTokens()
+	log.Println(tokens)
+	program := parser.NewParser(tokens, class, diags).ParseProgram()
+	log.Println(program)
+	// the checker only runs on programs without syntax errors and code generation only on programs without any errors
+	if !diags.HasErrors() {
+		program = parser.NewChecker(diags).Check(program)
+	}
+	if !diags.HasErrors() {
+		generator.NewGenerator(program).GenerateByteCode(class, diags)
+	}
+	if diags.HasErrors() {
+		return nil
+	}
+	data, err := class.ConvertToBytes()
+	if err != nil {
+		diags.Error(diagnostics.INVALID_CLASS_FILE, source.Span{}, "%v", strings.TrimPrefix(err.Error(), "error: "))
+		return nil
+	}
+	return data
+}
+
+func verify(file []byte) {
+	class, err := classfile.ParseClass(file)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	errors := class.Verify()
+	for _, err := range errors {
+		log.Println(err)
+	}
+	if len(errors) > 0 {
+		log.Fatalf("error: verification failed with %v errors", len(errors))
+	}
+}
+
+func disassemble() {
+	file, err := os.ReadFile(command.GetClassFile())
+	if err != nil {
+		log.Fatalf("error: could not open file (%v)", err)
+	}
+	class, err := classfile.ParseClass(file)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	class.Disassemble(os.Stdout)
+}
+
+func run() {
+	file, err := os.ReadFile(command.GetClassFile())
+	if err != nil {
+		log.Fatalf("error: could not open file (%v)", err)
+	}
+	vm := interpreter.NewVM(os.Stdout)
+	class, err := vm.Load(file)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := vm.Run(class.Name()); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/interpreter"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the compiler logs every phase
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// compileSource compiles a program for a release and fails the test if there are errors
+func compileSource(t *testing.T, file, src string, release int) []byte {
+	t.Helper()
+	diags := diagnostics.NewDiagnostics()
+	class := compile(file, src, "Test", classfile.NewTarget(release), diags)
+	if diags.HasErrors() {
+		var errors strings.Builder
+		diags.Render(&errors)
+		t.Fatalf("cannot compile %v:\n%v", file, errors.String())
+	}
+	return class
+}
+
+// compileErrors compiles a program that is expected to be invalid and returns the codes of the errors
+func compileErrors(file, src string) []string {
+	diags := diagnostics.NewDiagnostics()
+	compile(file, src, "Test", classfile.NewTarget(classfile.MIN_RELEASE), diags)
+	codes := make([]string, 0)
+	for _, diagnostic := range diags.List() {
+		if diagnostic.Severity == diagnostics.ERROR {
+			codes = append(codes, diagnostic.Code)
+		}
+	}
+	return codes
+}
+
+func runClass(t *testing.T, data []byte) string {
+	t.Helper()
+	var out bytes.Buffer
+	vm := interpreter.NewVM(&out)
+	class, err := vm.Load(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Run(class.Name()); err != nil {
+		t.Fatalf("%v (output so far %q)", err, out.String())
+	}
+	return out.String()
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		file    string
+		release int
+		output  string
+	}{
+		{"main.e", 8, "10\n"},
+		{"features.e", 8, "hi\nA 5\ntrue\n1\n1000000\n200\n30\n37.5\n2.5\n150\n512\n81\n22500.0\n-3\n3\n1\n-4\n17\n15\n-4\n7\n804609\n1\n2\n4\n5\n0\n1\n2\n24\n"},
+		{"operators.e", 8, "-17\n17\n-10\n-18\n-2.5\n2\n-2\n2\n0.5\n1\n25\n16\n-18\n-6\n68\n-9\n15\n5497558138880\n15\n2\n25\n16\n12\n6\n1\nfalse\ntrue\nfalse\ntrue\ntrue\ntrue\ntrue\n"},
+		{"numbers.e", 8, "3000000007\n3.0\n4.0\n1.5\n9000000000\n1.0E10\n1.0E-4\n-1.25E-5\n3\n-3\n3.0E9\n2.5\n2.5\n1.5\ncmp ok\nNaN\nInfinity\nconcat 3000000000 1.5 2.5\n2147483647\n0\n1\n2.0\n"},
+		{"power.e", 8, "512\n1024\n4611686018427387904\n-2147483648\n0\n-1\n1.4142135623730951\n8.0\n81\n-32\n-2147483648\n1\n0\n4052555153018976267\n-1\n3.0\n256.0\n18\n9\n-4\n4\n0\n"},
+		{"loops.e", 8, "1\n3\nj0\nj1\nj2\n45\n00\n01\n10\n11\n4\n4\n"},
+		{"scopes.e", 8, "1\n2\n3\n4\n18\n6\n"},
+		{"labels.e", 8, "42\n-1\n8\n"},
+		{"calls.e", 8, "4\n5\n15\ng4\n-4\n4\n"},
+		{"returns.e", 8, "2\n1\ndone\npositive 3\n"},
+		// StringBuilder before java 9 and invokedynamic since then
+		{"concat.e", 8, "a=3, b=4 d=2.5xtrue\nx3\n"},
+		{"concat.e", 11, "a=3, b=4 d=2.5xtrue\nx3\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			src, err := os.ReadFile("testsource/" + test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := compileSource(t, test.file, string(src), test.release)
+			class, err := classfile.ParseClass(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errors := class.Verify(); len(errors) > 0 {
+				t.Fatalf("verify errors %v", errors)
+			}
+			if output := runClass(t, data); output != test.output {
+				t.Errorf("expected output %q but got %q", test.output, output)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		codes []string
+	}{
+		{"undeclared variable", "fun main() { println(x); }", []string{diagnostics.UNDECLARED_VARIABLE}},
+		{"missing return", "fun f(a int) int { if a > 0 { return 1; } }\nfun main() { println(f(1)); }", []string{diagnostics.MISSING_RETURN}},
+		{"return without value", "fun f() int { return; }\nfun main() { println(f()); }", []string{diagnostics.TYPE_MISMATCH}},
+		{"return value in void function", "fun main() { return 1; }", []string{diagnostics.TYPE_MISMATCH}},
+		{"statements outside of functions", "let g int = 5;\nprintln(1);\nfun main() { return; }", []string{diagnostics.STATEMENT_OUTSIDE_FUNCTION, diagnostics.STATEMENT_OUTSIDE_FUNCTION}},
+		{"int literal overflow", "fun main() { println(2147483648); }", []string{diagnostics.INVALID_NUMBER}},
+		{"smallest int literal", "fun main() { println(-2147483648); }", nil},
+		{"long literal overflow", "fun main() { println(9223372036854775808L); }", []string{diagnostics.INVALID_NUMBER}},
+		{"smallest long literal", "fun main() { println(-9223372036854775808L); }", nil},
+		{"implicit narrowing", "fun main() { let b int = 1L; }", []string{diagnostics.TYPE_MISMATCH}},
+		{"explicit narrowing", "fun main() { let b int = 1L as int; println(b); }", nil},
+		{"long literal with a fraction", "fun main() { println(1.5L); }", []string{diagnostics.INVALID_NUMBER}},
+		{"long literal with an exponent", "fun main() { println(1e3L); }", []string{diagnostics.INVALID_NUMBER}},
+		{"double literal overflow", "fun main() { println(1e309); }", []string{diagnostics.INVALID_NUMBER}},
+		{"float literal overflow", "fun main() { println(3.5e38f); }", []string{diagnostics.INVALID_NUMBER}},
+		{"double literal underflow", "fun main() { println(1e-400); }", []string{diagnostics.INVALID_NUMBER}},
+		{"float literal underflow", "fun main() { println(1e-50f); }", []string{diagnostics.INVALID_NUMBER}},
+		{"zero float literal", "fun main() { println(0.0e-50f); }", nil},
+		{"break outside of a loop", "fun main() { break; }", []string{diagnostics.BREAK_OUTSIDE_LOOP}},
+		{"continue outside of a loop", "fun main() { if true { continue; } }", []string{diagnostics.BREAK_OUTSIDE_LOOP}},
+		{"break of an unknown loop", "fun main() { while true { break nope; } }", []string{diagnostics.UNKNOWN_LABEL}},
+		{"continue of a loop that ended", "fun main() { outer: while true { break; }\nwhile true { continue outer; } }", []string{diagnostics.UNKNOWN_LABEL}},
+		{"redeclaration", "fun main() { let a int = 1; let a long = 2L; }", []string{diagnostics.REDECLARED_VARIABLE}},
+		{"shadowing", "fun main() { let a int = 1; if a > 0 { let a int = 2; } }", []string{diagnostics.REDECLARED_VARIABLE}},
+		{"redeclared parameter", "fun f(a int) { let a int = 2; }\nfun main() { f(1); }", []string{diagnostics.REDECLARED_VARIABLE}},
+		{"variable of an ended scope", "fun main() { if true { let a int = 1; }\nlet a int = 2; println(a); }", nil},
+		{"string literal too long", "fun main() { println(\"" + strings.Repeat("é", 40000) + "\"); }", []string{diagnostics.STRING_TOO_LONG}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codes := compileErrors("test.e", test.src)
+			if strings.Join(codes, " ") != strings.Join(test.codes, " ") {
+				t.Errorf("expected the errors %v but got %v", test.codes, codes)
+			}
+		})
+	}
+}
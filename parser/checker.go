@@ -0,0 +1,670 @@
+package parser
+
+import (
+	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/source"
+)
+
+// Checker is the pass between parsing and code generation. It resolves every name to its variable
+// or function, checks the types of all statements and expressions and annotates the AST with what
+// it found, so every semantic error is reported before any bytecode is generated
+type Checker struct {
+	diagnostics *diagnostics.Diagnostics
+	scope       *lexicalScope
+	// local variables of the checked function
+	symbols *SymbolTable
+	// return type of the checked function
+	returnType string
+	// loops around the checked statement, the innermost loop is last
+	loops []*checkedLoop
+	// functions of the checked program
+	functions map[string]Function
+}
+
+// checkedLoop is a loop around the checked statement, broken is set by a break statement that ends it
+type checkedLoop struct {
+	label  string
+	broken bool
+}
+
+func NewChecker(diags *diagnostics.Diagnostics) *Checker {
+	return &Checker{diagnostics: diags, scope: newLexicalScope(nil), symbols: NewSymbolTable(), loops: make([]*checkedLoop, 0)}
+}
+
+// Check returns the program with annotated function definitions, other statements cannot be
+// compiled outside of a function and are reported
+func (c *Checker) Check(program Program) Program {
+	c.functions = program.Functions
+	statements := make([]Statement, 0, len(program.Statements))
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(FunctionDefinition); !ok {
+			c.diagnostics.Error(diagnostics.STATEMENT_OUTSIDE_FUNCTION, stmt.GetSpan(), "statement outside of a function").
+				Note("only function definitions can be at the top level, the program starts in main")
+			continue
+		}
+		checked, _ := c.checkStatement(stmt)
+		statements = append(statements, checked)
+	}
+	return Program{Statements: statements, Functions: program.Functions}
+}
+
+// checkStatement returns the annotated statement and reports whether the statement after it can be reached
+func (c *Checker) checkStatement(stmt Statement) (Statement, bool) {
+	switch s := stmt.(type) {
+	case FunctionDefinition:
+		return c.checkFunction(s), true
+	case VarDecl:
+		return c.checkVarDecl(s), true
+	case VarReAssignment:
+		return c.checkReassignment(s), true
+	case CompoundAssignment:
+		return c.checkCompoundAssignment(s), true
+	case ReturnStatement:
+		return c.checkReturn(s), false
+	case FunctionCall:
+		c.checkFunctionCall(&s)
+		return s, true
+	case IfStatement:
+		return c.checkIf(s)
+	case WhileStatement:
+		return c.checkWhile(s)
+	case ForStatement:
+		return c.checkFor(s)
+	case RangeForStatement:
+		return c.checkRangeFor(s), true
+	case LoopControl:
+		c.checkLoopControl(s)
+		return s, false
+	}
+	return stmt, true
+}
+
+// checkScope checks the statements of a block in a new scope and reports whether its end can be reached
+func (c *Checker) checkScope(scope Scope) (Scope, bool) {
+	c.scope = newLexicalScope(c.scope)
+	defer func() { c.scope = c.scope.parent }()
+	statements := make([]Statement, 0, len(scope.Statements))
+	completes := true
+	for _, stmt := range scope.Statements {
+		checked, next := c.checkStatement(stmt)
+		statements = append(statements, checked)
+		completes = completes && next
+	}
+	return Scope{Statements: statements, Span: scope.Span}, completes
+}
+
+// checkRedeclaration reports a variable that is already declared in the current scope or in a scope
+// around it, variables of the same function cannot shadow each other
+func (c *Checker) checkRedeclaration(name string, span source.Span) *diagnostics.Diagnostic {
+	for scope := c.scope; scope != nil; scope = scope.parent {
+		existing, ok := scope.variables[name]
+		if !ok {
+			continue
+		}
+		diagnostic := c.diagnostics.Error(diagnostics.REDECLARED_VARIABLE, span, "cannot redeclare variable '%v'", name)
+		// the parameters are the only variables in the scope of the function itself
+		if scope.parent == nil {
+			diagnostic.Note("'%v' is a parameter of the function declared at %v", name, existing.Span.Start)
+		} else if scope == c.scope {
+			diagnostic.Note("'%v' is already declared in line %v", name, existing.Span.Start.Line)
+		} else {
+			diagnostic.Note("'%v' is declared in an enclosing scope in line %v and cannot be shadowed", name, existing.Span.Start.Line)
+		}
+		return diagnostic
+	}
+	return nil
+}
+
+// declare adds a variable to the current scope and gives it a slot in the symbol table of the function
+func (c *Checker) declare(name, typ string, span source.Span) *Variable {
+	variable := c.symbols.declare(name, typ, span)
+	c.scope.variables[name] = variable
+	return variable
+}
+
+func (c *Checker) checkFunction(fd FunctionDefinition) Statement {
+	checkFunctionTypes(fd, c.diagnostics)
+	if fd.Name == MAIN_FUNCTION && len(fd.Args) != 0 && (len(fd.Args) != 1 || fd.Args[0].Type != "[]string") {
+		c.diagnostics.Error(diagnostics.INVALID_MAIN, fd.Span, "main must take no arguments or a single argument of type []string")
+	}
+	scope, symbols := c.scope, c.symbols
+	c.scope, c.symbols, c.returnType = newLexicalScope(nil), NewSymbolTable(), fd.ReturnType
+	defer func() { c.scope, c.symbols, c.returnType = scope, symbols, "" }()
+	for _, arg := range fd.Args {
+		if c.checkRedeclaration(arg.Name, arg.Span) == nil {
+			c.declare(arg.Name, arg.Type, arg.Span)
+		}
+	}
+	var completes bool
+	fd.Scope, completes = c.checkScope(fd.Scope)
+	// void functions may end without a return statement
+	if fd.ReturnType != "void" && completes {
+		c.diagnostics.Error(diagnostics.MISSING_RETURN, fd.Span, "function %v may end without returning a value of type %v", fd.Name, fd.ReturnType).
+			Note("every path through a function with a return type has to end with a return statement")
+	}
+	fd.Symbols = c.symbols
+	return fd
+}
+
+func (c *Checker) checkVarDecl(id VarDecl) Statement {
+	typ := id.Type.Value.Value
+	if descriptor, err := typeDescriptor(typ); err != nil || descriptor == "V" {
+		c.diagnostics.Error(diagnostics.UNKNOWN_TYPE, id.Type.Span, "unknown type '%v'", typ).
+			Note("variables can have the types int, long, float, double, bool and string")
+		return id
+	}
+	name := id.Ident.Value.Value
+	if diagnostic := c.checkRedeclaration(name, id.Ident.Span); diagnostic != nil {
+		diagnostic.Suggest(id.Span.To(id.Type.Span), name, "assign a new value instead")
+		return id
+	}
+	// the value is checked first, so it cannot use the declared variable
+	id.Value = c.checkTypedExpression(id.Value, typ)
+	id.Variable = c.declare(name, typ, id.Ident.Span)
+	return id
+}
+
+func (c *Checker) checkReassignment(vra VarReAssignment) Statement {
+	value, typ := c.checkExpression(vra.Value)
+	vra.Value = value
+	variable, ok := c.scope.lookup(vra.Ident.Value.Value)
+	if !ok {
+		if typ == "" {
+			typ = "int"
+		}
+		c.diagnostics.Error(diagnostics.UNDECLARED_VARIABLE, vra.Ident.Span, "cannot reassign undeclared variable '%v'", vra.Ident.Value.Value).
+			Suggest(vra.Ident.Span, "let "+vra.Ident.Value.Value+" "+typ, "declare the variable with let")
+		return vra
+	}
+	c.checkAssignable(value, typ, variable.Type)
+	vra.Variable = variable
+	return vra
+}
+
+// checkCompoundAssignment checks x op= y like x = x op y and keeps the operation for the code generation
+func (c *Checker) checkCompoundAssignment(ca CompoundAssignment) Statement {
+	variable, ok := c.scope.lookup(ca.Ident.Value.Value)
+	if !ok {
+		c.diagnostics.Error(diagnostics.UNDECLARED_VARIABLE, ca.Ident.Span, "cannot use undeclared variable %v", ca.Ident.Value.Value)
+		return ca
+	}
+	// strings can only be extended and bools only combined with & | and ^
+	isString := variable.Type == "string" && ca.Operator == "+="
+	isBool := variable.Type == "bool" && (ca.Kind == BIT_AND || ca.Kind == BIT_OR || ca.Kind == BIT_XOR)
+	if !isNumericType(variable.Type) && !isString && !isBool {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, ca.Span, "cannot use %v on a variable of type %v", ca.Operator, variable.Type)
+		return ca
+	}
+	operation := MathExpNode{Kind: ca.Kind, Span: ca.Span}
+	operation.Binary.Left = mathOperand(ca.Ident)
+	operation.Binary.Right = mathOperand(ca.Value)
+	c.checkAssignable(operation, c.checkMath(&operation), variable.Type)
+	ca.Variable, ca.Operation = variable, &operation
+	return ca
+}
+
+func (c *Checker) checkReturn(r ReturnStatement) Statement {
+	if r.ReturnValue == nil {
+		if c.returnType != "void" {
+			c.diagnostics.Error(diagnostics.TYPE_MISMATCH, r.Span, "missing return value of type %v", c.returnType).
+				Note("only functions with return type void can return without a value")
+		}
+		return r
+	}
+	if c.returnType == "void" {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, r.ReturnValue.GetSpan(), "cannot return a value from a function with return type void")
+		return r
+	}
+	r.ReturnValue = c.checkTypedExpression(r.ReturnValue, c.returnType)
+	return r
+}
+
+// checkIf reports the statement after the if as reachable if one of the branches that can be taken
+// completes or if no branch has to be taken
+func (c *Checker) checkIf(is IfStatement) (Statement, bool) {
+	branches := make([]ConditionalBranch, 0, len(is.Branches))
+	completes, exhaustive := false, false
+	for _, branch := range is.Branches {
+		condition := c.checkCondition(branch.Condition)
+		scope, scopeCompletes := c.checkScope(branch.Scope)
+		branches = append(branches, ConditionalBranch{Condition: condition, Scope: scope})
+		// the branches after a condition that is always true are never taken
+		if !exhaustive {
+			completes = completes || scopeCompletes
+			value, ok := constantCondition(condition)
+			exhaustive = ok && value
+		}
+	}
+	is.Branches = branches
+	if is.Else != nil {
+		scope, elseCompletes := c.checkScope(*is.Else)
+		is.Else = &scope
+		completes = completes || (elseCompletes && !exhaustive)
+		exhaustive = true
+	}
+	return is, completes || !exhaustive
+}
+
+func (c *Checker) checkWhile(ws WhileStatement) (Statement, bool) {
+	ws.Condition = c.checkCondition(ws.Condition)
+	loop := c.enterLoop(ws.Label)
+	ws.Scope, _ = c.checkScope(ws.Scope)
+	c.exitLoop()
+	// a loop with a condition that is always true can only be left with break
+	value, ok := constantCondition(ws.Condition)
+	return ws, !(ok && value) || loop.broken
+}
+
+// checkFor checks the header of the loop in its own scope, the variables declared in it can be used
+// in the condition, the update and the body
+func (c *Checker) checkFor(fs ForStatement) (Statement, bool) {
+	c.scope = newLexicalScope(c.scope)
+	defer func() { c.scope = c.scope.parent }()
+	if fs.Init != nil {
+		fs.Init, _ = c.checkStatement(fs.Init)
+	}
+	infinite := true
+	if fs.Condition != nil {
+		fs.Condition = c.checkCondition(fs.Condition)
+		value, ok := constantCondition(fs.Condition)
+		infinite = ok && value
+	}
+	loop := c.enterLoop(fs.Label)
+	fs.Scope, _ = c.checkScope(fs.Scope)
+	c.exitLoop()
+	if fs.Update != nil {
+		fs.Update, _ = c.checkStatement(fs.Update)
+	}
+	return fs, !infinite || loop.broken
+}
+
+func (c *Checker) checkRangeFor(rfs RangeForStatement) Statement {
+	name := rfs.Variable.Value.Value
+	if c.checkRedeclaration(name, rfs.Variable.Span) != nil {
+		return rfs
+	}
+	rfs.From = c.checkTypedExpression(rfs.From, "int")
+	rfs.To = c.checkTypedExpression(rfs.To, "int")
+	c.scope = newLexicalScope(c.scope)
+	defer func() { c.scope = c.scope.parent }()
+	// the end of the range is kept in a local without a name
+	rfs.End = c.symbols.declare("", "int", rfs.To.GetSpan())
+	rfs.Counter = c.declare(name, "int", rfs.Variable.Span)
+	c.enterLoop(rfs.Label)
+	rfs.Scope, _ = c.checkScope(rfs.Scope)
+	c.exitLoop()
+	return rfs
+}
+
+func (c *Checker) enterLoop(label Identifier) *checkedLoop {
+	loop := &checkedLoop{label: label.Value.Value}
+	c.loops = append(c.loops, loop)
+	return loop
+}
+
+func (c *Checker) exitLoop() {
+	c.loops = c.loops[:len(c.loops)-1]
+}
+
+// checkLoopControl finds the loop a break or continue statement belongs to
+func (c *Checker) checkLoopControl(lc LoopControl) {
+	keyword := "continue"
+	if lc.IsBreak {
+		keyword = "break"
+	}
+	loop := len(c.loops) - 1
+	if loop < 0 {
+		c.diagnostics.Error(diagnostics.BREAK_OUTSIDE_LOOP, lc.Span, "cannot use %v outside of a loop", keyword)
+		return
+	}
+	if label := lc.Label.Value.Value; label != "" {
+		for loop >= 0 && c.loops[loop].label != label {
+			loop--
+		}
+		if loop < 0 {
+			c.diagnostics.Error(diagnostics.UNKNOWN_LABEL, lc.Label.Span, "cannot %v unknown loop '%v'", keyword, label).
+				Note("only the loops around the %v statement can be named", keyword)
+			return
+		}
+	}
+	if lc.IsBreak {
+		c.loops[loop].broken = true
+	}
+}
+
+// checkCondition reports a condition that is not a bool
+func (c *Checker) checkCondition(condition Expression) Expression {
+	condition, typ := c.checkExpression(condition)
+	if typ != "" && typ != "bool" {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, condition.GetSpan(), "condition must be of type bool, found %v", typ)
+	}
+	return condition
+}
+
+// constantCondition returns the value of a condition that does not depend on variables, the code
+// generation does not jump for these conditions
+func constantCondition(condition Expression) (bool, bool) {
+	mxp, ok := condition.(MathExpNode)
+	if !ok {
+		return false, false
+	}
+	switch mxp.Kind {
+	case BOOLEAN:
+		return mxp.Number.Value == "true", true
+	case NOT:
+		value, ok := constantCondition(*mxp.Unary.Operand)
+		return !value, ok
+	}
+	return false, false
+}
+
+// checkExpression returns the annotated expression and the type of its value. The type is empty
+// if an error was reported in the expression, so the error is not reported again by its users
+func (c *Checker) checkExpression(expr Expression) (Expression, string) {
+	switch e := expr.(type) {
+	case MathExpNode:
+		typ := c.checkMath(&e)
+		return e, typ
+	case Identifier:
+		variable, ok := c.scope.lookup(e.Value.Value)
+		if !ok {
+			c.diagnostics.Error(diagnostics.UNDECLARED_VARIABLE, e.Span, "cannot use undeclared variable '%v'", e.Value.Value)
+			return e, ""
+		}
+		e.Variable = variable
+		return e, variable.Type
+	case FunctionCall:
+		typ := c.checkFunctionCall(&e)
+		return e, typ
+	}
+	c.diagnostics.Error(diagnostics.UNSUPPORTED, expr.GetSpan(), "unsupported expression type (%v)", expr.GetExpressionType())
+	return expr, ""
+}
+
+// checkTypedExpression checks an expression that has to have the given type
+func (c *Checker) checkTypedExpression(expr Expression, typ string) Expression {
+	expr, actual := c.checkExpression(expr)
+	c.checkAssignable(expr, actual, typ)
+	return expr
+}
+
+// checkAssignable reports a value that cannot be used as a value of type typ. Numeric values are
+// widened implicitly, narrowing them needs an explicit conversion with as
+func (c *Checker) checkAssignable(expr Expression, actual, typ string) {
+	if actual == "" || actual == typ || canWiden(actual, typ) {
+		return
+	}
+	if isNumericType(actual) && isNumericType(typ) {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, expr.GetSpan(), "cannot implicitly convert a value of type %v to %v", actual, typ).
+			Note("the value may lose precision or magnitude, use 'as %v' to convert it explicitly", typ)
+	} else {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, expr.GetSpan(), "expected value of type %v, found %v", typ, actual)
+	}
+}
+
+// checkFunctionCall resolves the called function and checks the arguments, it returns the return type
+func (c *Checker) checkFunctionCall(fc *FunctionCall) string {
+	fun, ok := c.functions[fc.CalledFunctionName]
+	if !ok {
+		c.diagnostics.Error(diagnostics.UNDEFINED_FUNCTION, fc.Span, "cannot call undefined function %v", fc.CalledFunctionName)
+		return ""
+	}
+	argTypes := make([]string, len(fc.Arguments))
+	for index, arg := range fc.Arguments {
+		fc.Arguments[index], argTypes[index] = c.checkExpression(arg)
+	}
+	if fc.CalledFunctionName == PRINTLN_FUNCTION {
+		if fun, ok = c.resolvePrintln(*fc, argTypes); !ok {
+			return ""
+		}
+	}
+	if len(fun.Args) != len(fc.Arguments) {
+		c.diagnostics.Error(diagnostics.ARGUMENT_COUNT, fc.Span, "not enough/too many arguments to call function %v", fc.CalledFunctionName).
+			Note("%v takes %v arguments but %v were given", fc.CalledFunctionName, len(fun.Args), len(fc.Arguments))
+		return ""
+	}
+	// invalid types are reported at the definition of the function
+	if _, err := functionDescriptor(fun.Args, fun.ReturnType); err != nil {
+		return ""
+	}
+	for index, arg := range fc.Arguments {
+		c.checkAssignable(arg, argTypes[index], fun.Args[index].Type)
+	}
+	fc.Function = &fun
+	return fun.ReturnType
+}
+
+// resolvePrintln picks the println overload that matches the type of the argument
+func (c *Checker) resolvePrintln(fc FunctionCall, argTypes []string) (Function, bool) {
+	for _, overload := range printlnOverloads {
+		if len(overload.Args) != len(argTypes) {
+			continue
+		}
+		matches := true
+		for index, arg := range overload.Args {
+			// an argument without a type already has an error
+			if argTypes[index] != "" && argTypes[index] != arg.Type {
+				matches = false
+			}
+		}
+		if matches {
+			return overload, true
+		}
+	}
+	if len(argTypes) > 1 {
+		c.diagnostics.Error(diagnostics.ARGUMENT_COUNT, fc.Span, "too many arguments to call function %v", fc.CalledFunctionName).
+			Note("println takes at most one argument")
+		return Function{}, false
+	}
+	c.diagnostics.Error(diagnostics.TYPE_MISMATCH, fc.Arguments[0].GetSpan(), "cannot print a value of type %v", argTypes[0]).
+		Note("println accepts values of type int, long, float, double, bool and string")
+	return Function{}, false
+}
+
+// checkMath checks an operator and its operands and annotates every node with the type of its value
+func (c *Checker) checkMath(mxp *MathExpNode) string {
+	mxp.ValueType = c.mathType(mxp)
+	return mxp.ValueType
+}
+
+// mathType returns the type of the value of a math expression, + concatenates strings if one of its
+// operands is a string. Operators whose operands have errors have no type
+func (c *Checker) mathType(mxp *MathExpNode) string {
+	switch mxp.Kind {
+	case NUMBER:
+		if _, ok := mxp.literalValue(false, c.diagnostics); !ok {
+			return ""
+		}
+		return literalType(mxp.Number.Value)
+	case STRING:
+		if length := classfile.ModifiedUtf8Length(mxp.Number.Value); length > classfile.MAX_UTF8_LENGTH {
+			c.diagnostics.Error(diagnostics.STRING_TOO_LONG, mxp.Span, "string literal is too long (%v bytes, the limit is %v)", length, classfile.MAX_UTF8_LENGTH).
+				Note("the length is counted in modified UTF-8, which stores characters outside of ASCII in two to six bytes")
+		}
+		return "string"
+	case BOOLEAN:
+		return "bool"
+	case IDENTIFIER:
+		variable, ok := c.scope.lookup(mxp.Number.Value)
+		if !ok {
+			c.diagnostics.Error(diagnostics.UNDECLARED_VARIABLE, mxp.Span, "cannot use undeclared variable '%v'", mxp.Number.Value)
+			return ""
+		}
+		mxp.Variable = variable
+		return variable.Type
+	case FUNCTION_CALL:
+		return c.checkFunctionCall(&mxp.FuncCall)
+	case NEGATIVE, POSITIVE:
+		operand := mxp.Unary.Operand
+		// the literal is negated before its range is checked, so -2147483648 is valid
+		if mxp.Kind == NEGATIVE && operand.Kind == NUMBER {
+			if _, ok := operand.literalValue(true, c.diagnostics); !ok {
+				return ""
+			}
+			operand.ValueType = literalType(operand.Number.Value)
+			return operand.ValueType
+		}
+		c.checkMath(operand)
+		return c.numericOperands(operand)
+	case ADD:
+		left, right := c.checkMath(mxp.Binary.Left), c.checkMath(mxp.Binary.Right)
+		if left == "string" || right == "string" {
+			return c.concatOperands(mxp.Binary.Left, mxp.Binary.Right)
+		}
+		return c.numericOperands(mxp.Binary.Left, mxp.Binary.Right)
+	case SUB, MUL, DIV, MOD:
+		c.checkMath(mxp.Binary.Left)
+		c.checkMath(mxp.Binary.Right)
+		return c.numericOperands(mxp.Binary.Left, mxp.Binary.Right)
+	case POW:
+		c.checkMath(mxp.Binary.Left)
+		c.checkMath(mxp.Binary.Right)
+		typ := c.numericOperands(mxp.Binary.Left, mxp.Binary.Right)
+		// powers of constants are computed now, so 0 ** -1 is reported
+		if typ != "" && mxp.isConstant() {
+			mxp.ValueType = typ
+			if _, ok := mxp.constantValue(c.diagnostics); !ok {
+				return ""
+			}
+		}
+		return typ
+	case BIT_AND, BIT_OR, BIT_XOR:
+		// & | and ^ of bools do not short circuit
+		left, right := c.checkMath(mxp.Binary.Left), c.checkMath(mxp.Binary.Right)
+		if left == "bool" && right == "bool" {
+			return "bool"
+		}
+		operator := map[ExpNodeType]string{BIT_AND: "&", BIT_OR: "|", BIT_XOR: "^"}[mxp.Kind]
+		return c.integralOperands(operator, mxp.Binary.Left, mxp.Binary.Right)
+	case BIT_NOT:
+		c.checkMath(mxp.Unary.Operand)
+		return c.integralOperands("~", mxp.Unary.Operand)
+	case SHL, SHR, USHR:
+		// the type of a shift only depends on the shifted value
+		c.checkMath(mxp.Binary.Left)
+		c.checkMath(mxp.Binary.Right)
+		operator := map[ExpNodeType]string{SHL: "<<", SHR: ">>", USHR: ">>>"}[mxp.Kind]
+		typ := c.integralOperands(operator, mxp.Binary.Left)
+		if c.integralOperands(operator, mxp.Binary.Right) == "" {
+			return ""
+		}
+		return typ
+	case CAST:
+		return c.checkCast(mxp)
+	case EQUAL, NOT_EQUAL, LESS, LESS_EQUAL, GREATER, GREATER_EQUAL:
+		c.checkComparison(mxp)
+		return "bool"
+	case AND, OR:
+		operator := map[ExpNodeType]string{AND: "&&", OR: "||"}[mxp.Kind]
+		c.checkMath(mxp.Binary.Left)
+		c.checkMath(mxp.Binary.Right)
+		c.checkOperandType(mxp.Binary.Left, "bool", operator)
+		c.checkOperandType(mxp.Binary.Right, "bool", operator)
+		return "bool"
+	case NOT:
+		c.checkMath(mxp.Unary.Operand)
+		c.checkOperandType(mxp.Unary.Operand, "bool", "!")
+		return "bool"
+	}
+	c.diagnostics.Error(diagnostics.UNSUPPORTED, mxp.Span, "unsupported operation")
+	return ""
+}
+
+// numericOperands reports the checked operands of an arithmetic operator that are not numbers and
+// returns the type the operands are promoted to
+func (c *Checker) numericOperands(operands ...*MathExpNode) string {
+	result, ok := "int", true
+	for _, operand := range operands {
+		if operand.ValueType != "" && !isNumericType(operand.ValueType) {
+			c.diagnostics.Error(diagnostics.TYPE_MISMATCH, operand.Span, "cannot use a value of type %v in a mathmatical expression", operand.ValueType)
+		}
+		if !isNumericType(operand.ValueType) {
+			ok = false
+			continue
+		}
+		result = promotedType(result, operand.ValueType)
+	}
+	if !ok {
+		return ""
+	}
+	return result
+}
+
+// integralOperands is numericOperands for bitwise operators and shifts, which only take ints and longs
+func (c *Checker) integralOperands(operator string, operands ...*MathExpNode) string {
+	result, ok := "int", true
+	for _, operand := range operands {
+		if operand.ValueType != "int" && operand.ValueType != "long" {
+			if operand.ValueType != "" {
+				c.diagnostics.Error(diagnostics.TYPE_MISMATCH, operand.Span, "expected operand of type int or long for %v, found %v", operator, operand.ValueType)
+			}
+			ok = false
+			continue
+		}
+		result = promotedType(result, operand.ValueType)
+	}
+	if !ok {
+		return ""
+	}
+	return result
+}
+
+// concatOperands reports the checked operands of a string concatenation that have no value
+func (c *Checker) concatOperands(operands ...*MathExpNode) string {
+	result := "string"
+	for _, operand := range operands {
+		if operand.ValueType == "void" {
+			c.diagnostics.Error(diagnostics.TYPE_MISMATCH, operand.Span, "cannot concatenate a value of type void")
+		}
+		if operand.ValueType == "void" || operand.ValueType == "" {
+			result = ""
+		}
+	}
+	return result
+}
+
+// checkCast checks an as expression, numeric values can be converted to every other numeric type
+// and every other value only to its own type
+func (c *Checker) checkCast(mxp *MathExpNode) string {
+	from, target := c.checkMath(mxp.Unary.Operand), mxp.Number.Value
+	if _, err := typeDescriptor(target); err != nil {
+		c.diagnostics.Error(diagnostics.UNKNOWN_TYPE, mxp.Number.Span, "unknown type '%v'", target)
+		return ""
+	}
+	if from == "" || from == target {
+		return from
+	}
+	if !isNumericType(from) || !isNumericType(target) {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, mxp.Span, "cannot convert a value of type %v to %v", from, target).
+			Note("only numeric values can be converted with as")
+		return ""
+	}
+	return target
+}
+
+// checkComparison reports operands that cannot be compared, only numbers can be ordered and only
+// values of the same type or numbers can be compared for equality
+func (c *Checker) checkComparison(mxp *MathExpNode) {
+	left, right := c.checkMath(mxp.Binary.Left), c.checkMath(mxp.Binary.Right)
+	if mxp.Kind != EQUAL && mxp.Kind != NOT_EQUAL {
+		c.checkNumericOperand(mxp.Binary.Left, "comparison")
+		c.checkNumericOperand(mxp.Binary.Right, "comparison")
+	} else if left != "" && right != "" && (left != right || left == "void") && !(isNumericType(left) && isNumericType(right)) {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, mxp.Span, "cannot compare a value of type %v with a value of type %v", left, right)
+	}
+}
+
+// checkNumericOperand reports a checked operand that is not a number
+func (c *Checker) checkNumericOperand(operand *MathExpNode, operator string) {
+	if actual := operand.ValueType; actual != "" && !isNumericType(actual) {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, operand.Span, "expected numeric operand for %v, found %v", operator, actual)
+	}
+}
+
+// checkOperandType reports a checked operand that does not have the type an operator expects
+func (c *Checker) checkOperandType(operand *MathExpNode, typ, operator string) {
+	if actual := operand.ValueType; actual != "" && actual != typ {
+		c.diagnostics.Error(diagnostics.TYPE_MISMATCH, operand.Span, "expected operand of type %v for %v, found %v", typ, operator, actual)
+	}
+}
@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"compiler/instructions"
+)
+
+// comparisonJumps contains the instructions that jump if a comparison of two ints is true
+var comparisonJumps map[ExpNodeType]byte = map[ExpNodeType]byte{
+	EQUAL:         instructions.IF_ICMPEQ,
+	NOT_EQUAL:     instructions.IF_ICMPNE,
+	LESS:          instructions.IF_ICMPLT,
+	LESS_EQUAL:    instructions.IF_ICMPLE,
+	GREATER:       instructions.IF_ICMPGT,
+	GREATER_EQUAL: instructions.IF_ICMPGE,
+}
+
+// generateCondition generates code that jumps to target if the value of a bool expression
+// equals jumpIf and falls through otherwise
+func generateCondition(expr Expression, jumpIf bool, target instructions.Label, context *GeneratorContext) {
+	if expr.GetExpressionType() == MATH_EXP {
+		expr.(MathExpNode).generateJump(context, jumpIf, target)
+		return
+	}
+	generateExpression(expr, context)
+	conditionalJump(jumpIf, target, context)
+}
+
+// generateBoolValue lowers a condition to branches that leave 1 or 0 on the stack
+func (mxp MathExpNode) generateBoolValue(context *GeneratorContext) {
+	isFalse, end := context.Code.NewLabel(), context.Code.NewLabel()
+	mxp.generateJump(context, false, isFalse)
+	context.Code.Emit(instructions.ICONST_1)
+	context.Code.Jump(instructions.GOTO, end)
+	context.Code.Mark(isFalse)
+	context.Code.Emit(instructions.ICONST_0)
+	context.Code.Mark(end)
+}
+
+// generateJump is generateCondition for math expressions. The right operand of && and || is only
+// evaluated if the left one does not decide the result already
+func (mxp MathExpNode) generateJump(context *GeneratorContext, jumpIf bool, target instructions.Label) {
+	switch mxp.Kind {
+	case BOOLEAN:
+		// constant conditions do not need a jump, so the code after while true is unreachable
+		if (mxp.Number.Value == "true") == jumpIf {
+			context.Code.Jump(instructions.GOTO, target)
+		}
+		return
+	case NOT:
+		mxp.Unary.Operand.generateJump(context, !jumpIf, target)
+		return
+	case AND, OR:
+		// the left operand decides the result if it is false for && or true for ||
+		decidingValue := mxp.Kind == OR
+		if jumpIf == decidingValue {
+			mxp.Binary.Left.generateJump(context, decidingValue, target)
+			mxp.Binary.Right.generateJump(context, jumpIf, target)
+			return
+		}
+		end := context.Code.NewLabel()
+		mxp.Binary.Left.generateJump(context, decidingValue, end)
+		mxp.Binary.Right.generateJump(context, jumpIf, target)
+		context.Code.Mark(end)
+		return
+	case EQUAL, NOT_EQUAL, LESS, LESS_EQUAL, GREATER, GREATER_EQUAL:
+		mxp.generateComparison(context, jumpIf, target)
+		return
+	}
+	mxp.GenerateByteCode(context)
+	conditionalJump(jumpIf, target, context)
+}
+
+// generateComparison compares ints and bools with if_icmp, other numeric values are compared with
+// lcmp, fcmp or dcmp first and every other value with Objects.equals
+func (mxp MathExpNode) generateComparison(context *GeneratorContext, jumpIf bool, target instructions.Label) {
+	left, right := mxp.Binary.Left, mxp.Binary.Right
+	leftType, rightType := left.Type(), right.Type()
+	op := comparisonJumps[mxp.Kind]
+	typ := leftType
+	if isNumericType(leftType) && isNumericType(rightType) {
+		typ = promotedType(leftType, rightType)
+	}
+	left.GenerateByteCode(context)
+	generateConversion(leftType, typ, context)
+	right.GenerateByteCode(context)
+	generateConversion(rightType, typ, context)
+	if (mxp.Kind == EQUAL || mxp.Kind == NOT_EQUAL) && isReferenceType(leftType) {
+		descriptor := "(Ljava/lang/Object;Ljava/lang/Object;)Z"
+		context.Code.Invoke(instructions.INVOKESTATIC, context.Class.AddMethodRef("equals", descriptor, "java/util/Objects"), descriptor)
+		conditionalJump(jumpIf == (mxp.Kind == EQUAL), target, context)
+		return
+	}
+	if inst, ok := compareInstruction(typ, mxp.Kind); ok {
+		// the result of the compare instruction is compared with 0 instead of the second operand
+		context.Code.Emit(inst)
+		op = op - instructions.IF_ICMPEQ + instructions.IFEQ
+	}
+	if !jumpIf {
+		op = instructions.Negate(op)
+	}
+	context.Code.Jump(op, target)
+}
+
+// compareInstruction returns the instruction that compares two longs, floats or doubles. Comparisons
+// with NaN are false, so < and <= push 1 for NaN and every other comparison pushes -1
+func compareInstruction(typ string, kind ExpNodeType) (byte, bool) {
+	nanIsGreater := kind == LESS || kind == LESS_EQUAL
+	switch typ {
+	case "long":
+		return instructions.LCMP, true
+	case "float":
+		if nanIsGreater {
+			return instructions.FCMPG, true
+		}
+		return instructions.FCMPL, true
+	case "double":
+		if nanIsGreater {
+			return instructions.DCMPG, true
+		}
+		return instructions.DCMPL, true
+	}
+	return 0, false
+}
+
+// conditionalJump jumps if the bool on the stack equals jumpIf
+func conditionalJump(jumpIf bool, target instructions.Label, context *GeneratorContext) {
+	if jumpIf {
+		context.Code.Jump(instructions.IFNE, target)
+	} else {
+		context.Code.Jump(instructions.IFEQ, target)
+	}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"compiler/diagnostics"
+	"fmt"
+	"strings"
+)
+
+var primitiveDescriptors map[string]string = map[string]string{
+	"int":    "I",
+	"long":   "J",
+	"float":  "F",
+	"double": "D",
+	"bool":   "Z",
+	"void":   "V",
+}
+
+// maps the names of the built in class types of the language to their internal jvm names, it is never
+// changed because programs cannot declare classes
+var classTypes map[string]string = map[string]string{
+	"string": "java/lang/String",
+}
+
+// typeDescriptor converts a type of the language into a jvm field descriptor. Array types
+// are written as []T, fully qualified java classes like java.util.List can be used directly
+func typeDescriptor(typ string) (string, error) {
+	if elementType, ok := strings.CutPrefix(typ, "[]"); ok {
+		descriptor, err := typeDescriptor(elementType)
+		if err != nil {
+			return "", err
+		}
+		if descriptor == "V" {
+			return "", fmt.Errorf("cannot create an array of type void")
+		}
+		return "[" + descriptor, nil
+	}
+	if descriptor, ok := primitiveDescriptors[typ]; ok {
+		return descriptor, nil
+	}
+	if internalName, ok := classTypes[typ]; ok {
+		return "L" + internalName + ";", nil
+	}
+	if strings.Contains(typ, ".") && !strings.HasPrefix(typ, ".") && !strings.HasSuffix(typ, ".") {
+		return "L" + strings.ReplaceAll(typ, ".", "/") + ";", nil
+	}
+	return "", fmt.Errorf("unknown type '%v'", typ)
+}
+
+// functionDescriptor returns the descriptor of a function or an error for the first invalid type
+func functionDescriptor(args []FunctionArgument, retType string) (string, error) {
+	descriptor := "("
+	for _, arg := range args {
+		argDescriptor, err := typeDescriptor(arg.Type)
+		if err != nil {
+			return "", err
+		}
+		if argDescriptor == "V" {
+			return "", fmt.Errorf("argument '%v' cannot have the type void", arg.Name)
+		}
+		descriptor += argDescriptor
+	}
+	retDescriptor, err := typeDescriptor(retType)
+	if err != nil {
+		return "", err
+	}
+	return descriptor + ")" + retDescriptor, nil
+}
+
+// checkFunctionTypes reports every invalid type in the signature of a function definition
+func checkFunctionTypes(fd FunctionDefinition, diags *diagnostics.Diagnostics) {
+	for _, arg := range fd.Args {
+		argDescriptor, err := typeDescriptor(arg.Type)
+		if err != nil {
+			diags.Error(diagnostics.UNKNOWN_TYPE, arg.Span, "%v", err)
+		} else if argDescriptor == "V" {
+			diags.Error(diagnostics.UNKNOWN_TYPE, arg.Span, "argument '%v' cannot have the type void", arg.Name)
+		}
+	}
+	if _, err := typeDescriptor(fd.ReturnType); err != nil {
+		diags.Error(diagnostics.UNKNOWN_TYPE, fd.Span, "%v (return type of %v)", err, fd.Name)
+	}
+}
@@ -0,0 +1,30 @@
+package parser
+
+import "testing"
+
+func TestTypeDescriptor(t *testing.T) {
+	tests := []struct {
+		typ        string
+		descriptor string
+		valid      bool
+	}{
+		{"int", "I", true},
+		{"bool", "Z", true},
+		{"string", "Ljava/lang/String;", true},
+		{"[][]double", "[[D", true},
+		{"[]string", "[Ljava/lang/String;", true},
+		{"java.util.List", "Ljava/util/List;", true},
+		{"Test", "", false},
+		{"java.", "", false},
+		{"[]void", "", false},
+	}
+	for _, test := range tests {
+		descriptor, err := typeDescriptor(test.typ)
+		if test.valid && (err != nil || descriptor != test.descriptor) {
+			t.Errorf("expected %v to have the descriptor %v but got %v (%v)", test.typ, test.descriptor, descriptor, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected %v to be invalid but got %v", test.typ, descriptor)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/instructions"
+	"math"
+	"strings"
+)
+
+const (
+	STRING_BUILDER        = "java/lang/StringBuilder"
+	STRING_CONCAT_FACTORY = "java/lang/invoke/StringConcatFactory"
+	MAKE_CONCAT           = "makeConcatWithConstants"
+	MAKE_CONCAT_BOOTSTRAP = "(Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"
+	// StringConcatFactory accepts at most 200 argument slots, longer concatenations use a StringBuilder
+	MAX_CONCAT_SLOTS = 200
+)
+
+// expressionType returns the type of the value an expression leaves on the stack. It returns an
+// empty string for expressions the checker did not annotate
+func expressionType(expr Expression) string {
+	switch expr.GetExpressionType() {
+	case MATH_EXP:
+		return expr.(MathExpNode).Type()
+	case IDENTIFIER_EXP:
+		if variable := expr.(Identifier).Variable; variable != nil {
+			return variable.Type
+		}
+	case FUNCTIONCALL:
+		return expr.(FunctionCall).Type()
+	}
+	return ""
+}
+
+// generateExpression generates the bytecode that leaves the value of an expression on the stack
+func generateExpression(expr Expression, context *GeneratorContext) {
+	switch expr.GetExpressionType() {
+	case MATH_EXP:
+		expr.(MathExpNode).GenerateByteCode(context)
+		return
+	case IDENTIFIER_EXP:
+		loadVariable(*expr.(Identifier).Variable, context)
+		return
+	case FUNCTIONCALL:
+		expr.(FunctionCall).GenerateByteCode(context)
+		return
+	}
+	context.Diagnostics.Error(diagnostics.INTERNAL_ERROR, expr.GetSpan(), "unsupported expression type (%v)", expr.GetExpressionType())
+}
+
+// generateTypedExpression generates an expression that has to have the given type, numeric values
+// are widened implicitly
+func generateTypedExpression(expr Expression, typ string, context *GeneratorContext) {
+	generateExpression(expr, context)
+	generateConversion(expressionType(expr), typ, context)
+}
+
+// generateConcat joins the string representation of all operands, with invokedynamic on targets
+// that have StringConcatFactory and with a StringBuilder on older ones
+func generateConcat(operands []Expression, context *GeneratorContext) {
+	if context.Class.Target().SupportsStringConcatFactory() && concatSlots(operands) <= MAX_CONCAT_SLOTS &&
+		classfile.ModifiedUtf8Length(concatRecipe(operands)) <= classfile.MAX_UTF8_LENGTH {
+		generateDynamicConcat(operands, context)
+		return
+	}
+	context.Code.Emit(instructions.NEW, int(context.Class.AddClassRef(STRING_BUILDER)))
+	context.Code.Emit(instructions.DUP)
+	context.Code.Invoke(instructions.INVOKESPECIAL, context.Class.AddMethodRef("<init>", "()V", STRING_BUILDER), "()V")
+	for _, operand := range operands {
+		generateExpression(operand, context)
+		appendDescriptor := "(" + concatDescriptor(expressionType(operand)) + ")L" + STRING_BUILDER + ";"
+		context.Code.Invoke(instructions.INVOKEVIRTUAL, context.Class.AddMethodRef("append", appendDescriptor, STRING_BUILDER), appendDescriptor)
+	}
+	context.Code.Invoke(instructions.INVOKEVIRTUAL, context.Class.AddMethodRef("toString", "()Ljava/lang/String;", STRING_BUILDER), "()Ljava/lang/String;")
+}
+
+// generateDynamicConcat calls StringConcatFactory.makeConcatWithConstants. String literals become part
+// of the recipe and every other operand is passed as argument, which the recipe marks with \1
+func generateDynamicConcat(operands []Expression, context *GeneratorContext) {
+	descriptor := "("
+	for _, operand := range operands {
+		if !isRecipeLiteral(operand) {
+			generateExpression(operand, context)
+			descriptor += concatDescriptor(expressionType(operand))
+		}
+	}
+	descriptor += ")Ljava/lang/String;"
+	bootstrap := classfile.BootstrapMethod{
+		MethodHandle: context.Class.AddMethodHandle(classfile.REF_INVOKESTATIC, STRING_CONCAT_FACTORY, MAKE_CONCAT, MAKE_CONCAT_BOOTSTRAP),
+		Arguments:    []uint16{context.Class.AddString(concatRecipe(operands))},
+	}
+	context.Code.Invoke(instructions.INVOKEDYNAMIC, context.Class.AddInvokeDynamic(bootstrap, MAKE_CONCAT, descriptor), descriptor)
+}
+
+// concatRecipe joins the string literals of the operands, the other operands are marked with \1.
+// The recipe is a single constant, so concatenations with a longer recipe use a StringBuilder
+func concatRecipe(operands []Expression) string {
+	var recipe strings.Builder
+	for _, operand := range operands {
+		if isRecipeLiteral(operand) {
+			recipe.WriteString(operand.(MathExpNode).Number.Value)
+		} else {
+			recipe.WriteString("\x01")
+		}
+	}
+	return recipe.String()
+}
+
+// isRecipeLiteral reports whether an operand is a string literal that can be written into the recipe,
+// \1 and \2 are the markers for arguments and constants
+func isRecipeLiteral(operand Expression) bool {
+	literal, ok := operand.(MathExpNode)
+	return ok && literal.Kind == STRING && !strings.ContainsAny(literal.Number.Value, "\x01\x02")
+}
+
+// concatSlots counts the argument slots the operands take when they are passed to StringConcatFactory
+func concatSlots(operands []Expression) int {
+	slots := 0
+	for _, operand := range operands {
+		if !isRecipeLiteral(operand) {
+			slots += slotSize(expressionType(operand))
+		}
+	}
+	return slots
+}
+
+// concatDescriptor returns the descriptor a value of a type is appended with, values without a
+// special overload are appended as Object
+func concatDescriptor(typ string) string {
+	switch typ {
+	case "int", "long", "float", "double", "bool", "string":
+		descriptor, _ := typeDescriptor(typ)
+		return descriptor
+	}
+	return "Ljava/lang/Object;"
+}
+
+// loadInt pushes an int with the shortest instruction that can encode it, other values are loaded from the constant pool
+func loadInt(value int32, context *GeneratorContext) {
+	if inst, ok := instructions.Iconsts[value]; ok {
+		context.Code.Emit(inst)
+	} else if value >= math.MinInt8 && value <= math.MaxInt8 {
+		context.Code.Emit(instructions.BIPUSH, int(value))
+	} else if value >= math.MinInt16 && value <= math.MaxInt16 {
+		context.Code.Emit(instructions.SIPUSH, int(value))
+	} else {
+		context.Code.Emit(instructions.LDC, int(context.Class.AddInteger(value)))
+	}
+}
+
+// isReferenceType reports whether values of a type are stored as references
+func isReferenceType(typ string) bool {
+	descriptor, err := typeDescriptor(typ)
+	return err == nil && (descriptor[0] == 'L' || descriptor[0] == '[')
+}
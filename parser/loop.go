@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"compiler/instructions"
+	"compiler/source"
+)
+
+// Loop is a loop around the generated statement with the labels its break and continue statements jump to
+type Loop struct {
+	Name     string
+	Break    instructions.Label
+	Continue instructions.Label
+}
+
+type WhileStatement struct {
+	Label     Identifier
+	Condition Expression
+	Scope     Scope
+	Span      source.Span
+}
+
+func (ws WhileStatement) GetSpan() source.Span {
+	return ws.Span
+}
+
+func (ws WhileStatement) GetStatementType() string {
+	return WHILE_STMT
+}
+
+func (ws WhileStatement) GenerateByteCode(context *GeneratorContext) {
+	loop := Loop{Name: ws.Label.Value.Value, Break: context.Code.NewLabel(), Continue: context.Code.NewLabel()}
+	context.Code.Mark(loop.Continue)
+	generateCondition(ws.Condition, false, loop.Break, context)
+	generateLoopBody(loop, ws.Scope, context)
+	context.Code.Jump(instructions.GOTO, loop.Continue)
+	context.Code.Mark(loop.Break)
+}
+
+// ForStatement is a loop like for (init; condition; update) in C, every part of the header is optional
+type ForStatement struct {
+	Label     Identifier
+	Init      Statement
+	Condition Expression
+	Update    Statement
+	Scope     Scope
+	Span      source.Span
+}
+
+func (fs ForStatement) GetSpan() source.Span {
+	return fs.Span
+}
+
+func (fs ForStatement) GetStatementType() string {
+	return FOR_STMT
+}
+
+func (fs ForStatement) GenerateByteCode(context *GeneratorContext) {
+	if fs.Init != nil {
+		fs.Init.GenerateByteCode(context)
+	}
+	loop := Loop{Name: fs.Label.Value.Value, Break: context.Code.NewLabel(), Continue: context.Code.NewLabel()}
+	start := context.Code.NewLabel()
+	context.Code.Mark(start)
+	if fs.Condition != nil {
+		generateCondition(fs.Condition, false, loop.Break, context)
+	}
+	generateLoopBody(loop, fs.Scope, context)
+	context.Code.Mark(loop.Continue)
+	if fs.Update != nil {
+		fs.Update.GenerateByteCode(context)
+	}
+	context.Code.Jump(instructions.GOTO, start)
+	context.Code.Mark(loop.Break)
+}
+
+// RangeForStatement counts the variable from From up to To, To is not included. Both
+// bounds are evaluated once before the loop starts
+type RangeForStatement struct {
+	Label    Identifier
+	Variable Identifier
+	From     Expression
+	To       Expression
+	Scope    Scope
+	// Counter is the declared variable and End the local that keeps the end of the range, set by the checker
+	Counter *Variable
+	End     *Variable
+	Span    source.Span
+}
+
+func (rfs RangeForStatement) GetSpan() source.Span {
+	return rfs.Span
+}
+
+func (rfs RangeForStatement) GetStatementType() string {
+	return RANGE_FOR_STMT
+}
+
+func (rfs RangeForStatement) GenerateByteCode(context *GeneratorContext) {
+	variable, end := *rfs.Counter, *rfs.End
+	generateTypedExpression(rfs.From, "int", context)
+	generateTypedExpression(rfs.To, "int", context)
+	storeVariable(end, context)
+	storeVariable(variable, context)
+	loop := Loop{Name: rfs.Label.Value.Value, Break: context.Code.NewLabel(), Continue: context.Code.NewLabel()}
+	start := context.Code.NewLabel()
+	context.Code.Mark(start)
+	loadVariable(variable, context)
+	loadVariable(end, context)
+	context.Code.Jump(instructions.IF_ICMPGE, loop.Break)
+	generateLoopBody(loop, rfs.Scope, context)
+	context.Code.Mark(loop.Continue)
+	context.Code.Emit(instructions.IINC, variable.VariableIndex, 1)
+	context.Code.Jump(instructions.GOTO, start)
+	context.Code.Mark(loop.Break)
+}
+
+// LoopControl is a break or continue statement, without a label it belongs to the innermost loop
+type LoopControl struct {
+	IsBreak bool
+	Label   Identifier
+	Span    source.Span
+}
+
+func (lc LoopControl) GetSpan() source.Span {
+	return lc.Span
+}
+
+func (lc LoopControl) GetStatementType() string {
+	return LOOP_CONTROL
+}
+
+func (lc LoopControl) GenerateByteCode(context *GeneratorContext) {
+	// the checker made sure that the loop exists
+	loop := len(context.Loops) - 1
+	if label := lc.Label.Value.Value; label != "" {
+		for context.Loops[loop].Name != label {
+			loop--
+		}
+	}
+	if lc.IsBreak {
+		context.Code.Jump(instructions.GOTO, context.Loops[loop].Break)
+	} else {
+		context.Code.Jump(instructions.GOTO, context.Loops[loop].Continue)
+	}
+}
+
+// generateLoopBody generates the scope of a loop, its break and continue statements jump to the labels of the loop
+func generateLoopBody(loop Loop, scope Scope, context *GeneratorContext) {
+	context.Loops = append(context.Loops, loop)
+	scope.GenerateByteCode(context)
+	context.Loops = context.Loops[:len(context.Loops)-1]
+}
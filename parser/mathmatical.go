@@ -1,17 +1,12 @@
 package parser
 
 import (
+	"compiler/diagnostics"
 	"compiler/instructions"
+	"compiler/source"
 	"compiler/tokenizer"
-	"log"
-	"strconv"
 )
 
-type Variable struct {
-	VariableIndex int
-	Type          string
-}
-
 type ExpNodeType int
 
 const (
@@ -26,15 +21,43 @@ const (
 	POW
 	IDENTIFIER
 	FUNCTION_CALL
+	STRING
+	BOOLEAN
+	EQUAL
+	NOT_EQUAL
+	LESS
+	LESS_EQUAL
+	GREATER
+	GREATER_EQUAL
+	AND
+	OR
+	NOT
+	CAST
+	MOD
+	BIT_AND
+	BIT_OR
+	BIT_XOR
+	BIT_NOT
+	SHL
+	SHR
+	USHR
 )
 
 type precedence int
 
 const (
 	MIN precedence = iota
+	LOGICAL_OR
+	LOGICAL_AND
+	BITWISE_OR
+	BITWISE_XOR
+	BITWISE_AND
+	EQUALITY
+	COMPARISON
+	SHIFT
 	TERM
 	MULT
-	DIVI
+	CAST_PREC
 	POWER
 	MAX
 )
@@ -43,15 +66,38 @@ var precedenceLookupTable map[tokenizer.TokenType]precedence = map[tokenizer.Tok
 	tokenizer.PLUS:  TERM,
 	tokenizer.MINUS: TERM,
 	tokenizer.MUL:   MULT,
-	tokenizer.DIV:   DIVI,
+	tokenizer.DIV:   MULT,
+	tokenizer.MOD:   MULT,
 	tokenizer.POW:   POWER,
+	tokenizer.AS:    CAST_PREC,
+	tokenizer.SHL:   SHIFT,
+	tokenizer.SHR:   SHIFT,
+	tokenizer.USHR:  SHIFT,
+
+	// like in c the bitwise operators bind weaker than comparisons
+	tokenizer.BIT_AND: BITWISE_AND,
+	tokenizer.BIT_XOR: BITWISE_XOR,
+	tokenizer.BIT_OR:  BITWISE_OR,
+
+	tokenizer.OR:             LOGICAL_OR,
+	tokenizer.AND:            LOGICAL_AND,
+	tokenizer.EQUALS:         EQUALITY,
+	tokenizer.NOT_EQUALS:     EQUALITY,
+	tokenizer.LESS:           COMPARISON,
+	tokenizer.LESS_EQUALS:    COMPARISON,
+	tokenizer.GREATER:        COMPARISON,
+	tokenizer.GREATER_EQUALS: COMPARISON,
 }
 
 type MathExpNode struct {
 	Kind     ExpNodeType
 	Number   tokenizer.Token
 	FuncCall FunctionCall
-	Unary    struct {
+	// the variable of an identifier and the type of the value, set by the checker
+	Variable  *Variable
+	ValueType string
+	Span      source.Span
+	Unary     struct {
 		Operand *MathExpNode
 	}
 	Binary struct {
@@ -60,59 +106,110 @@ type MathExpNode struct {
 	}
 }
 
+func (mxp MathExpNode) GetSpan() source.Span {
+	return mxp.Span
+}
+
 func (mxp MathExpNode) GetExpressionType() string {
 	return MATH_EXP
 }
 
-func (mxp MathExpNode) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	if mxp.Kind == ADD {
-		byteCode = append(byteCode, mxp.getOperationArgsByteCode(context)...)
-		byteCode = append(byteCode, instructions.IADD)
+// Type returns the type of the value of the expression, + concatenates strings if one of its operands is a string
+func (mxp MathExpNode) Type() string {
+	return mxp.ValueType
+}
+
+func (mxp MathExpNode) GenerateByteCode(context *GeneratorContext) {
+	if mxp.Kind == ADD && mxp.Type() == "string" {
+		generateConcat(mxp.concatOperands(), context)
+	} else if mxp.Kind == ADD {
+		mxp.getOperationArgsByteCode(context)
+		context.Code.Emit(instructions.IADD + typeOffset(mxp.Type()))
 	} else if mxp.Kind == SUB {
-		byteCode = append(byteCode, mxp.getOperationArgsByteCode(context)...)
-		byteCode = append(byteCode, instructions.ISUB)
+		mxp.getOperationArgsByteCode(context)
+		context.Code.Emit(instructions.ISUB + typeOffset(mxp.Type()))
 	} else if mxp.Kind == MUL {
-		byteCode = append(byteCode, mxp.getOperationArgsByteCode(context)...)
-		byteCode = append(byteCode, instructions.IMUL)
+		mxp.getOperationArgsByteCode(context)
+		context.Code.Emit(instructions.IMUL + typeOffset(mxp.Type()))
 	} else if mxp.Kind == DIV {
-		byteCode = append(byteCode, mxp.getOperationArgsByteCode(context)...)
-		byteCode = append(byteCode, instructions.IDIV)
+		mxp.getOperationArgsByteCode(context)
+		context.Code.Emit(instructions.IDIV + typeOffset(mxp.Type()))
+	} else if mxp.Kind == MOD {
+		mxp.getOperationArgsByteCode(context)
+		context.Code.Emit(instructions.IREM + typeOffset(mxp.Type()))
+	} else if mxp.Kind == BIT_AND || mxp.Kind == BIT_OR || mxp.Kind == BIT_XOR || mxp.Kind == BIT_NOT {
+		mxp.generateBitwise(context)
+	} else if mxp.Kind == SHL || mxp.Kind == SHR || mxp.Kind == USHR {
+		mxp.generateShift(context)
+	} else if mxp.Kind == POW {
+		mxp.generatePower(context)
 	} else if mxp.Kind == NUMBER {
-		number, err := strconv.ParseInt(mxp.Number.Value, 10, 32)
-		if err != nil {
-			log.Fatalf("error: expected number")
+		mxp.generateLiteral(false, context)
+	} else if mxp.Kind == NEGATIVE && mxp.Unary.Operand.Kind == NUMBER {
+		// the literal is negated before its range is checked, so -2147483648 is valid
+		mxp.Unary.Operand.generateLiteral(true, context)
+	} else if mxp.Kind == NEGATIVE || mxp.Kind == POSITIVE {
+		mxp.Unary.Operand.generateConverted(mxp.Type(), context)
+		if mxp.Kind == NEGATIVE {
+			context.Code.Emit(instructions.INEG + typeOffset(mxp.Type()))
 		}
-		inst, ok := instructions.Iconsts[int32(number)]
-		if !ok {
-			log.Fatalf("error: number too large")
+	} else if mxp.Kind == CAST {
+		mxp.generateCast(context)
+	} else if mxp.Kind == BOOLEAN {
+		if mxp.Number.Value == "true" {
+			context.Code.Emit(instructions.ICONST_1)
+		} else {
+			context.Code.Emit(instructions.ICONST_0)
 		}
-		byteCode = append(byteCode, inst)
+	} else if mxp.Kind >= EQUAL && mxp.Kind <= NOT {
+		mxp.generateBoolValue(context)
+	} else if mxp.Kind == STRING {
+		context.Code.Emit(instructions.LDC, int(context.Class.AddString(mxp.Number.Value)))
 	} else if mxp.Kind == IDENTIFIER {
-		variable, ok := context.Variables[mxp.Number.Value]
-		if !ok {
-			log.Fatalf("error: cannot use undeclared variable '%v'", mxp.Number.Value)
-		}
-		byteCode = append(byteCode, loadVariable(variable)...)
+		loadVariable(*mxp.Variable, context)
 	} else if mxp.Kind == FUNCTION_CALL {
-		fun, ok := discoveredFunctions[mxp.FuncCall.CalledFunctionName]
-		if !ok {
-			log.Fatalf("error: cannot call undefined function %v", mxp.FuncCall.CalledFunctionName)
-		}
-		if fun.ReturnType != "int" {
-			log.Fatalf("error: cannot use function with return type %v in a mathmatical expression", fun.ReturnType)
-		}
-		byteCode = append(byteCode, mxp.FuncCall.GenerateByteCode(context)...)
+		mxp.FuncCall.GenerateByteCode(context)
+	} else {
+		context.Diagnostics.Error(diagnostics.INTERNAL_ERROR, mxp.Span, "unsupported operation")
+	}
+}
+
+// getOperationArgsByteCode generates both operands of an arithmetic operation and converts them to the type of the result
+func (mxp MathExpNode) getOperationArgsByteCode(context *GeneratorContext) {
+	mxp.generateOperands(mxp.Type(), context)
+}
+
+// generateOperands generates both operands of a binary operation and converts them to typ
+func (mxp MathExpNode) generateOperands(typ string, context *GeneratorContext) {
+	mxp.Binary.Left.generateConverted(typ, context)
+	mxp.Binary.Right.generateConverted(typ, context)
+}
+
+// generateConverted generates the expression and converts its numeric value to typ
+func (mxp MathExpNode) generateConverted(typ string, context *GeneratorContext) {
+	mxp.GenerateByteCode(context)
+	generateConversion(mxp.Type(), typ, context)
+}
+
+// concatOperands returns the operands of a chain of string concatenations, so they can be appended to a single StringBuilder
+func (mxp MathExpNode) concatOperands() []Expression {
+	if mxp.Kind != ADD || mxp.Type() != "string" {
+		return []Expression{mxp}
 	}
-	return byteCode
+	return append(mxp.Binary.Left.concatOperands(), mxp.Binary.Right.concatOperands()...)
 }
 
-func (mxp MathExpNode) getOperationArgsByteCode(context *GeneratorContext) (byteCode []byte) {
-	byteCode = make([]byte, 0)
-	number1, number2 := mxp.Binary.Left.GenerateByteCode(context), mxp.Binary.Right.GenerateByteCode(context)
-	byteCode = append(byteCode, number1...)
-	byteCode = append(byteCode, number2...)
-	return
+// mathOperand wraps an expression that is not a math expression, so it can be the operand of an operator
+func mathOperand(expr Expression) *MathExpNode {
+	switch e := expr.(type) {
+	case MathExpNode:
+		return &e
+	case Identifier:
+		return &MathExpNode{Kind: IDENTIFIER, Number: e.Value, Span: e.Span}
+	case FunctionCall:
+		return &MathExpNode{Kind: FUNCTION_CALL, FuncCall: e, Span: e.Span}
+	}
+	return &MathExpNode{Kind: ERROR, Span: expr.GetSpan()}
 }
 
 type MathmaticalParser struct {
@@ -130,9 +227,7 @@ func (mp MathmaticalParser) Parse() MathExpNode {
 func (mp MathmaticalParser) parseExpression(curOpPrecedence precedence) *MathExpNode {
 	left := mp.parsePrefixExpression()
 	nextOp, err := mp.parser.reader.ReadToken()
-	if err != nil {
-		log.Fatalf("error: %v", err)
-	}
+	mp.parser.isUnexpectedEndOfInput(err)
 	nextOpPrecedence := getPrecedenceOfOp(nextOp.Type)
 	for nextOpPrecedence != MIN {
 		if curOpPrecedence >= nextOpPrecedence {
@@ -141,9 +236,7 @@ func (mp MathmaticalParser) parseExpression(curOpPrecedence precedence) *MathExp
 			mp.parser.reader.NextToken()
 			left = mp.parseInfixExpression(nextOp, left)
 			nextOp, err = mp.parser.reader.ReadToken()
-			if err != nil {
-				log.Fatalf("error: %v", err)
-			}
+			mp.parser.isUnexpectedEndOfInput(err)
 			nextOpPrecedence = getPrecedenceOfOp(nextOp.Type)
 		}
 	}
@@ -153,42 +246,64 @@ func (mp MathmaticalParser) parseExpression(curOpPrecedence precedence) *MathExp
 func (mp MathmaticalParser) parsePrefixExpression() *MathExpNode {
 	ret := MathExpNode{Kind: ERROR}
 	curr, err := mp.parser.reader.ReadToken()
-	if err != nil {
-		log.Fatalf("error: %v", err)
-	}
+	mp.parser.isUnexpectedEndOfInput(err)
 	if curr.Type == tokenizer.NUMBER {
-		ret = MathExpNode{Kind: NUMBER, Number: curr}
+		ret = MathExpNode{Kind: NUMBER, Number: curr, Span: curr.Span}
+		mp.parser.reader.NextToken()
+	} else if curr.Type == tokenizer.STRING {
+		ret = MathExpNode{Kind: STRING, Number: curr, Span: curr.Span}
+		mp.parser.reader.NextToken()
+	} else if curr.Type == tokenizer.BOOLEAN {
+		ret = MathExpNode{Kind: BOOLEAN, Number: curr, Span: curr.Span}
 		mp.parser.reader.NextToken()
 	} else if curr.Type == tokenizer.IDENTIFIER {
 		next, err := mp.parser.reader.ReadTokenAtOffset(1)
-		isUnexpectedEndOfInput(err)
+		mp.parser.isUnexpectedEndOfInput(err)
 		if next.Type == tokenizer.OPEN_PAR {
 			mp.parser.reader.NextToken()
 			fc := parseFunctionCallExp(mp.parser, curr, true)
-			return &MathExpNode{Kind: FUNCTION_CALL, FuncCall: fc.(FunctionCall)}
+			return &MathExpNode{Kind: FUNCTION_CALL, FuncCall: fc.(FunctionCall), Span: fc.GetSpan()}
 		}
-		ret = MathExpNode{Kind: IDENTIFIER, Number: curr}
+		ret = MathExpNode{Kind: IDENTIFIER, Number: curr, Span: curr.Span}
 		mp.parser.reader.NextToken()
 	} else if curr.Type == tokenizer.OPEN_PAR {
 		mp.parser.reader.NextToken()
 		ret = *mp.parseExpression(MIN)
 		temp, err := mp.parser.reader.ReadToken()
-		if err != nil {
-			log.Fatalf("error: %v", err)
-		}
-		if temp.Type == tokenizer.CLOSE_PAR {
-			mp.parser.reader.NextToken()
+		mp.parser.isUnexpectedEndOfInput(err)
+		if temp.Type != tokenizer.CLOSE_PAR {
+			mp.parser.fail(diagnostics.EXPECTED_TOKEN, temp.Span, "expected ')'")
 		}
+		mp.parser.reader.NextToken()
+		ret.Span = mp.parser.spanFrom(curr.Span)
 	} else if curr.Type == tokenizer.PLUS {
 		mp.parser.reader.NextToken()
-		ret = MathExpNode{Kind: POSITIVE, Unary: struct{ Operand *MathExpNode }{Operand: mp.parsePrefixExpression()}}
+		ret = MathExpNode{Kind: POSITIVE, Unary: struct{ Operand *MathExpNode }{Operand: mp.parseUnaryOperand()}}
+		ret.Span = curr.Span.To(ret.Unary.Operand.Span)
 	} else if curr.Type == tokenizer.MINUS {
 		mp.parser.reader.NextToken()
-		ret = MathExpNode{Kind: NEGATIVE, Unary: struct{ Operand *MathExpNode }{Operand: mp.parsePrefixExpression()}}
+		ret = MathExpNode{Kind: NEGATIVE, Unary: struct{ Operand *MathExpNode }{Operand: mp.parseUnaryOperand()}}
+		ret.Span = curr.Span.To(ret.Unary.Operand.Span)
+	} else if curr.Type == tokenizer.NOT {
+		mp.parser.reader.NextToken()
+		ret = MathExpNode{Kind: NOT, Unary: struct{ Operand *MathExpNode }{Operand: mp.parseUnaryOperand()}}
+		ret.Span = curr.Span.To(ret.Unary.Operand.Span)
+	} else if curr.Type == tokenizer.BIT_NOT {
+		mp.parser.reader.NextToken()
+		ret = MathExpNode{Kind: BIT_NOT, Unary: struct{ Operand *MathExpNode }{Operand: mp.parseUnaryOperand()}}
+		ret.Span = curr.Span.To(ret.Unary.Operand.Span)
+	} else {
+		mp.parser.fail(diagnostics.EXPECTED_EXPRESSION, curr.Span, "expected expression")
 	}
 	return &ret
 }
 
+// parseUnaryOperand parses the operand of a prefix operator. ** binds tighter than the prefix
+// operators like in python, so -2 ** 2 is -(2 ** 2)
+func (mp MathmaticalParser) parseUnaryOperand() *MathExpNode {
+	return mp.parseExpression(POWER - 1)
+}
+
 func getPrecedenceOfOp(t tokenizer.TokenType) precedence {
 	value, ok := precedenceLookupTable[t]
 	if !ok {
@@ -208,10 +323,54 @@ func (mp MathmaticalParser) parseInfixExpression(op tokenizer.Token, left *MathE
 		ret.Kind = MUL
 	case tokenizer.DIV:
 		ret.Kind = DIV
+	case tokenizer.MOD:
+		ret.Kind = MOD
+	case tokenizer.BIT_AND:
+		ret.Kind = BIT_AND
+	case tokenizer.BIT_OR:
+		ret.Kind = BIT_OR
+	case tokenizer.BIT_XOR:
+		ret.Kind = BIT_XOR
+	case tokenizer.SHL:
+		ret.Kind = SHL
+	case tokenizer.SHR:
+		ret.Kind = SHR
+	case tokenizer.USHR:
+		ret.Kind = USHR
 	case tokenizer.POW:
 		ret.Kind = POW
+	case tokenizer.EQUALS:
+		ret.Kind = EQUAL
+	case tokenizer.NOT_EQUALS:
+		ret.Kind = NOT_EQUAL
+	case tokenizer.LESS:
+		ret.Kind = LESS
+	case tokenizer.LESS_EQUALS:
+		ret.Kind = LESS_EQUAL
+	case tokenizer.GREATER:
+		ret.Kind = GREATER
+	case tokenizer.GREATER_EQUALS:
+		ret.Kind = GREATER_EQUAL
+	case tokenizer.AND:
+		ret.Kind = AND
+	case tokenizer.OR:
+		ret.Kind = OR
+	case tokenizer.AS:
+		// the right side of as is a type and not an expression
+		start := mp.parser.currentSpan()
+		typ := mp.parser.parseType()
+		ret = MathExpNode{Kind: CAST, Number: tokenizer.Token{Value: typ, Type: tokenizer.IDENTIFIER, Span: mp.parser.spanFrom(start)}}
+		ret.Unary.Operand = left
+		ret.Span = mp.parser.spanFrom(left.Span)
+		return &ret
 	}
 	ret.Binary.Left = left
-	ret.Binary.Right = mp.parseExpression(getPrecedenceOfOp(op.Type))
+	rightPrecedence := getPrecedenceOfOp(op.Type)
+	// ** is right associative, so a ** b ** c is a ** (b ** c) and the right operand takes the next ** as well
+	if op.Type == tokenizer.POW {
+		rightPrecedence--
+	}
+	ret.Binary.Right = mp.parseExpression(rightPrecedence)
+	ret.Span = left.Span.To(ret.Binary.Right.Span)
 	return &ret
 }
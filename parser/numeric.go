@@ -0,0 +1,221 @@
+package parser
+
+import (
+	"compiler/diagnostics"
+	"compiler/instructions"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// numericRanks orders the numeric types, a value can be widened to every type with a higher rank
+var numericRanks map[string]int = map[string]int{
+	"int":    0,
+	"long":   1,
+	"float":  2,
+	"double": 3,
+}
+
+// conversions contains the instructions that convert a value between two numeric types
+var conversions map[[2]string]byte = map[[2]string]byte{
+	{"int", "long"}:     instructions.I2L,
+	{"int", "float"}:    instructions.I2F,
+	{"int", "double"}:   instructions.I2D,
+	{"long", "int"}:     instructions.L2I,
+	{"long", "float"}:   instructions.L2F,
+	{"long", "double"}:  instructions.L2D,
+	{"float", "int"}:    instructions.F2I,
+	{"float", "long"}:   instructions.F2L,
+	{"float", "double"}: instructions.F2D,
+	{"double", "int"}:   instructions.D2I,
+	{"double", "long"}:  instructions.D2L,
+	{"double", "float"}: instructions.D2F,
+}
+
+func isNumericType(typ string) bool {
+	_, ok := numericRanks[typ]
+	return ok
+}
+
+// promotedType returns the type both operands of an arithmetic operation are converted to
+func promotedType(left, right string) string {
+	result := "int"
+	for _, typ := range []string{left, right} {
+		if rank, ok := numericRanks[typ]; ok && rank > numericRanks[result] {
+			result = typ
+		}
+	}
+	return result
+}
+
+// canWiden reports whether a value can be converted implicitly without losing its magnitude
+func canWiden(from, to string) bool {
+	fromRank, fromOk := numericRanks[from]
+	toRank, toOk := numericRanks[to]
+	return fromOk && toOk && fromRank <= toRank
+}
+
+// typeOffset returns the distance of the typed variant of an instruction from its int variant,
+// for example ILOAD+typeOffset("double") is DLOAD and IADD+typeOffset("long") is LADD
+func typeOffset(typ string) byte {
+	if rank, ok := numericRanks[typ]; ok {
+		return byte(rank)
+	}
+	if isReferenceType(typ) {
+		return 4
+	}
+	return 0
+}
+
+// generateConversion converts the numeric value on the stack to another numeric type
+func generateConversion(from, to string, context *GeneratorContext) {
+	if inst, ok := conversions[[2]string{from, to}]; ok {
+		context.Code.Emit(inst)
+	}
+}
+
+// literalType returns the type of a number literal, the suffixes l, f and d select long, float and
+// double. Literals with a fraction or an exponent are doubles
+func literalType(literal string) string {
+	switch literal[len(literal)-1] {
+	case 'l', 'L':
+		return "long"
+	case 'f', 'F':
+		return "float"
+	case 'd', 'D':
+		return "double"
+	}
+	if strings.ContainsAny(literal, ".eE") {
+		return "double"
+	}
+	return "int"
+}
+
+// generateLiteral pushes the value of a number literal
+func (mxp MathExpNode) generateLiteral(negate bool, context *GeneratorContext) {
+	if value, ok := mxp.literalValue(negate, context.Diagnostics); ok {
+		loadConstant(value, context)
+	}
+}
+
+// literalValue returns the value of a number literal as int32, int64, float32 or float64. The value
+// has to fit into its type after it is negated, otherwise the problem is reported
+func (mxp MathExpNode) literalValue(negate bool, diags *diagnostics.Diagnostics) (any, bool) {
+	literal := mxp.Number.Value
+	typ := literalType(literal)
+	if strings.ContainsAny(literal[len(literal)-1:], "lLfFdD") {
+		literal = literal[:len(literal)-1]
+	}
+	if negate {
+		literal = "-" + literal
+	}
+	switch typ {
+	case "int":
+		number, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil || number < math.MinInt32 || number > math.MaxInt32 {
+			diagnostic := diags.Error(diagnostics.INVALID_NUMBER, mxp.Span, "integer literal %v does not fit into an int", literal).
+				Note("values of type int range from %v to %v", math.MinInt32, math.MaxInt32)
+			if err == nil {
+				diagnostic.Note("use the suffix L for long literals")
+			}
+			return nil, false
+		}
+		return int32(number), true
+	case "long":
+		number, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			diags.Error(diagnostics.INVALID_NUMBER, mxp.Span, "integer literal %v does not fit into a long", literal).
+				Note("values of type long range from %v to %v", math.MinInt64, math.MaxInt64)
+			return nil, false
+		}
+		return number, true
+	}
+	bits := 64
+	if typ == "float" {
+		bits = 32
+	}
+	number, err := strconv.ParseFloat(literal, bits)
+	if err != nil && math.IsInf(number, 0) {
+		diags.Error(diagnostics.INVALID_NUMBER, mxp.Span, "floating point literal %v is too large for a %v", literal, typ)
+		return nil, false
+	}
+	mantissa, _, _ := strings.Cut(strings.ToLower(literal), "e")
+	if number == 0 && strings.ContainsAny(mantissa, "123456789") {
+		diags.Error(diagnostics.INVALID_NUMBER, mxp.Span, "floating point literal %v is too small for a %v", literal, typ)
+		return nil, false
+	}
+	if typ == "float" {
+		return float32(number), true
+	}
+	return number, true
+}
+
+// loadConstant pushes a constant value of one of the types literalValue returns
+func loadConstant(value any, context *GeneratorContext) {
+	switch v := value.(type) {
+	case int32:
+		loadInt(v, context)
+	case int64:
+		loadLong(v, context)
+	case float32:
+		loadFloat(v, context)
+	case float64:
+		loadDouble(v, context)
+	}
+}
+
+// loadLong pushes a long with lconst if possible, other values are loaded from the constant pool
+func loadLong(value int64, context *GeneratorContext) {
+	if value == 0 || value == 1 {
+		context.Code.Emit(instructions.LCONST_0 + byte(value))
+		return
+	}
+	context.Code.Emit(instructions.LDC2_W, int(context.Class.AddLong(value)))
+}
+
+// loadFloat pushes a float with fconst if possible, other values are loaded from the constant pool
+func loadFloat(value float32, context *GeneratorContext) {
+	if (value == 0 && !math.Signbit(float64(value))) || value == 1 || value == 2 {
+		context.Code.Emit(instructions.FCONST_0 + byte(value))
+		return
+	}
+	context.Code.Emit(instructions.LDC, int(context.Class.AddFloat(value)))
+}
+
+// loadDouble pushes a double with dconst if possible, other values are loaded from the constant pool
+func loadDouble(value float64, context *GeneratorContext) {
+	if (value == 0 && !math.Signbit(value)) || value == 1 {
+		context.Code.Emit(instructions.DCONST_0 + byte(value))
+		return
+	}
+	context.Code.Emit(instructions.LDC2_W, int(context.Class.AddDouble(value)))
+}
+
+// generateCast converts the operand of an as expression to the target type
+func (mxp MathExpNode) generateCast(context *GeneratorContext) {
+	mxp.Unary.Operand.generateConverted(mxp.Type(), context)
+}
+
+// generateBitwise generates &, |, ^ and ~ of ints and longs, ~x is computed as x ^ -1. & | and ^ of
+// bools work like for ints because bools are 0 or 1
+func (mxp MathExpNode) generateBitwise(context *GeneratorContext) {
+	typ := mxp.Type()
+	if mxp.Kind == BIT_NOT {
+		mxp.Unary.Operand.generateConverted(typ, context)
+		loadConstant(convertConstant(int32(-1), typ), context)
+		context.Code.Emit(instructions.IXOR + typeOffset(typ))
+		return
+	}
+	mxp.generateOperands(typ, context)
+	inst := map[ExpNodeType]byte{BIT_AND: instructions.IAND, BIT_OR: instructions.IOR, BIT_XOR: instructions.IXOR}[mxp.Kind]
+	context.Code.Emit(inst + typeOffset(typ))
+}
+
+// generateShift generates <<, >> and >>>, the shift distance is always converted to an int
+func (mxp MathExpNode) generateShift(context *GeneratorContext) {
+	typ := mxp.Type()
+	mxp.Binary.Left.generateConverted(typ, context)
+	mxp.Binary.Right.generateConverted("int", context)
+	inst := map[ExpNodeType]byte{SHL: instructions.ISHL, SHR: instructions.ISHR, USHR: instructions.IUSHR}[mxp.Kind]
+	context.Code.Emit(inst + typeOffset(typ))
+}
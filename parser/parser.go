@@ -2,44 +2,92 @@ package parser
 
 import (
 	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/source"
 	"compiler/tokenizer"
-	"log"
+	"strings"
 )
 
 type Parser struct {
-	Source []tokenizer.Token
-	reader tokenizer.TokenReader
-	class  *classfile.Class
+	Source      []tokenizer.Token
+	reader      tokenizer.TokenReader
+	class       *classfile.Class
+	diagnostics *diagnostics.Diagnostics
+	// doc comments by the index of the fun token they belong to
+	docComments map[int]string
+	// set once the end of input error was reported
+	reachedEndOfInput bool
+	// functions defined so far and println, by their name
+	functions map[string]Function
 }
 
-var discoveredFunctions map[string]Function = make(map[string]Function)
+// parseError is used to unwind the parser after a syntax error was reported
+type parseError struct{}
 
-func NewParser(src []tokenizer.Token, class *classfile.Class) Parser {
-	discoveredFunctions["println"] = Function{
+func NewParser(src []tokenizer.Token, class *classfile.Class, diags *diagnostics.Diagnostics) Parser {
+	functions := make(map[string]Function)
+	functions[PRINTLN_FUNCTION] = Function{
 		ReturnType: "void",
 		Args: []FunctionArgument{
 			{Name: "value", Type: "int"},
 		},
 	}
-	return Parser{Source: src, reader: tokenizer.NewTokenReader(src), class: class}
+	tokens, docComments := collectDocComments(src, diags)
+	return Parser{Source: tokens, reader: tokenizer.NewTokenReader(tokens), class: class, diagnostics: diags, docComments: docComments, functions: functions}
+}
+
+// collectDocComments removes the doc comment tokens and joins the lines of each doc comment,
+// which is attached to the function definition that follows it
+func collectDocComments(src []tokenizer.Token, diags *diagnostics.Diagnostics) ([]tokenizer.Token, map[int]string) {
+	tokens := make([]tokenizer.Token, 0, len(src))
+	docComments := make(map[int]string)
+	lines := make([]string, 0)
+	var span source.Span
+	for _, token := range src {
+		if token.Type == tokenizer.DOC_COMMENT {
+			if len(lines) == 0 {
+				span = token.Span
+			}
+			span = span.To(token.Span)
+			lines = append(lines, token.Value)
+			continue
+		}
+		if len(lines) > 0 {
+			if token.Type == tokenizer.FUN_DEF {
+				docComments[len(tokens)] = strings.Join(lines, "\n")
+			} else {
+				diags.Warning(diagnostics.UNUSED_DOC_COMMENT, span, "unused doc comment").
+					Note("doc comments can only document function definitions, use // for other comments")
+			}
+			lines = lines[:0]
+		}
+		tokens = append(tokens, token)
+	}
+	if len(lines) > 0 {
+		diags.Warning(diagnostics.UNUSED_DOC_COMMENT, span, "unused doc comment").
+			Note("doc comments can only document function definitions, use // for other comments")
+	}
+	return tokens, docComments
 }
 
 func (p *Parser) parseExpression() Expression {
 	prev, err := p.reader.ReadTokenAtOffset(-1)
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	cur, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	p.reader.NextToken()
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	if isStartOfMathExp(cur, next) {
 		p.reader.UnreadToken()
 		return NewMathmaticalParser(p).Parse()
 	} else if isFunctionCallStart(cur, next, prev) {
 		return parseFunctionCallExp(p, cur, false)
 	} else if cur.Type == tokenizer.IDENTIFIER {
-		return Identifier{Value: cur}
+		return Identifier{Value: cur, Span: cur.Span}
 	}
+	// the token may be the ';' or '}' the parser synchronizes on after the error
+	p.reader.UnreadToken()
 	return nil
 }
 
@@ -48,21 +96,26 @@ func parseFunctionCallExp(p *Parser, cur tokenizer.Token, isInMathmeticalExp boo
 	p.reader.NextToken()
 	functionName := cur.Value
 	args := make([]Expression, 0)
-	parseFuncCallArgs(p, &args)
-	p.reader.NextToken()
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
+	if next.Type != tokenizer.CLOSE_PAR {
+		parseFuncCallArgs(p, &args)
+	}
+	p.reader.NextToken()
+	next, err = p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
 	if tokenizer.IsOperator(next) && !isInMathmeticalExp {
 		positionOfOp := p.reader.GetCurrentPosition()
 		p.reader.UnreadTokens(positionOfOp - positionOfFuncName)
 		mathExp := NewMathmaticalParser(p).Parse()
 		return mathExp
 	}
-	return FunctionCall{CalledFunctionName: functionName, Arguments: args}
+	return FunctionCall{CalledFunctionName: functionName, Arguments: args, Span: p.spanFrom(cur.Span)}
 }
 
 func isStartOfMathExp(cur, next tokenizer.Token) bool {
-	return cur.Type == tokenizer.NUMBER || cur.Type == tokenizer.PLUS ||
+	return cur.Type == tokenizer.NUMBER || cur.Type == tokenizer.STRING || cur.Type == tokenizer.BOOLEAN ||
+		cur.Type == tokenizer.NOT || cur.Type == tokenizer.BIT_NOT || cur.Type == tokenizer.PLUS ||
 		cur.Type == tokenizer.MINUS || cur.Type == tokenizer.OPEN_PAR ||
 		(cur.Type == tokenizer.IDENTIFIER && tokenizer.IsOperator(next))
 }
@@ -71,15 +124,43 @@ func isFunctionCallStart(cur, next, prev tokenizer.Token) bool {
 	return cur.Type == tokenizer.IDENTIFIER && next.Type == tokenizer.OPEN_PAR && prev.Type != tokenizer.FUN_DEF
 }
 
-func isSemicolon(t tokenizer.Token) {
+func (p *Parser) isSemicolon(t tokenizer.Token) {
 	if t.Type != tokenizer.SEMICOLON {
-		log.Fatalf("error: expected ';'")
+		end := t.Span.Start
+		if prev, err := p.reader.ReadTokenAtOffset(-1); err == nil {
+			end = prev.Span.End
+		}
+		p.diagnostics.Error(diagnostics.EXPECTED_TOKEN, t.Span, "expected ';'").
+			Suggest(source.Span{Start: end, End: end}, ";", "add ';' here")
+		panic(parseError{})
+	}
+}
+
+// fail reports a syntax error and stops parsing
+func (p *Parser) fail(code string, span source.Span, format string, args ...any) {
+	p.diagnostics.Error(code, span, format, args...)
+	panic(parseError{})
+}
+
+// currentSpan returns the span of the next token or the end of the input
+func (p *Parser) currentSpan() source.Span {
+	if cur, err := p.reader.ReadToken(); err == nil {
+		return cur.Span
+	}
+	return p.endOfInput()
+}
+
+func (p *Parser) endOfInput() source.Span {
+	if len(p.Source) == 0 {
+		return source.Span{}
 	}
+	end := p.Source[len(p.Source)-1].Span.End
+	return source.Span{Start: end, End: end}
 }
 
 func (p *Parser) parseStatement() Statement {
 	cur, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	p.reader.NextToken()
 	if cur.Type == tokenizer.RETURN {
 		return parseReturnStatement(p)
@@ -87,221 +168,563 @@ func (p *Parser) parseStatement() Statement {
 		return parseVarDecl(p)
 	} else if cur.Type == tokenizer.FUN_DEF {
 		return parseFunDef(p)
+	} else if cur.Type == tokenizer.IF {
+		return parseIfStatement(p)
+	} else if cur.Type == tokenizer.WHILE || cur.Type == tokenizer.FOR {
+		p.reader.UnreadToken()
+		return p.parseLoop(Identifier{})
+	} else if cur.Type == tokenizer.BREAK || cur.Type == tokenizer.CONTINUE {
+		return parseLoopControl(p, cur)
 	} else if cur.Type == tokenizer.IDENTIFIER {
 		next, err := p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type == tokenizer.LABEL_COLON {
+			p.reader.NextToken()
+			return p.parseLoop(Identifier{Value: cur, Span: cur.Span})
+		}
+		stmt := p.parseSimpleStatement(cur)
+		p.expectSemicolon()
+		return stmt
+	}
+	p.fail(diagnostics.EXPECTED_STATEMENT, cur.Span, "could not identify statement")
+	return nil
+}
+
+// parseSimpleStatement parses an assignment or a function call without the following ';',
+// so it can also be used in the header of a for loop
+func (p *Parser) parseSimpleStatement(cur tokenizer.Token) Statement {
+	next, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	p.reader.NextToken()
+	if next.Type == tokenizer.ASSIGN {
+		return parseVarReassignment(p, cur)
+	} else if _, ok := compoundOperators[next.Type]; ok {
+		return parseCompoundAssignment(p, cur, next)
+	} else if next.Type == tokenizer.OPEN_PAR {
+		return parseFunctionCall(p, cur)
+	}
+	p.fail(diagnostics.EXPECTED_STATEMENT, cur.Span, "could not identify statement")
+	return nil
+}
+
+func (p *Parser) expectSemicolon() {
+	next, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	p.isSemicolon(next)
+	p.reader.NextToken()
+}
+
+// parseStatementWithRecovery parses a statement, after a syntax error the tokens up to the next
+// ';', '}' or 'fun' are skipped and an ErrorStatement takes the place of the statement
+func (p *Parser) parseStatementWithRecovery(isTopLevel bool) (stmt Statement) {
+	start := p.reader.GetCurrentPosition()
+	startSpan := p.currentSpan()
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, ok := r.(parseError); !ok {
+			panic(r)
+		}
+		p.synchronize(isTopLevel)
+		if p.reader.GetCurrentPosition() == start {
+			p.reader.NextToken()
+		}
+		stmt = ErrorStatement{Span: p.spanFrom(startSpan)}
+	}()
+	return p.parseStatement()
+}
+
+// synchronize skips tokens until a statement can start. Blocks opened while skipping are skipped
+// as a whole, a '}' of the enclosing scope is only skipped at the top level where there is no scope to end
+func (p *Parser) synchronize(isTopLevel bool) {
+	depth := 0
+	for {
+		cur, err := p.reader.ReadToken()
 		if err != nil {
-			log.Fatalf("error: unexpected end of input")
+			return
 		}
-		p.reader.NextToken()
-		if next.Type == tokenizer.ASSIGN {
-			return parseVarReassignment(p, cur)
-		} else if next.Type == tokenizer.PLUS {
-			return parseVarAddToValue(p, cur)
-		} else if next.Type == tokenizer.OPEN_PAR {
-			return parseFunctionCall(p, cur)
+		switch cur.Type {
+		case tokenizer.SEMICOLON:
+			p.reader.NextToken()
+			if depth == 0 {
+				return
+			}
+			continue
+		case tokenizer.CURL_OPEN_PAR:
+			depth++
+		case tokenizer.CURL_CLOSE_PAR:
+			if depth > 0 {
+				depth--
+				p.reader.NextToken()
+				if depth == 0 {
+					return
+				}
+				continue
+			}
+			if isTopLevel {
+				p.reader.NextToken()
+			}
+			return
+		case tokenizer.FUN_DEF:
+			return
 		}
+		p.reader.NextToken()
 	}
-	return nil
 }
 
 func parseReturnStatement(p *Parser) ReturnStatement {
-	expr := p.parseExpression()
-	if expr == nil {
-		log.Fatalf("error: could not parse expression")
+	start, _ := p.reader.ReadTokenAtOffset(-1)
+	// a return without a value leaves a void function
+	var expr Expression
+	if next, err := p.reader.ReadToken(); err != nil || next.Type != tokenizer.SEMICOLON {
+		expr = p.parseExpression()
+		if expr == nil {
+			p.fail(diagnostics.EXPECTED_EXPRESSION, p.spanFrom(start.Span), "could not parse expression")
+		}
 	}
-	tok, _ := p.reader.ReadToken()
-	isSemicolon(tok)
+	tok, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	p.isSemicolon(tok)
 	p.reader.NextToken()
-	stmt := ReturnStatement{ReturnValue: expr}
+	stmt := ReturnStatement{ReturnValue: expr, Span: p.spanFrom(start.Span)}
 	return stmt
 }
 
 func parseVarDecl(p *Parser) VarDecl {
+	start, _ := p.reader.ReadTokenAtOffset(-1)
+	identSpan := p.currentSpan()
 	ident := p.parseExpression()
 	if ident == nil || ident.GetExpressionType() != IDENTIFIER_EXP {
-		log.Fatalf("error: expected identifier")
+		p.fail(diagnostics.EXPECTED_IDENTIFIER, identSpan, "expected identifier")
 	}
+	typeSpan := p.currentSpan()
 	typeOfVar := p.parseExpression()
-	if typeOfVar == nil || ident.GetExpressionType() != IDENTIFIER_EXP {
-		log.Fatalf("error: expected type")
+	if typeOfVar == nil || typeOfVar.GetExpressionType() != IDENTIFIER_EXP {
+		p.fail(diagnostics.EXPECTED_TYPE, typeSpan, "expected type")
 	}
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	if next.Type != tokenizer.ASSIGN {
-		log.Fatalf("error: expected '='")
+		p.fail(diagnostics.EXPECTED_TOKEN, next.Span, "expected '='")
 	}
 	p.reader.NextToken()
+	valueSpan := p.currentSpan()
 	varValue := p.parseExpression()
+	if varValue == nil {
+		p.fail(diagnostics.EXPECTED_EXPRESSION, valueSpan, "expected expression")
+	}
 	next, err = p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	isSemicolon(next)
+	p.isUnexpectedEndOfInput(err)
+	p.isSemicolon(next)
 	p.reader.NextToken()
-	varDecl := VarDecl{Ident: ident.(Identifier), Value: varValue, Type: typeOfVar.(Identifier)}
+	varDecl := VarDecl{Ident: ident.(Identifier), Value: varValue, Type: typeOfVar.(Identifier), Span: p.spanFrom(start.Span)}
 	return varDecl
 }
 
 func parseFunDef(p *Parser) FunctionDefinition {
+	start, _ := p.reader.ReadTokenAtOffset(-1)
+	doc := p.docComments[p.reader.GetCurrentPosition()-1]
+	identSpan := p.currentSpan()
 	ident := p.parseExpression()
-	if ident.GetExpressionType() != IDENTIFIER_EXP {
-		log.Fatalf("error: expected identifier")
+	if ident == nil || ident.GetExpressionType() != IDENTIFIER_EXP {
+		p.fail(diagnostics.EXPECTED_IDENTIFIER, identSpan, "expected identifier")
 	}
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	if next.Type != tokenizer.OPEN_PAR {
-		log.Fatalf("error: expected open parentheses")
+		p.fail(diagnostics.EXPECTED_TOKEN, next.Span, "expected open parentheses")
 	}
 	p.reader.NextToken()
 	args := make([]FunctionArgument, 0)
 	p.parseFuncArgs(&args)
 	retType := ""
 	next, err = p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	getFuncReturnType(&retType, next, p)
-	next, err = p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	p.reader.NextToken()
-	if next.Type != tokenizer.CURL_OPEN_PAR {
-		log.Fatalf("error: expected '{'")
-	}
-	stmts := make([]Statement, 0)
-	p.parseScope(&stmts)
+	scope := p.parseBlock()
 	funcDef := FunctionDefinition{Name: ident.(Identifier).Value.Value, Args: args,
-		Scope: Scope{Statements: stmts}, ReturnType: retType}
-	log.Println(funcDef.Name, funcDef.ReturnType, funcDef.Args)
-	addDiscoveredFunction(ident.(Identifier).Value.Value, retType, args)
+		Scope: scope, ReturnType: retType, Doc: doc, Span: p.spanFrom(start.Span)}
 	return funcDef
 }
 
-func parseVarReassignment(p *Parser, cur tokenizer.Token) VarReAssignment {
-	varIdent := cur
-	newValue := p.parseExpression()
-	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	isSemicolon(next)
+// parseIfStatement parses an if statement with any number of else if branches and an optional else branch
+func parseIfStatement(p *Parser) IfStatement {
+	start, _ := p.reader.ReadTokenAtOffset(-1)
+	ifStmt := IfStatement{Branches: make([]ConditionalBranch, 0)}
+	for {
+		conditionSpan := p.currentSpan()
+		condition := p.parseExpression()
+		if condition == nil {
+			p.fail(diagnostics.EXPECTED_EXPRESSION, conditionSpan, "expected condition")
+		}
+		scope := p.parseBlock()
+		ifStmt.Branches = append(ifStmt.Branches, ConditionalBranch{Condition: condition, Scope: scope})
+		next, err := p.reader.ReadToken()
+		if err != nil || next.Type != tokenizer.ELSE {
+			break
+		}
+		p.reader.NextToken()
+		next, err = p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type == tokenizer.IF {
+			p.reader.NextToken()
+			continue
+		}
+		elseScope := p.parseBlock()
+		ifStmt.Else = &elseScope
+		break
+	}
+	ifStmt.Span = p.spanFrom(start.Span)
+	return ifStmt
+}
+
+// parseLoop parses a while loop, a for loop with a header like C or a for loop over a range
+func (p *Parser) parseLoop(label Identifier) Statement {
+	keyword, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
 	p.reader.NextToken()
-	varReassign := VarReAssignment{Ident: Identifier{Value: varIdent}, Value: newValue}
-	return varReassign
+	start := keyword
+	if label.Value.Value != "" {
+		start = label.Value
+	}
+	if keyword.Type == tokenizer.WHILE {
+		conditionSpan := p.currentSpan()
+		condition := p.parseExpression()
+		if condition == nil {
+			p.fail(diagnostics.EXPECTED_EXPRESSION, conditionSpan, "expected condition")
+		}
+		scope := p.parseBlock()
+		return WhileStatement{Label: label, Condition: condition, Scope: scope, Span: p.spanFrom(start.Span)}
+	} else if keyword.Type != tokenizer.FOR {
+		p.fail(diagnostics.EXPECTED_TOKEN, keyword.Span, "expected 'while' or 'for' after label")
+	}
+	cur, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	next, err := p.reader.ReadTokenAtOffset(1)
+	p.isUnexpectedEndOfInput(err)
+	if cur.Type == tokenizer.IDENTIFIER && next.Type == tokenizer.IN {
+		return p.parseRangeFor(label, start)
+	}
+	loop := ForStatement{Label: label}
+	// every part of the header can be left out
+	if cur.Type == tokenizer.VARDECL {
+		p.reader.NextToken()
+		loop.Init = parseVarDecl(p)
+	} else if cur.Type == tokenizer.IDENTIFIER {
+		p.reader.NextToken()
+		loop.Init = p.parseSimpleStatement(cur)
+		p.expectSemicolon()
+	} else {
+		p.expectSemicolon()
+	}
+	cur, err = p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	if cur.Type != tokenizer.SEMICOLON {
+		loop.Condition = p.parseExpression()
+		if loop.Condition == nil {
+			p.fail(diagnostics.EXPECTED_EXPRESSION, cur.Span, "expected condition")
+		}
+	}
+	p.expectSemicolon()
+	cur, err = p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	if cur.Type == tokenizer.IDENTIFIER {
+		p.reader.NextToken()
+		loop.Update = p.parseSimpleStatement(cur)
+	}
+	loop.Scope = p.parseBlock()
+	loop.Span = p.spanFrom(start.Span)
+	return loop
 }
 
-func parseVarAddToValue(p *Parser, cur tokenizer.Token) VarAddToValue {
+// parseRangeFor parses for i in a..b, which counts from a up to b without b
+func (p *Parser) parseRangeFor(label Identifier, start tokenizer.Token) RangeForStatement {
+	variable, _ := p.reader.ReadToken()
+	p.reader.NextToken()
+	p.reader.NextToken()
+	fromSpan := p.currentSpan()
+	from := p.parseExpression()
+	if from == nil {
+		p.fail(diagnostics.EXPECTED_EXPRESSION, fromSpan, "expected start of range")
+	}
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	if next.Type != tokenizer.ASSIGN {
-		log.Fatalf("error: expected '='")
+	p.isUnexpectedEndOfInput(err)
+	if next.Type != tokenizer.RANGE {
+		p.fail(diagnostics.EXPECTED_TOKEN, next.Span, "expected '..'")
 	}
 	p.reader.NextToken()
+	toSpan := p.currentSpan()
+	to := p.parseExpression()
+	if to == nil {
+		p.fail(diagnostics.EXPECTED_EXPRESSION, toSpan, "expected end of range")
+	}
+	scope := p.parseBlock()
+	return RangeForStatement{Label: label, Variable: Identifier{Value: variable, Span: variable.Span},
+		From: from, To: to, Scope: scope, Span: p.spanFrom(start.Span)}
+}
+
+// parseLoopControl parses break and continue, which can name the loop they belong to
+func parseLoopControl(p *Parser, keyword tokenizer.Token) Statement {
+	label := Identifier{}
+	next, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	if next.Type == tokenizer.IDENTIFIER {
+		p.reader.NextToken()
+		label = Identifier{Value: next, Span: next.Span}
+	}
+	p.expectSemicolon()
+	return LoopControl{IsBreak: keyword.Type == tokenizer.BREAK, Label: label, Span: p.spanFrom(keyword.Span)}
+}
+
+func parseVarReassignment(p *Parser, cur tokenizer.Token) VarReAssignment {
 	varIdent := cur
+	valueSpan := p.currentSpan()
 	newValue := p.parseExpression()
-	next, err = p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	isSemicolon(next)
-	p.reader.NextToken()
-	return VarAddToValue{Ident: Identifier{Value: varIdent}, ValueToAdd: newValue}
+	if newValue == nil {
+		p.fail(diagnostics.EXPECTED_EXPRESSION, valueSpan, "expected expression")
+	}
+	varReassign := VarReAssignment{Ident: Identifier{Value: varIdent, Span: varIdent.Span}, Value: newValue, Span: p.spanFrom(varIdent.Span)}
+	return varReassign
+}
+
+type compoundOperator struct {
+	symbol string
+	kind   ExpNodeType
+}
+
+// compoundOperators maps the tokens of compound assignments to the operation they apply
+var compoundOperators map[tokenizer.TokenType]compoundOperator = map[tokenizer.TokenType]compoundOperator{
+	tokenizer.PLUS_ASSIGN:  {"+=", ADD},
+	tokenizer.MINUS_ASSIGN: {"-=", SUB},
+	tokenizer.MUL_ASSIGN:   {"*=", MUL},
+	tokenizer.DIV_ASSIGN:   {"/=", DIV},
+	tokenizer.MOD_ASSIGN:   {"%=", MOD},
+	tokenizer.POW_ASSIGN:   {"**=", POW},
+	tokenizer.AND_ASSIGN:   {"&=", BIT_AND},
+	tokenizer.OR_ASSIGN:    {"|=", BIT_OR},
+	tokenizer.XOR_ASSIGN:   {"^=", BIT_XOR},
+	tokenizer.SHL_ASSIGN:   {"<<=", SHL},
+	tokenizer.SHR_ASSIGN:   {">>=", SHR},
+	tokenizer.USHR_ASSIGN:  {">>>=", USHR},
+	tokenizer.INCREMENT:    {"++", ADD},
+	tokenizer.DECREMENT:    {"--", SUB},
+}
+
+// parseCompoundAssignment parses the value after the operator of a compound assignment, x++ and x-- have no value
+func parseCompoundAssignment(p *Parser, cur, op tokenizer.Token) CompoundAssignment {
+	operator := compoundOperators[op.Type]
+	stmt := CompoundAssignment{Ident: Identifier{Value: cur, Span: cur.Span}, Operator: operator.symbol, Kind: operator.kind}
+	if op.Type == tokenizer.INCREMENT || op.Type == tokenizer.DECREMENT {
+		stmt.Value = MathExpNode{Kind: NUMBER, Number: tokenizer.Token{Type: tokenizer.NUMBER, Value: "1", Span: op.Span}, Span: op.Span}
+		stmt.Span = p.spanFrom(cur.Span)
+		return stmt
+	}
+	valueSpan := p.currentSpan()
+	stmt.Value = p.parseExpression()
+	if stmt.Value == nil {
+		p.fail(diagnostics.EXPECTED_EXPRESSION, valueSpan, "expected expression")
+	}
+	stmt.Span = p.spanFrom(cur.Span)
+	return stmt
 }
 
 func parseFunctionCall(p *Parser, cur tokenizer.Token) FunctionCall {
 	args := make([]Expression, 0)
 	next, err := p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
+	p.isUnexpectedEndOfInput(err)
 	if next.Type != tokenizer.CLOSE_PAR {
 		parseFuncCallArgs(p, &args)
 	}
 	p.reader.NextToken()
-	next, err = p.reader.ReadToken()
-	isUnexpectedEndOfInput(err)
-	isSemicolon(next)
-	p.reader.NextToken()
-	funcCall := FunctionCall{CalledFunctionName: cur.Value, Arguments: args}
+	funcCall := FunctionCall{CalledFunctionName: cur.Value, Arguments: args, IsStatement: true, Span: p.spanFrom(cur.Span)}
 	return funcCall
 }
 
 func parseFuncCallArgs(p *Parser, args *[]Expression) {
 	for {
+		expSpan := p.currentSpan()
 		exp := p.parseExpression()
+		if exp == nil {
+			p.fail(diagnostics.EXPECTED_EXPRESSION, expSpan, "expected expression")
+		}
 		*args = append(*args, exp)
 		cur, err := p.reader.ReadToken()
-		isUnexpectedEndOfInput(err)
+		p.isUnexpectedEndOfInput(err)
 		if cur.Type == tokenizer.CLOSE_PAR {
 			break
 		} else if cur.Type == tokenizer.COLON {
 			p.reader.NextToken()
 			continue
 		}
+		p.fail(diagnostics.EXPECTED_TOKEN, cur.Span, "expected ',' or ')'")
 	}
 }
 
-func isUnexpectedEndOfInput(err error) {
+// spanFrom returns the span from start to the end of the last consumed token
+func (p *Parser) spanFrom(start source.Span) source.Span {
+	last, err := p.reader.ReadTokenAtOffset(-1)
 	if err != nil {
-		log.Fatalf("error: unexpected end of input")
+		return start
 	}
+	return start.To(last.Span)
 }
 
-func addDiscoveredFunction(name, retType string, args []FunctionArgument) {
-	if _, ok := discoveredFunctions[name]; ok {
-		log.Fatalf("error: cannot define a function with the name %v (function with that name already exists)", name)
+func (p *Parser) isUnexpectedEndOfInput(err error) {
+	if err == nil {
+		return
 	}
-	discoveredFunctions[name] = Function{ReturnType: retType, Args: args}
+	// every statement that is still open when the input ends would report it again
+	if !p.reachedEndOfInput {
+		p.diagnostics.Error(diagnostics.UNEXPECTED_END_OF_INPUT, p.endOfInput(), "unexpected end of input")
+		p.reachedEndOfInput = true
+	}
+	panic(parseError{})
+}
+
+func (p *Parser) addDiscoveredFunction(fd FunctionDefinition) {
+	if _, ok := p.functions[fd.Name]; ok {
+		p.diagnostics.Error(diagnostics.DUPLICATE_FUNCTION, fd.Span, "cannot define a function with the name %v (function with that name already exists)", fd.Name)
+		return
+	}
+	p.functions[fd.Name] = Function{ReturnType: fd.ReturnType, Args: fd.Args}
 }
 
 func getFuncReturnType(retType *string, t tokenizer.Token, p *Parser) {
-	if t.Type == tokenizer.IDENTIFIER {
-		*retType = t.Value
-		p.reader.NextToken()
+	if t.Type == tokenizer.IDENTIFIER || t.Type == tokenizer.OPEN_BRACKET {
+		*retType = p.parseType()
 	} else if t.Type == tokenizer.CURL_OPEN_PAR {
 		*retType = "void"
 	} else {
-		log.Fatalf("error: expected return type")
+		p.fail(diagnostics.EXPECTED_TYPE, t.Span, "expected return type")
 	}
 }
 
 func (p *Parser) parseFuncArgs(args *[]FunctionArgument) {
 	for {
 		next, err := p.reader.ReadToken()
-		isUnexpectedEndOfInput(err)
+		p.isUnexpectedEndOfInput(err)
+		if next.Type != tokenizer.CLOSE_PAR && next.Type != tokenizer.IDENTIFIER && next.Type != tokenizer.COLON {
+			p.diagnostics.Error(diagnostics.EXPECTED_TOKEN, next.Span, "expected parameter, ',' or ')'")
+			p.synchronizeParameters()
+			return
+		}
 		p.reader.NextToken()
 		if next.Type == tokenizer.CLOSE_PAR {
 			break
 		} else if next.Type == tokenizer.IDENTIFIER {
-			temp, err := p.reader.ReadToken()
-			isUnexpectedEndOfInput(err)
+			typ := p.parseType()
+			*args = append(*args, FunctionArgument{Name: next.Value, Type: typ, Span: p.spanFrom(next.Span)})
+		}
+	}
+}
+
+// synchronizeParameters skips the rest of a parameter list after an error. It stops before a '{', '}'
+// or 'fun' if the ')' is missing, so the body of the function can still be parsed
+func (p *Parser) synchronizeParameters() {
+	for {
+		next, err := p.reader.ReadToken()
+		if err != nil {
+			return
+		}
+		switch next.Type {
+		case tokenizer.CLOSE_PAR:
 			p.reader.NextToken()
-			if temp.Type != tokenizer.IDENTIFIER {
-				log.Fatalf("error: expected identifier")
-			}
-			*args = append(*args, FunctionArgument{Name: next.Value, Type: temp.Value})
-			continue
-		} else if next.Type == tokenizer.COLON {
-			continue
+			return
+		case tokenizer.CURL_OPEN_PAR, tokenizer.CURL_CLOSE_PAR, tokenizer.FUN_DEF:
+			return
 		}
+		p.reader.NextToken()
 	}
 }
 
+// parseType reads a type like int, []string or java.util.List
+func (p *Parser) parseType() string {
+	typ := ""
+	for {
+		next, err := p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type != tokenizer.OPEN_BRACKET {
+			break
+		}
+		p.reader.NextToken()
+		next, err = p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type != tokenizer.CLOSE_BRACKET {
+			p.fail(diagnostics.EXPECTED_TOKEN, next.Span, "expected ']'")
+		}
+		p.reader.NextToken()
+		typ += "[]"
+	}
+	next, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	if next.Type != tokenizer.IDENTIFIER {
+		p.fail(diagnostics.EXPECTED_TYPE, next.Span, "expected type")
+	}
+	p.reader.NextToken()
+	typ += next.Value
+	for {
+		next, err := p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type != tokenizer.DOT {
+			break
+		}
+		p.reader.NextToken()
+		next, err = p.reader.ReadToken()
+		p.isUnexpectedEndOfInput(err)
+		if next.Type != tokenizer.IDENTIFIER {
+			p.fail(diagnostics.EXPECTED_IDENTIFIER, next.Span, "expected identifier after '.'")
+		}
+		p.reader.NextToken()
+		typ += "." + next.Value
+	}
+	return typ
+}
+
+// parseBlock parses statements surrounded by curly braces
+func (p *Parser) parseBlock() Scope {
+	next, err := p.reader.ReadToken()
+	p.isUnexpectedEndOfInput(err)
+	if next.Type != tokenizer.CURL_OPEN_PAR {
+		p.fail(diagnostics.EXPECTED_TOKEN, next.Span, "expected '{'")
+	}
+	p.reader.NextToken()
+	stmts := make([]Statement, 0)
+	p.parseScope(&stmts)
+	return Scope{Statements: stmts, Span: p.spanFrom(next.Span)}
+}
+
 func (p *Parser) parseScope(stmts *[]Statement) {
 	for {
 		next, err := p.reader.ReadToken()
-		isUnexpectedEndOfInput(err)
+		p.isUnexpectedEndOfInput(err)
 		if next.Type == tokenizer.CURL_CLOSE_PAR {
 			p.reader.NextToken()
 			break
 		}
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery(false)
 		if stmt.GetStatementType() == FUNCDEF {
-			log.Fatalf("error: cannot define function inside another function")
+			p.diagnostics.Error(diagnostics.NESTED_FUNCTION, stmt.GetSpan(), "cannot define function inside another function")
+			continue
 		}
 		*stmts = append(*stmts, stmt)
 	}
 }
 
+// ParseProgram parses all statements. Syntax errors are reported to the diagnostics and
+// the statements that contain them are replaced with an ErrorStatement
 func (p Parser) ParseProgram() Program {
-	program := Program{Statements: make([]Statement, 0)}
+	program := Program{Statements: make([]Statement, 0), Functions: p.functions}
 	for {
 		_, err := p.reader.ReadToken()
 		if err != nil {
 			break
 		}
-		stmt := p.parseStatement()
-		if stmt == nil {
-			log.Fatalf("error: could not identify statement")
+		stmt := p.parseStatementWithRecovery(true)
+		// only functions at the top level are defined, nested ones are reported by parseScope
+		if fd, ok := stmt.(FunctionDefinition); ok {
+			p.addDiscoveredFunction(fd)
 		}
 		program.Statements = append(program.Statements, stmt)
 	}
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/source"
+	"compiler/tokenizer"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func parse(src string) (Program, []diagnostics.Diagnostic) {
+	diags := diagnostics.NewDiagnostics()
+	tokens := tokenizer.NewTokenizer("test.e", src, diags).GetTokens()
+	class := classfile.NewClass("Test", "java/lang/Object", classfile.NewTarget(classfile.MIN_RELEASE))
+	program := NewParser(tokens, class, diags).ParseProgram()
+	return program, diags.List()
+}
+
+// describe lists the code and start position of every diagnostic, like "E0104 2:14"
+func describe(list []diagnostics.Diagnostic) string {
+	described := make([]string, 0, len(list))
+	for _, diagnostic := range list {
+		described = append(described, fmt.Sprintf("%v %v:%v", diagnostic.Code, diagnostic.Span.Start.Line, diagnostic.Span.Start.Column))
+	}
+	return strings.Join(described, ", ")
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// code and position of every expected diagnostic
+		diagnostics string
+	}{
+		{"missing value before ';'", "fun main() {\n let y int = ;\n let q int = 1\n println(2);\n}",
+			"E0104 2:14, E0101 4:2"},
+		{"missing value of an assignment", "fun main() {\n let x int = 1;\n x = ;\n x += ;\n println(x)\n}",
+			"E0104 3:6, E0104 4:7, E0101 6:1"},
+		{"error before '}'", "fun main() {\n if true { let a int = }\n let b = 2;\n}",
+			"E0104 2:24, E0103 3:8"},
+		{"error before 'fun'", "fun f() {\n println(\n}\nfun main() {\n let = 1;\n}",
+			"E0104 3:1, E0102 5:6"},
+		{"statements after a skipped block", "fun main() {\n if { println(1); }\n let a int = 1\n}",
+			"E0104 2:5, E0101 4:1"},
+		{"nested function with the name of a later one", "fun main() {\n fun g() {}\n}\nfun g() {}", "E0106 2:2"},
+		{"duplicate function", "fun g() {}\nfun g(a int) {}", "E0107 2:1"},
+		{"unexpected token in the parameters", "fun f(a int ; b int) {\n}\nfun main() {\n let a = 1;\n}",
+			"E0101 1:13, E0103 4:8"},
+		{"unclosed parameters", "fun g( {\n println(1);\n}\nfun main() {\n let a = 1;\n}",
+			"E0101 1:8, E0103 5:8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, list := parse(test.src)
+			if described := describe(list); described != test.diagnostics {
+				t.Errorf("expected the diagnostics %v but got %v", test.diagnostics, described)
+			}
+		})
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// doc comments of the function definitions in the order they are defined
+		docs        []string
+		diagnostics string
+	}{
+		{"documented functions", "/// adds one\n/// to a\nfun f(a int) int { return a + 1; }\n// not a doc comment\nfun main() {}",
+			[]string{"adds one\nto a", ""}, ""},
+		{"comments between doc comment and function", "/// main /* block */\n// line\nfun main() {}",
+			[]string{"main /* block */"}, ""},
+		{"doc comment of a statement", "fun main() {\n /// x\n let x int = 1;\n}",
+			[]string{""}, "W0001 2:2"},
+		{"doc comment at the end", "fun main() {}\n/// end\n///", []string{""}, "W0001 2:1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program, list := parse(test.src)
+			docs := make([]string, 0)
+			for _, stmt := range program.Statements {
+				if fd, ok := stmt.(FunctionDefinition); ok {
+					docs = append(docs, fd.Doc)
+				}
+			}
+			if !slices.Equal(docs, test.docs) {
+				t.Errorf("expected the doc comments %q but got %q", test.docs, docs)
+			}
+			if described := describe(list); described != test.diagnostics {
+				t.Errorf("expected the diagnostics %v but got %v", test.diagnostics, described)
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	src := "fun main() {\r\n  let x int = (1 + 2) * -f(3, \"ä\");\r\n  x += 1;\r\n  if x > 2 { println(x); } else { println(0); }\r\n}"
+	program, list := parse(src)
+	if len(list) > 0 || len(program.Statements) != 1 {
+		t.Fatalf("unexpected diagnostics %v", describe(list))
+	}
+	main := program.Statements[0].(FunctionDefinition)
+	decl := main.Scope.Statements[0].(VarDecl)
+	value := decl.Value.(MathExpNode)
+	call := value.Binary.Right.Unary.Operand.FuncCall
+	tests := []struct {
+		node string
+		span source.Span
+		// the source code the span covers
+		text string
+	}{
+		{"function", main.Span, src},
+		{"declaration", decl.Span, "let x int = (1 + 2) * -f(3, \"ä\");"},
+		{"value", value.Span, "(1 + 2) * -f(3, \"ä\")"},
+		{"parenthesized operand", value.Binary.Left.Span, "(1 + 2)"},
+		{"unary operand", value.Binary.Right.Span, "-f(3, \"ä\")"},
+		{"call", call.Span, "f(3, \"ä\")"},
+		{"argument", call.Arguments[1].GetSpan(), "\"ä\""},
+		// simple statements end before the ";" as they are also used in the header of for loops
+		{"compound assignment", main.Scope.Statements[1].GetSpan(), "x += 1"},
+		{"if statement", main.Scope.Statements[2].GetSpan(), "if x > 2 { println(x); } else { println(0); }"},
+	}
+	for _, test := range tests {
+		if text := src[test.span.Start.Offset:test.span.End.Offset]; text != test.text {
+			t.Errorf("expected the span of the %v to cover %q but it covers %q", test.node, test.text, text)
+		}
+	}
+	if start := value.Binary.Right.Span.Start; start.Line != 2 || start.Column != 25 {
+		t.Errorf("expected the unary operand to start at 2:25 but it starts at %v:%v", start.Line, start.Column)
+	}
+}
+
+func TestRedeclaration(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		note string
+	}{
+		{"parameter", "fun f(a int) {\n let a int = 1;\n}", "'a' is a parameter of the function declared at test.e:1:7"},
+		{"two parameters", "fun f(a int, a long) {\n}", "'a' is a parameter of the function declared at test.e:1:7"},
+		{"same scope", "fun f() {\n let a int = 1;\n let a int = 2;\n}", "'a' is already declared in line 2"},
+		{"enclosing scope", "fun f() {\n let a int = 1;\n if true { let a int = 2; }\n}", "'a' is declared in an enclosing scope in line 2 and cannot be shadowed"},
+		{"parameter in a nested scope", "fun f(a int) {\n while true { let a int = 2; }\n}", "'a' is a parameter of the function declared at test.e:1:7"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program, list := parse(test.src)
+			if len(list) > 0 {
+				t.Fatalf("unexpected diagnostics %v", describe(list))
+			}
+			diags := diagnostics.NewDiagnostics()
+			NewChecker(diags).Check(program)
+			list = diags.List()
+			if len(list) != 1 || list[0].Code != diagnostics.REDECLARED_VARIABLE || !slices.Equal(list[0].Notes, []string{test.note}) {
+				t.Errorf("expected a redeclaration with the note %q but got %v", test.note, list)
+			}
+		})
+	}
+}
+
+func TestNestedFunctionIsNotDefined(t *testing.T) {
+	program, _ := parse("fun main() {\n fun nested() {}\n}")
+	if _, ok := program.Functions["nested"]; ok {
+		t.Errorf("a rejected nested function must not be defined")
+	}
+	if _, ok := program.Functions["main"]; !ok {
+		t.Errorf("main must be defined")
+	}
+}
+
+func TestUnaryPrecedence(t *testing.T) {
+	tests := []struct {
+		src string
+		// the expression with parentheses around every operation
+		tree string
+	}{
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"(-2) ** 2", "((-2) ** 2)"},
+		{"2 ** -2", "(2 ** (-2))"},
+		{"-2 ** 3 ** 2", "(-(2 ** (3 ** 2)))"},
+		{"-2 * 3", "((-2) * 3)"},
+		{"-x as long", "((-x) as long)"},
+		{"!a && b", "((!a) && b)"},
+	}
+	for _, test := range tests {
+		program, list := parse("fun main() { let v int = " + test.src + "; }")
+		if len(list) > 0 {
+			t.Fatalf("%v: unexpected diagnostics %v", test.src, describe(list))
+		}
+		value := program.Statements[0].(FunctionDefinition).Scope.Statements[0].(VarDecl).Value.(MathExpNode)
+		if tree := parenthesize(&value); tree != test.tree {
+			t.Errorf("expected %v to be parsed as %v but got %v", test.src, test.tree, tree)
+		}
+	}
+}
+
+func parenthesize(mxp *MathExpNode) string {
+	symbols := map[ExpNodeType]string{POW: "**", MUL: "*", AND: "&&", NEGATIVE: "-", NOT: "!"}
+	switch {
+	case mxp.Kind == CAST:
+		return "(" + parenthesize(mxp.Unary.Operand) + " as " + mxp.Number.Value + ")"
+	case mxp.Unary.Operand != nil:
+		return "(" + symbols[mxp.Kind] + parenthesize(mxp.Unary.Operand) + ")"
+	case mxp.Binary.Left != nil:
+		return "(" + parenthesize(mxp.Binary.Left) + " " + symbols[mxp.Kind] + " " + parenthesize(mxp.Binary.Right) + ")"
+	}
+	return mxp.Number.Value
+}
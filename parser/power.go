@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"compiler/classfile"
+	"compiler/diagnostics"
+	"compiler/instructions"
+	"compiler/source"
+	"math"
+	"strings"
+)
+
+const (
+	// name of the generated methods that compute integer powers, $ cannot be part of a function name
+	POW_HELPER = "$pow"
+)
+
+// generatePower generates a ** b. Powers of constants are computed at compile time, powers of
+// floats and doubles call Math.pow and integer powers call a generated fast power method
+func (mxp MathExpNode) generatePower(context *GeneratorContext) {
+	if mxp.isConstant() {
+		if value, ok := mxp.constantValue(context.Diagnostics); ok {
+			loadConstant(value, context)
+		}
+		return
+	}
+	typ := mxp.Type()
+	operandType := typ
+	if typ == "float" {
+		operandType = "double"
+	}
+	mxp.generateOperands(operandType, context)
+	switch typ {
+	case "float", "double":
+		context.Code.Invoke(instructions.INVOKESTATIC, context.Class.AddMethodRef("pow", "(DD)D", "java/lang/Math"), "(DD)D")
+		generateConversion("double", typ, context)
+	default:
+		descriptor, _ := functionDescriptor([]FunctionArgument{{Type: typ}, {Type: typ}}, typ)
+		if _, ok := context.Class.FindMethod(POW_HELPER, descriptor); !ok {
+			generatePowerHelper(typ, descriptor, mxp.Span, context)
+		}
+		context.Code.Invoke(instructions.INVOKESTATIC, context.Class.AddMethodRef(POW_HELPER, descriptor, context.Class.Name()), descriptor)
+	}
+}
+
+// generatePowerHelper adds the method that computes powers of ints or longs by squaring. A negative
+// exponent gives 1 / base ** -exponent, so 0 ** -1 divides by zero
+func generatePowerHelper(typ, descriptor string, span source.Span, context *GeneratorContext) {
+	code := context.Code
+	defer func() { context.Code = code }()
+	context.Code = instructions.NewAssembler()
+	offset, size := typeOffset(typ), slotSize(typ)
+	base := Variable{VariableIndex: 0, Type: typ}
+	exponent := Variable{VariableIndex: size, Type: typ}
+	result := Variable{VariableIndex: 2 * size, Type: typ}
+	negative := Variable{VariableIndex: 3 * size, Type: "int"}
+	// compareWithZero leaves a value that the if<cond> instructions can compare with 0
+	compareWithZero := func(variable Variable) {
+		loadVariable(variable, context)
+		if typ == "long" {
+			context.Code.Emit(instructions.LCONST_0)
+			context.Code.Emit(instructions.LCMP)
+		}
+	}
+	one := func() { loadConstant(convertConstant(int32(1), typ), context) }
+	positive, loop, skip, end, isPositive := context.Code.NewLabel(), context.Code.NewLabel(), context.Code.NewLabel(), context.Code.NewLabel(), context.Code.NewLabel()
+
+	context.Code.Emit(instructions.ICONST_0)
+	storeVariable(negative, context)
+	compareWithZero(exponent)
+	context.Code.Jump(instructions.IFGE, positive)
+	context.Code.Emit(instructions.ICONST_1)
+	storeVariable(negative, context)
+	loadVariable(exponent, context)
+	context.Code.Emit(instructions.INEG + offset)
+	storeVariable(exponent, context)
+	context.Code.Mark(positive)
+	one()
+	storeVariable(result, context)
+	// the exponent is shifted as unsigned number, so the negated minimum value works as well
+	context.Code.Mark(loop)
+	compareWithZero(exponent)
+	context.Code.Jump(instructions.IFEQ, end)
+	loadVariable(exponent, context)
+	one()
+	context.Code.Emit(instructions.IAND + offset)
+	if typ == "long" {
+		context.Code.Emit(instructions.L2I)
+	}
+	context.Code.Jump(instructions.IFEQ, skip)
+	loadVariable(result, context)
+	loadVariable(base, context)
+	context.Code.Emit(instructions.IMUL + offset)
+	storeVariable(result, context)
+	context.Code.Mark(skip)
+	loadVariable(base, context)
+	loadVariable(base, context)
+	context.Code.Emit(instructions.IMUL + offset)
+	storeVariable(base, context)
+	loadVariable(exponent, context)
+	context.Code.Emit(instructions.ICONST_1)
+	context.Code.Emit(instructions.IUSHR + offset)
+	storeVariable(exponent, context)
+	context.Code.Jump(instructions.GOTO, loop)
+	context.Code.Mark(end)
+	loadVariable(negative, context)
+	context.Code.Jump(instructions.IFEQ, isPositive)
+	one()
+	loadVariable(result, context)
+	context.Code.Emit(instructions.IDIV + offset)
+	context.Code.Emit(instructions.IRETURN + offset)
+	context.Code.Mark(isPositive)
+	loadVariable(result, context)
+	context.Code.Emit(instructions.IRETURN + offset)
+
+	byteCode, err := context.Code.Assemble()
+	if err == nil {
+		err = context.Class.AddMethod(classfile.ACC_PRIVATE|classfile.ACC_STATIC|classfile.ACC_SYNTHETIC, POW_HELPER, descriptor, byteCode, uint16(3*size+1))
+	}
+	if err != nil {
+		context.Diagnostics.Error(diagnostics.INTERNAL_ERROR, span, "%v", strings.TrimPrefix(err.Error(), "error: "))
+	}
+}
+
+// isConstant reports whether the value of an expression is known at compile time, which is the
+// case for number literals and powers of them
+func (mxp MathExpNode) isConstant() bool {
+	switch mxp.Kind {
+	case NUMBER:
+		return true
+	case NEGATIVE:
+		return mxp.Unary.Operand.Kind == NUMBER
+	case POW:
+		return mxp.Binary.Left.isConstant() && mxp.Binary.Right.isConstant()
+	}
+	return false
+}
+
+// constantValue computes the value of a constant expression, see literalValue for the types of the value
+func (mxp MathExpNode) constantValue(diags *diagnostics.Diagnostics) (any, bool) {
+	switch mxp.Kind {
+	case NUMBER:
+		return mxp.literalValue(false, diags)
+	case NEGATIVE:
+		return mxp.Unary.Operand.literalValue(true, diags)
+	}
+	left, leftOk := mxp.Binary.Left.constantValue(diags)
+	right, rightOk := mxp.Binary.Right.constantValue(diags)
+	if !leftOk || !rightOk {
+		return nil, false
+	}
+	typ := mxp.Type()
+	left, right = convertConstant(left, typ), convertConstant(right, typ)
+	switch typ {
+	case "float":
+		return float32(math.Pow(float64(left.(float32)), float64(right.(float32)))), true
+	case "double":
+		return math.Pow(left.(float64), right.(float64)), true
+	}
+	var value any
+	var ok bool
+	if typ == "long" {
+		value, ok = integerPower[int64, uint64](left.(int64), right.(int64))
+	} else {
+		value, ok = integerPower[int32, uint32](left.(int32), right.(int32))
+	}
+	if !ok {
+		diags.Error(diagnostics.DIVISION_BY_ZERO, mxp.Span, "division by zero").
+			Note("0 to the power of a negative number is 1 divided by 0")
+	}
+	return value, ok
+}
+
+// integerPower computes a power the same way the generated helper method does, it reports false for 0 ** -n
+// U is the unsigned type of the same size as T, the exponent is shifted as unsigned number like in the helper
+func integerPower[T int32 | int64, U uint32 | uint64](base, exponent T) (T, bool) {
+	negative := exponent < 0
+	if negative {
+		exponent = -exponent
+	}
+	result := T(1)
+	for bits := U(exponent); bits != 0; bits >>= 1 {
+		if bits&1 != 0 {
+			result *= base
+		}
+		base *= base
+	}
+	if !negative {
+		return result, true
+	}
+	if result == 0 {
+		return 0, false
+	}
+	return 1 / result, true
+}
+
+// convertConstant widens a constant value to a numeric type
+func convertConstant(value any, typ string) any {
+	var integer int64
+	var float float64
+	isInteger := true
+	switch v := value.(type) {
+	case int32:
+		integer = int64(v)
+	case int64:
+		integer = v
+	case float32:
+		float, isInteger = float64(v), false
+	case float64:
+		float, isInteger = v, false
+	}
+	switch {
+	case typ == "int":
+		return int32(integer)
+	case typ == "long":
+		return integer
+	case typ == "float" && isInteger:
+		return float32(integer)
+	case typ == "float":
+		return float32(float)
+	case isInteger:
+		return float64(integer)
+	}
+	return float
+}
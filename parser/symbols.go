@@ -0,0 +1,51 @@
+package parser
+
+import "compiler/source"
+
+// Variable is a local variable of a function, the checker assigns every variable its own slot
+type Variable struct {
+	Name          string
+	VariableIndex int
+	Type          string
+	Span          source.Span
+}
+
+// SymbolTable contains every local variable of a function including its arguments, variables
+// of different scopes never share a slot
+type SymbolTable struct {
+	Variables []*Variable
+	MaxLocals int
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{Variables: make([]*Variable, 0)}
+}
+
+// declare adds a variable in the next free slot, long and double take two slots
+func (st *SymbolTable) declare(name, typ string, span source.Span) *Variable {
+	variable := &Variable{Name: name, VariableIndex: st.MaxLocals, Type: typ, Span: span}
+	st.Variables = append(st.Variables, variable)
+	st.MaxLocals += slotSize(typ)
+	return variable
+}
+
+// lexicalScope contains the variables declared in a block, the variables of the blocks around
+// it can be used as well. The scope of a function has no parent
+type lexicalScope struct {
+	variables map[string]*Variable
+	parent    *lexicalScope
+}
+
+func newLexicalScope(parent *lexicalScope) *lexicalScope {
+	return &lexicalScope{variables: make(map[string]*Variable), parent: parent}
+}
+
+// lookup finds a variable in the scope or the scopes around it
+func (s *lexicalScope) lookup(name string) (*Variable, bool) {
+	for scope := s; scope != nil; scope = scope.parent {
+		if variable, ok := scope.variables[name]; ok {
+			return variable, true
+		}
+	}
+	return nil, false
+}
@@ -2,42 +2,69 @@ package parser
 
 import (
 	"compiler/classfile"
+	"compiler/diagnostics"
 	"compiler/instructions"
+	"compiler/source"
 	"compiler/tokenizer"
-	"encoding/binary"
-	"fmt"
-	"log"
+	"math"
+	"strings"
 )
 
 const (
-	IDENTIFIER_EXP   = "identifier"
-	MATH_EXP         = "mathExp"
-	RETURN           = "return"
-	VARDECL          = "varDecl"
-	VARREASSIGNMENT  = "varReAssignment"
-	VARADDTOVARIABLE = "varAddToVariable"
-	FUNCTIONARG      = "functionArg"
-	FUNCDEF          = "funcDef"
-	FUNCTIONCALL     = "functionCall"
+	IDENTIFIER_EXP  = "identifier"
+	MATH_EXP        = "mathExp"
+	RETURN          = "return"
+	VARDECL         = "varDecl"
+	VARREASSIGNMENT = "varReAssignment"
+	COMPOUND_ASSIGN = "compoundAssignment"
+	FUNCTIONARG     = "functionArg"
+	FUNCDEF         = "funcDef"
+	FUNCTIONCALL    = "functionCall"
+	IF_STMT         = "if"
+	WHILE_STMT      = "while"
+	FOR_STMT        = "for"
+	RANGE_FOR_STMT  = "rangeFor"
+	LOOP_CONTROL    = "loopControl"
+	ERROR_STMT      = "error"
+
+	MAIN_FUNCTION    = "main"
+	MAIN_DESCRIPTOR  = "([Ljava/lang/String;)V"
+	PRINTLN_FUNCTION = "println"
 )
 
+// GeneratorContext is the state of the code generation. The variables and types it needs are
+// annotated by the checker, so only problems of the compiler itself are reported
 type GeneratorContext struct {
-	Class     *classfile.Class
-	MaxLocals *int
-	Variables map[string]Variable
+	Class       *classfile.Class
+	Diagnostics *diagnostics.Diagnostics
+	// code of the function that is generated
+	Code *instructions.Assembler
+	// return type of the function that is generated
+	ReturnType string
+	// loops around the generated statement, the innermost loop is last
+	Loops []Loop
 }
 
 type Expression interface {
 	GetExpressionType() string
+	GetSpan() source.Span
 }
 
 type Statement interface {
 	GetStatementType() string
-	GenerateByteCode(context *GeneratorContext) []byte
+	GetSpan() source.Span
+	GenerateByteCode(context *GeneratorContext)
 }
 
 type Identifier struct {
 	Value tokenizer.Token
+	// the variable an identifier expression refers to, set by the checker
+	Variable *Variable
+	Span     source.Span
+}
+
+func (i Identifier) GetSpan() source.Span {
+	return i.Span
 }
 
 func (i Identifier) GetExpressionType() string {
@@ -46,166 +73,141 @@ func (i Identifier) GetExpressionType() string {
 
 type ReturnStatement struct {
 	ReturnValue Expression
+	Span        source.Span
+}
+
+func (r ReturnStatement) GetSpan() source.Span {
+	return r.Span
 }
 
 func (r ReturnStatement) GetStatementType() string {
 	return RETURN
 }
 
-func (r ReturnStatement) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	switch r.ReturnValue.GetExpressionType() {
-	case MATH_EXP:
-		byteCode = append(byteCode, r.ReturnValue.(MathExpNode).GenerateByteCode(context)...)
-		byteCode = append(byteCode, instructions.IRETURN)
-	case IDENTIFIER_EXP:
-		variable, ok := context.Variables[r.ReturnValue.(Identifier).Value.Value]
-		if !ok {
-			log.Fatalf("error: cannot return undefined variable '%v'", r.ReturnValue.(Identifier).Value.Value)
-		}
-		byteCode = append(byteCode, loadVariable(variable)...)
-		byteCode = append(byteCode, instructions.IRETURN)
-	default:
-		log.Fatalf("error: unsupported expression type (%v)", r.ReturnValue.GetExpressionType())
+func (r ReturnStatement) GenerateByteCode(context *GeneratorContext) {
+	if r.ReturnValue == nil {
+		context.Code.Emit(instructions.RETURN)
+		return
 	}
-	return byteCode
+	generateTypedExpression(r.ReturnValue, context.ReturnType, context)
+	context.Code.Emit(instructions.IRETURN + typeOffset(context.ReturnType))
 }
 
 type VarDecl struct {
-	Type  Identifier
-	Value Expression
-	Ident Identifier
+	Type     Identifier
+	Value    Expression
+	Ident    Identifier
+	Variable *Variable
+	Span     source.Span
+}
+
+func (id VarDecl) GetSpan() source.Span {
+	return id.Span
 }
 
 func (id VarDecl) GetStatementType() string {
 	return VARDECL
 }
 
-func (id VarDecl) GenerateByteCode(context *GeneratorContext) []byte {
-	if id.Type.Value.Value == "int" {
-		_, ok := context.Variables[id.Ident.Value.Value]
-		if ok {
-			log.Fatalf("error: cannot redeclare variable '%v'", id.Ident.Value.Value)
-		}
-		byteCode := make([]byte, 0)
-		if id.Value.GetExpressionType() == IDENTIFIER_EXP {
-			variable, ok := context.Variables[id.Value.(Identifier).Value.Value]
-			if !ok {
-				log.Fatalf("error: cannot use undeclared variable '%v'", id.Value.(Identifier).Value.Value)
-			}
-			byteCode = append(byteCode, loadVariable(variable)...)
-		} else if id.Value.GetExpressionType() == MATH_EXP {
-			byteCode = append(byteCode, id.Value.(MathExpNode).GenerateByteCode(context)...)
-		}
-		byteCode = append(byteCode, declareVariable(id.Ident.Value.Value, "int", context)...)
-		return byteCode
-	}
-	log.Fatalf("error: unknown type")
-	return nil
+func (id VarDecl) GenerateByteCode(context *GeneratorContext) {
+	generateTypedExpression(id.Value, id.Variable.Type, context)
+	storeVariable(*id.Variable, context)
 }
 
 type VarReAssignment struct {
-	Ident Identifier
-	Value Expression
+	Ident    Identifier
+	Value    Expression
+	Variable *Variable
+	Span     source.Span
+}
+
+func (vra VarReAssignment) GetSpan() source.Span {
+	return vra.Span
 }
 
 func (vra VarReAssignment) GetStatementType() string {
 	return VARREASSIGNMENT
 }
 
-func (vra VarReAssignment) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	variable, ok := context.Variables[vra.Ident.Value.Value]
-	if !ok {
-		log.Fatalf("error: cannot reassign undeclared variable '%v'", vra.Ident.Value.Value)
-	}
-	if variable.Type == "int" {
-		if vra.Value.GetExpressionType() == MATH_EXP {
-			byteCode = append(byteCode, vra.Value.(MathExpNode).GenerateByteCode(context)...)
-		} else if vra.Value.GetExpressionType() == IDENTIFIER_EXP {
-			tempVar, ok := context.Variables[vra.Value.(Identifier).Value.Value]
-			if !ok {
-				log.Fatalf("error: cannot use undeclared variable %v", vra.Value.(Identifier).Value.Value)
-			}
-			if tempVar.Type != variable.Type {
-				log.Fatalf("error: cannot reassign a variable of type %v with a variable of type %v", tempVar.Type, variable.Type)
-			}
-			byteCode = append(byteCode, loadVariable(tempVar)...)
-		}
-	}
-	byteCode = append(byteCode, storeVariable(variable)...)
-	return byteCode
+func (vra VarReAssignment) GenerateByteCode(context *GeneratorContext) {
+	generateTypedExpression(vra.Value, vra.Variable.Type, context)
+	storeVariable(*vra.Variable, context)
 }
 
-type VarAddToValue struct {
-	Ident      Identifier
-	ValueToAdd Expression
+// CompoundAssignment is an assignment like x += y, x <<= y or x++, which stores the result of
+// the operator applied to the variable and the value in the variable
+type CompoundAssignment struct {
+	Ident Identifier
+	// Operator is the operator as written in the source like "+=" or "++", Kind the operation it applies
+	Operator string
+	Kind     ExpNodeType
+	// x++ and x-- have the value 1
+	Value    Expression
+	Variable *Variable
+	// Operation is x op value, built by the checker
+	Operation *MathExpNode
+	Span      source.Span
 }
 
-func (vatv VarAddToValue) GetStatementType() string {
-	return VARADDTOVARIABLE
+func (ca CompoundAssignment) GetSpan() source.Span {
+	return ca.Span
 }
 
-func (vatv VarAddToValue) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	variable, ok := context.Variables[vatv.Ident.Value.Value]
-	if !ok {
-		log.Fatalf("error: cannot use undeclared variable %v", vatv.Ident.Value.Value)
-	}
-	if variable.Type == "int" {
-		if vatv.ValueToAdd.GetExpressionType() == MATH_EXP {
-			byteCode = append(byteCode, vatv.ValueToAdd.(MathExpNode).GenerateByteCode(context)...)
-		} else if vatv.ValueToAdd.GetExpressionType() == IDENTIFIER_EXP {
-			tempVar, ok := context.Variables[vatv.ValueToAdd.(Identifier).Value.Value]
-			if !ok {
-				log.Fatalf("error: cannot use undeclared variable %v", vatv.ValueToAdd.(Identifier).Value.Value)
-			}
-			if tempVar.Type != variable.Type {
-				log.Fatalf("error: cannot add a variable of type %v to a variable of type %v", tempVar.Type, variable.Type)
-			}
-			byteCode = append(byteCode, loadVariable(tempVar)...)
+func (ca CompoundAssignment) GetStatementType() string {
+	return COMPOUND_ASSIGN
+}
+
+func (ca CompoundAssignment) GenerateByteCode(context *GeneratorContext) {
+	variable, value := *ca.Variable, ca.Operation.Binary.Right
+	// adding a constant to an int variable does not need the operand stack
+	if variable.Type == "int" && (ca.Kind == ADD || ca.Kind == SUB) && value.isConstant() && value.Type() == "int" {
+		constant, _ := value.constantValue(context.Diagnostics)
+		amount := int(constant.(int32))
+		if ca.Kind == SUB {
+			amount = -amount
+		}
+		if amount >= math.MinInt16 && amount <= math.MaxInt16 {
+			context.Code.Emit(instructions.IINC, variable.VariableIndex, amount)
+			return
 		}
 	}
-	byteCode = append(byteCode, loadVariable(variable)...)
-	byteCode = append(byteCode, instructions.IADD)
-	byteCode = append(byteCode, storeVariable(variable)...)
-	return byteCode
-}
-
-func storeVariable(variable Variable) []byte {
-	byteCode := make([]byte, 0)
-	inst, ok := instructions.Istores[int32(variable.VariableIndex)]
-	if !ok {
-		byteCode = append(byteCode, instructions.ISTORE)
-		byteCode = append(byteCode, uint8(variable.VariableIndex))
-	} else {
-		byteCode = append(byteCode, inst)
-	}
-	return byteCode
+	generateTypedExpression(*ca.Operation, variable.Type, context)
+	storeVariable(variable, context)
 }
 
-func loadVariable(variable Variable) []byte {
-	byteCode := make([]byte, 0)
-	inst, ok := instructions.Iloads[int32(variable.VariableIndex)]
-	if !ok {
-		byteCode = append(byteCode, instructions.ILOAD)
-		byteCode = append(byteCode, uint8(variable.VariableIndex))
-	} else {
-		byteCode = append(byteCode, inst)
-	}
-	return byteCode
+// storeVariable stores the value on the stack, the assembler picks the short or wide form of the instruction
+func storeVariable(variable Variable, context *GeneratorContext) {
+	context.Code.Emit(instructions.ISTORE+typeOffset(variable.Type), variable.VariableIndex)
+}
+
+func loadVariable(variable Variable, context *GeneratorContext) {
+	context.Code.Emit(instructions.ILOAD+typeOffset(variable.Type), variable.VariableIndex)
 }
 
-// um imm scope deklarierte variablen zu löschen
-// den unterschied der in var map befindlichen einträge feststellen
-// alles was davor noch nicht da war löschen
+// Scope is a block of statements, the variables declared in it can only be used inside of it
 type Scope struct {
 	Statements []Statement
+	Span       source.Span
+}
+
+func (s Scope) GetSpan() source.Span {
+	return s.Span
+}
+
+func (s Scope) GenerateByteCode(context *GeneratorContext) {
+	for _, stmt := range s.Statements {
+		stmt.GenerateByteCode(context)
+	}
 }
 
 type FunctionArgument struct {
 	Name string
 	Type string
+	Span source.Span
+}
+
+func (fa FunctionArgument) GetSpan() source.Span {
+	return fa.Span
 }
 
 func (fa FunctionArgument) GetExpressionType() string {
@@ -217,6 +219,15 @@ type FunctionDefinition struct {
 	ReturnType string
 	Args       []FunctionArgument
 	Scope      Scope
+	// text of the /// comments in front of the definition
+	Doc string
+	// local variables of the function, set by the checker
+	Symbols *SymbolTable
+	Span    source.Span
+}
+
+func (fd FunctionDefinition) GetSpan() source.Span {
+	return fd.Span
 }
 
 type Function struct {
@@ -228,52 +239,89 @@ func (fd FunctionDefinition) GetStatementType() string {
 	return FUNCDEF
 }
 
-func (fd FunctionDefinition) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	variables := make(map[string]string, 0)
-	for k := range context.Variables {
-		variables[k] = k
+func (fd FunctionDefinition) GenerateByteCode(context *GeneratorContext) {
+	context.ReturnType = fd.ReturnType
+	context.Code = instructions.NewAssembler()
+	fd.Scope.GenerateByteCode(context)
+	// void functions may end without a return statement, the checker reports other functions
+	if fd.ReturnType == "void" && context.Code.Reachable() {
+		context.Code.Emit(instructions.RETURN)
+	} else if context.Code.Reachable() {
+		context.Diagnostics.Error(diagnostics.INTERNAL_ERROR, fd.Span, "function %v can end without a return statement", fd.Name)
+	}
+	context.ReturnType = ""
+	descriptor, _ := functionDescriptor(fd.Args, fd.ReturnType)
+	addMethod(fd, fd.Name, descriptor, uint16(fd.Symbols.MaxLocals), context)
+	if fd.Name == MAIN_FUNCTION {
+		generateMainWrapper(fd, descriptor, context)
+	}
+}
+
+// addMethod assembles the code in context.Code and adds it to the class as a method
+func addMethod(fd FunctionDefinition, name, descriptor string, maxLocals uint16, context *GeneratorContext) {
+	byteCode, err := context.Code.Assemble()
+	if err == nil {
+		err = context.Class.AddMethod(classfile.ACC_PUBLIC|classfile.ACC_STATIC, name, descriptor, byteCode, maxLocals)
 	}
-	for _, arg := range fd.Args {
-		context.Variables[arg.Name] = Variable{VariableIndex: *context.MaxLocals, Type: arg.Type}
-		*context.MaxLocals++
+	if err != nil {
+		context.Diagnostics.Error(diagnostics.INTERNAL_ERROR, fd.Span, "%v", strings.TrimPrefix(err.Error(), "error: "))
 	}
-	for _, stmt := range fd.Scope.Statements {
-		byteCode = append(byteCode, stmt.GenerateByteCode(context)...)
+}
+
+// generateMainWrapper adds the public static void main(String[]) entry point the jvm expects,
+// which calls the main function of the program and discards its result
+func generateMainWrapper(fd FunctionDefinition, descriptor string, context *GeneratorContext) {
+	if descriptor == MAIN_DESCRIPTOR {
+		return
 	}
-	for k := range context.Variables {
-		if _, ok := variables[k]; !ok {
-			delete(context.Variables, k)
-		}
+	context.Code = instructions.NewAssembler()
+	if len(fd.Args) == 1 {
+		context.Code.Emit(instructions.ALOAD, 0)
 	}
-	context.Class.AddMethod(fd.Name, generateFunctionDescriptor(len(fd.Args), fd.ReturnType), byteCode, uint16(*context.MaxLocals))
-	*context.MaxLocals = 0
-	return byteCode
-}
-
-func generateFunctionDescriptor(argCount int, retType string) string {
-	return fmt.Sprintf("(%v)%v", argCount, retType)
-}
-
-func declareVariable(name, typ string, context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	inst, ok := instructions.Istores[int32(*context.MaxLocals)]
-	if !ok {
-		byteCode = append(byteCode, instructions.ISTORE)
-		byteCode = append(byteCode, uint8(*context.MaxLocals))
-		context.Variables[name] = Variable{VariableIndex: *context.MaxLocals, Type: typ}
-		*context.MaxLocals++
-	} else {
-		byteCode = append(byteCode, inst)
-		context.Variables[name] = Variable{VariableIndex: *context.MaxLocals, Type: typ}
-		*context.MaxLocals++
+	context.Code.Invoke(instructions.INVOKESTATIC, context.Class.AddMethodRef(fd.Name, descriptor, context.Class.Name()), descriptor)
+	discardValue(fd.ReturnType, context)
+	context.Code.Emit(instructions.RETURN)
+	addMethod(fd, MAIN_FUNCTION, MAIN_DESCRIPTOR, 1, context)
+}
+
+// discardValue pops a value of the given type from the stack
+func discardValue(typ string, context *GeneratorContext) {
+	if slotSize(typ) == 1 {
+		context.Code.Emit(instructions.POP)
+	} else if slotSize(typ) == 2 {
+		context.Code.Emit(instructions.POP2)
 	}
-	return byteCode
+}
+
+// slotSize returns the number of local variable or stack slots a value of a type takes, long and double take two
+func slotSize(typ string) int {
+	descriptor, _ := typeDescriptor(typ)
+	return classfile.SlotSize(descriptor)
+}
+
+// println is overloaded for every type it can print, the overload is picked by the type of the argument
+var printlnOverloads []Function = []Function{
+	{ReturnType: "void", Args: []FunctionArgument{}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "int"}}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "long"}}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "float"}}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "double"}}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "bool"}}},
+	{ReturnType: "void", Args: []FunctionArgument{{Name: "value", Type: "string"}}},
 }
 
 type FunctionCall struct {
 	CalledFunctionName string
 	Arguments          []Expression
+	// the returned value of a call that is used as a statement is discarded
+	IsStatement bool
+	// the called function or println overload, set by the checker
+	Function *Function
+	Span     source.Span
+}
+
+func (fc FunctionCall) GetSpan() source.Span {
+	return fc.Span
 }
 
 func (fc FunctionCall) GetExpressionType() string {
@@ -284,41 +332,95 @@ func (fc FunctionCall) GetStatementType() string {
 	return FUNCTIONCALL
 }
 
-func (fc FunctionCall) GenerateByteCode(context *GeneratorContext) []byte {
-	byteCode := make([]byte, 0)
-	fun, ok := discoveredFunctions[fc.CalledFunctionName]
-	if !ok {
-		log.Fatalf("error: cannot call undefined function %v", fc.CalledFunctionName)
+// Type returns the return type of the called function
+func (fc FunctionCall) Type() string {
+	if fc.Function == nil {
+		return ""
 	}
-	if len(fun.Args) != len(fc.Arguments) {
-		log.Fatalf("error: not enough/too many arguments to call function %v", fc.CalledFunctionName)
+	return fc.Function.ReturnType
+}
+
+func (fc FunctionCall) GenerateByteCode(context *GeneratorContext) {
+	// println is mapped to System.out.println
+	isPrintln := fc.CalledFunctionName == PRINTLN_FUNCTION
+	fun := *fc.Function
+	descriptor, _ := functionDescriptor(fun.Args, fun.ReturnType)
+	if isPrintln {
+		context.Code.Field(instructions.GETSTATIC, context.Class.AddFieldRef("out", "Ljava/io/PrintStream;", "java/lang/System"), "Ljava/io/PrintStream;")
 	}
 	for index, arg := range fc.Arguments {
-		if arg.GetExpressionType() == MATH_EXP {
-			if fun.Args[index].Type != "int" {
-				log.Fatalf("error: expected argument of type %v", fun.Args[index].Type)
-			}
-			byteCode = append(byteCode, arg.(MathExpNode).GenerateByteCode(context)...)
-		} else if arg.GetExpressionType() == IDENTIFIER_EXP {
-			v, ok := context.Variables[arg.(Identifier).Value.Value]
-			if !ok {
-				log.Fatalf("error: cannot use undefined variable %v", arg.(Identifier).Value.Value)
-			}
-			if fun.Args[index].Type != v.Type {
-				log.Fatalf("error: expected argument of type %v", fun.Args[index].Type)
-			}
-			byteCode = append(byteCode, loadVariable(v)...)
-		} else if arg.GetExpressionType() == FUNCTIONCALL {
-			fc := arg.(FunctionCall)
-			byteCode = append(byteCode, fc.GenerateByteCode(context)...)
+		generateTypedExpression(arg, fun.Args[index].Type, context)
+	}
+	if isPrintln {
+		context.Code.Invoke(instructions.INVOKEVIRTUAL, context.Class.AddMethodRef(fc.CalledFunctionName, descriptor, "java/io/PrintStream"), descriptor)
+		return
+	}
+	context.Code.Invoke(instructions.INVOKESTATIC, context.Class.AddMethodRef(fc.CalledFunctionName, descriptor, context.Class.Name()), descriptor)
+	if fc.IsStatement {
+		discardValue(fun.ReturnType, context)
+	}
+}
+
+type ConditionalBranch struct {
+	Condition Expression
+	Scope     Scope
+}
+
+// IfStatement runs the scope of the first branch with a true condition or the else scope if there is none
+type IfStatement struct {
+	Branches []ConditionalBranch
+	Else     *Scope
+	Span     source.Span
+}
+
+func (is IfStatement) GetSpan() source.Span {
+	return is.Span
+}
+
+func (is IfStatement) GetStatementType() string {
+	return IF_STMT
+}
+
+func (is IfStatement) GenerateByteCode(context *GeneratorContext) {
+	end := context.Code.NewLabel()
+	for i, branch := range is.Branches {
+		next := end
+		if i < len(is.Branches)-1 || is.Else != nil {
+			next = context.Code.NewLabel()
+		}
+		generateCondition(branch.Condition, false, next, context)
+		branch.Scope.GenerateByteCode(context)
+		// a branch that returns does not need to jump to the end
+		if next != end && context.Code.Reachable() {
+			context.Code.Jump(instructions.GOTO, end)
 		}
+		if next != end {
+			context.Code.Mark(next)
+		}
+	}
+	if is.Else != nil {
+		is.Else.GenerateByteCode(context)
 	}
-	byteCode = append(byteCode, instructions.INVOKEVIRTUAL)
-	methodRefIndex := context.Class.AddMethodRef(fc.CalledFunctionName, generateFunctionDescriptor(len(fun.Args), fun.ReturnType), "Default")
-	byteCode = binary.BigEndian.AppendUint16(byteCode, methodRefIndex)
-	return byteCode
+	context.Code.Mark(end)
 }
 
+// ErrorStatement takes the place of a statement with syntax errors
+type ErrorStatement struct {
+	Span source.Span
+}
+
+func (e ErrorStatement) GetSpan() source.Span {
+	return e.Span
+}
+
+func (e ErrorStatement) GetStatementType() string {
+	return ERROR_STMT
+}
+
+func (e ErrorStatement) GenerateByteCode(context *GeneratorContext) {}
+
 type Program struct {
 	Statements []Statement
+	// every function the program can call by its name
+	Functions map[string]Function
 }
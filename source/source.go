@@ -0,0 +1,35 @@
+package source
+
+import "fmt"
+
+// Position is a location in a source file. Line and Column start at 1, the column counts
+// characters and Offset is the byte offset from the start of the file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%v:%v:%v", p.File, p.Line, p.Column)
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Span is the range of source code between Start and End (exclusive)
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String()
+}
+
+// To returns the span from the start of s to the end of other
+func (s Span) To(other Span) Span {
+	return Span{Start: s.Start, End: other.End}
+}
@@ -0,0 +1,19 @@
+fun f() int {
+    return 4;
+}
+
+fun g() string {
+    return "g";
+}
+
+fun main() {
+    let x int = f();
+    println(f());
+    println(1 + f());
+    println(f() * f() - 1);
+    println(g() + f());
+    println(-f());
+    if f() > 3 && f() < 5 {
+        println(x);
+    }
+}
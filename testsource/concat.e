@@ -0,0 +1,9 @@
+fun main() {
+    let a int = 3;
+    let b long = 4l;
+    let d double = 2.5;
+    let s string = "x";
+    let t bool = true;
+    println("a=" + a + ", b=" + b + " d=" + d + s + t);
+    println(s + a);
+}
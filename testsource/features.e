@@ -0,0 +1,57 @@
+/// doc for f
+fun f(n int) int {
+    let r int = 1;
+    for i in 1..n {
+        r *= i;
+    }
+    return r;
+}
+
+/* outer /* nested */ still comment */
+fun main() {
+    let s string = "hi\nA";
+    println(s + " " + 5);
+    let b bool = 3 < 4 && !(2 == 3);
+    println(b);
+    if b { println(1); } else if 2 > 3 { println(2); } else { println(3); }
+    let x int = 1000000;
+    println(x);
+    let big int = 200;
+    println(big);
+    let l long = 10L;
+    let d double = 1.5e2;
+    let fl float = 2.5f;
+    println(l * 3);
+    println(d / 4);
+    println(fl);
+    println((d as int));
+    println(2 ** 3 ** 2);
+    let p int = 3;
+    println(p ** 4);
+    println(d ** 2.0);
+    println(-p);
+    println(+p);
+    println(7 % 3);
+    println(~p);
+    println(1 << 4 | 1);
+    println(-16 >>> 28);
+    println(-16 >> 2);
+    println(6 ^ 3 & 5);
+    p++;
+    p--;
+    p += 100;
+    p -= 1000;
+    p **= 2;
+    println(p);
+    let w int = 0;
+    outer: while w < 10 {
+        w++;
+        if w == 3 { continue outer; }
+        if w == 6 { break; }
+        println(w);
+    }
+    for let k int = 0; k < 3; k++ {
+        println(k);
+    }
+    println(f(5));
+}
@@ -0,0 +1,33 @@
+fun find(target int) int {
+    let found int = -1;
+    rows: for row in 0..10 {
+        for column in 0..10 {
+            let k int = 0;
+            while true {
+                if row * 10 + column + k == target {
+                    found = row * 10 + column;
+                    break rows;
+                }
+                k += 1;
+                if k > 0 { break; }
+            }
+        }
+    }
+    return found;
+}
+
+fun main() {
+    println(find(42));
+    println(find(100));
+    let count int = 0;
+    outer: while count < 100 {
+        let i int = 0;
+        while true {
+            i += 1;
+            count += 1;
+            if i == 3 { continue outer; }
+            if count > 7 { break outer; }
+        }
+    }
+    println(count);
+}
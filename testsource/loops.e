@@ -0,0 +1,34 @@
+fun main() {
+    let i int = 0;
+    while i < 5 {
+        i += 1;
+        if i == 2 { continue; }
+        if i == 4 { break; }
+        println(i);
+    }
+    for let j int = 0; j < 3; j += 1 {
+        println("j" + j);
+    }
+    let sum int = 0;
+    for k in 0..5 + 5 {
+        sum += k;
+    }
+    println(sum);
+    outer: for a in 0..3 {
+        for b in 0..3 {
+            if b == 2 { continue outer; }
+            if a == 2 { break outer; }
+            println("" + a + b);
+        }
+    }
+    let n int = 0;
+    for ; ; {
+        n += 1;
+        if n > 3 { break; }
+    }
+    println(n);
+    let m int = 0;
+    for m = 1; m < 4; m += m {
+    }
+    println(m);
+}
@@ -0,0 +1,49 @@
+fun half(x double) double {
+    return x / 2;
+}
+
+fun big(a long, b int) long {
+    return a * b;
+}
+
+fun main() {
+    let a long = 3000000000L;
+    let b int = 7;
+    let c long = a + b;
+    println(c);
+    let f float = 1.5f;
+    let d double = 2.5;
+    println(f * 2);
+    println(d + f);
+    println(half(3));
+    println(big(a, 3));
+    println(1e10);
+    println(0.0001);
+    println(-1.25e-5);
+    println(3.9 as int);
+    println(-3.9 as long);
+    println(c as float);
+    println(10 / 4 as double);
+    println((10 as double) / 4);
+    let e double = 1;
+    e += 0.5;
+    println(e);
+    if a > 2999999999L && d >= 2 {
+        println("cmp ok");
+    }
+    if d != d {
+        println("nan");
+    }
+    let z double = 0.0 / 0.0;
+    if z < 1 || z > 1 || z == z {
+        println("bad nan");
+    }
+    println(z);
+    println(1.0 / 0);
+    println("concat " + a + " " + f + " " + d);
+    let i int = 1e300 as int;
+    println(i);
+    println(0L);
+    println(1L);
+    println(2.0f);
+}
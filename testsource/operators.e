@@ -0,0 +1,38 @@
+fun main() {
+    let a int = 17;
+    let b long = 5L;
+    println(-a);
+    println(+a);
+    println(-b * 2);
+    println(-(a + 1));
+    let d double = 2.5;
+    println(-d);
+    println(a % 5);
+    println(-a % 5);
+    println(b % 3);
+    println(d % 1);
+    println(a & 3);
+    println(a | 8);
+    println(a ^ 1);
+    println(~a);
+    println(~b);
+    println(a << 2);
+    println(-a >> 1);
+    println(-a >>> 28);
+    println(b << 40);
+    println(-1L >>> 60);
+    println(1 << 33);
+    println(a * 3 / 2);
+    println(a / 2 * 2);
+    println(a - 2 - 3);
+    println(1 + 2 << 1);
+    println(a & 1 | 2 ^ 3);
+    let t bool = true;
+    println(t & false);
+    println(t | false);
+    println(t ^ true);
+    println((5 & 3) == 1);
+    println(1 < 2 == true);
+    println(a > 1 && a < 20);
+    println(a >= 17);
+}
@@ -0,0 +1,35 @@
+fun ipow(a int, b int) int {
+    return a ** b;
+}
+
+fun lpow(a long, b int) long {
+    return a ** b;
+}
+
+fun main() {
+    println(2 ** 3 ** 2);
+    println(2 ** 10);
+    println(2L ** 62);
+    println(2 ** 31);
+    println(2 ** -1);
+    println(-1 ** -3);
+    println(2.0 ** 0.5);
+    println(2f ** 3);
+    println(ipow(3, 4));
+    println(ipow(-2, 5));
+    println(ipow(2, 31));
+    println(ipow(1, -2147483648));
+    println(ipow(2, -1));
+    println(lpow(3L, 39));
+    println(lpow(-1L, -7));
+    let x double = 9;
+    println(x ** 0.5);
+    let y float = 2;
+    println(y ** 2 ** 3);
+    println(2 * 3 ** 2);
+    println(1 + 2 ** 3 as long);
+    // ** binds tighter than a prefix minus
+    println(-2 ** 2);
+    println((-2) ** 2);
+    println(2 ** -1 ** 2);
+}
@@ -0,0 +1,22 @@
+fun countdown(n int) {
+    while true {
+        if n == 0 {
+            println("done");
+            return;
+        }
+        println(n);
+        n -= 1;
+    }
+}
+
+fun positive(n int) {
+    if n <= 0 { return; }
+    println("positive " + n);
+}
+
+fun main() {
+    countdown(2);
+    positive(-1);
+    positive(3);
+    return;
+}
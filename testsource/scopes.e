@@ -0,0 +1,57 @@
+fun pick(a int) int {
+    if a > 0 {
+        let x int = 1;
+        return x;
+    } else {
+        let x long = 2L;
+        return x as int;
+    }
+}
+
+fun loop(z int) int {
+    while true {
+        return 3;
+    }
+}
+
+fun constant(z int) int {
+    if true {
+        return 4;
+    }
+}
+
+fun sum(z int) long {
+    let total long = 0L;
+    for k in 0..4 {
+        let sq long = k * k;
+        total += sq;
+    }
+    for k in 0..3 {
+        let sq double = 1.5;
+        total += 1;
+    }
+    for let i int = 0; i < 2; i++ {
+        let d double = i;
+        total += d as long;
+    }
+    return total;
+}
+
+fun forever(z int) int {
+    outer: for ; ; {
+        for let i int = 0; ; i++ {
+            if i > 5 {
+                return i;
+            }
+        }
+    }
+}
+
+fun main() {
+    println(pick(1));
+    println(pick(-1));
+    println(loop(0));
+    println(constant(0));
+    println(sum(0));
+    println(forever(0));
+}
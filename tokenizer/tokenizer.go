@@ -1,10 +1,14 @@
 package tokenizer
 
 import (
+	"compiler/diagnostics"
+	"compiler/source"
 	"io"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
 )
 
 type TokenType int
@@ -27,27 +31,121 @@ const (
 	CURL_CLOSE_PAR
 	FUN_DEF
 	COLON
+	OPEN_BRACKET
+	CLOSE_BRACKET
+	DOT
+	DOC_COMMENT
+	STRING
+	BOOLEAN
+	EQUALS
+	NOT_EQUALS
+	LESS
+	LESS_EQUALS
+	GREATER
+	GREATER_EQUALS
+	AND
+	OR
+	NOT
+	IF
+	ELSE
+	WHILE
+	FOR
+	IN
+	BREAK
+	CONTINUE
+	RANGE
+	LABEL_COLON
+	AS
+	MOD
+	BIT_AND
+	BIT_OR
+	BIT_XOR
+	BIT_NOT
+	SHL
+	SHR
+	USHR
+	PLUS_ASSIGN
+	MINUS_ASSIGN
+	MUL_ASSIGN
+	DIV_ASSIGN
+	MOD_ASSIGN
+	POW_ASSIGN
+	AND_ASSIGN
+	OR_ASSIGN
+	XOR_ASSIGN
+	SHL_ASSIGN
+	SHR_ASSIGN
+	USHR_ASSIGN
+	INCREMENT
+	DECREMENT
 )
 
+var keywords map[string]TokenType = map[string]TokenType{
+	"return":   RETURN,
+	"let":      VARDECL,
+	"fun":      FUN_DEF,
+	"if":       IF,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"true":     BOOLEAN,
+	"false":    BOOLEAN,
+	"as":       AS,
+}
+
 type Token struct {
 	Value string
 	Type  TokenType
+	Span  source.Span
 }
 
 type Tokenizer struct {
-	Reader *strings.Reader
-	src    []rune
+	Reader      *strings.Reader
+	src         string
+	file        string
+	lineStarts  []int
+	diagnostics *diagnostics.Diagnostics
 }
 
-func NewTokenizer(source string) *Tokenizer {
-	tokenizer := Tokenizer{src: []rune(source), Reader: strings.NewReader(source)}
+func NewTokenizer(file, src string, diags *diagnostics.Diagnostics) *Tokenizer {
+	lineStarts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	tokenizer := Tokenizer{src: src, file: file, lineStarts: lineStarts, Reader: strings.NewReader(src), diagnostics: diags}
 	return &tokenizer
 }
 
+// offset returns the byte offset of the next rune
+func (t *Tokenizer) offset() int {
+	return len(t.src) - t.Reader.Len()
+}
+
+func (t *Tokenizer) position(offset int) source.Position {
+	line := sort.Search(len(t.lineStarts), func(i int) bool { return t.lineStarts[i] > offset }) - 1
+	column := len([]rune(t.src[t.lineStarts[line]:offset])) + 1
+	return source.Position{File: t.file, Line: line + 1, Column: column, Offset: offset}
+}
+
+// span returns the span from start to the current position
+func (t *Tokenizer) span(start int) source.Span {
+	return source.Span{Start: t.position(start), End: t.position(t.offset())}
+}
+
+func (t *Tokenizer) token(typ TokenType, value string, start int) Token {
+	return Token{Type: typ, Value: value, Span: t.span(start)}
+}
+
 func (t *Tokenizer) GetTokens() []Token {
 	tokens := make([]Token, 0)
 	tempWord := ""
 	for {
+		start := t.offset()
 		cur, _, err := t.Reader.ReadRune()
 		if err == io.EOF {
 			break
@@ -57,7 +155,7 @@ func (t *Tokenizer) GetTokens() []Token {
 			for {
 				temp, _, err := t.Reader.ReadRune()
 				if err != nil {
-					log.Fatalf("error: %v", err)
+					break
 				}
 				if !(unicode.IsLetter(temp) || unicode.IsDigit(temp)) {
 					t.Reader.UnreadRune()
@@ -65,80 +163,347 @@ func (t *Tokenizer) GetTokens() []Token {
 				}
 				tempWord += string(temp)
 			}
-			if tempWord == "return" {
-				tokens = append(tokens, Token{Type: RETURN})
-				tempWord = ""
-				continue
-			} else if tempWord == "let" {
-				tokens = append(tokens, Token{Type: VARDECL})
-				tempWord = ""
-				continue
-			} else if tempWord == "fun" {
-				tokens = append(tokens, Token{Type: FUN_DEF})
+			if typ, ok := keywords[tempWord]; ok {
+				value := ""
+				if typ == BOOLEAN {
+					value = tempWord
+				}
+				tokens = append(tokens, t.token(typ, value, start))
 				tempWord = ""
 				continue
 			}
-			tokens = append(tokens, Token{Type: IDENTIFIER, Value: tempWord})
+			tokens = append(tokens, t.token(IDENTIFIER, tempWord, start))
 			tempWord = ""
 			continue
 		} else if unicode.IsDigit(cur) {
-			tempWord += string(cur)
-			for {
-				temp, _, err := t.Reader.ReadRune()
-				if err == io.EOF {
-					break
-				}
-				if !unicode.IsDigit(temp) {
-					t.Reader.UnreadRune()
-					break
-				}
-				tempWord += string(temp)
-			}
-			tokens = append(tokens, Token{Type: NUMBER, Value: tempWord})
-			tempWord = ""
+			tokens = append(tokens, t.token(NUMBER, t.readNumber(start), start))
 			continue
 		} else if cur == ';' {
-			tokens = append(tokens, Token{Type: SEMICOLON})
+			tokens = append(tokens, t.token(SEMICOLON, "", start))
 			continue
 		} else if unicode.IsSpace(cur) || cur == '\t' {
 			continue
 		} else if cur == '(' {
-			tokens = append(tokens, Token{Type: OPEN_PAR})
+			tokens = append(tokens, t.token(OPEN_PAR, "", start))
 		} else if cur == ')' {
-			tokens = append(tokens, Token{Type: CLOSE_PAR})
+			tokens = append(tokens, t.token(CLOSE_PAR, "", start))
+		} else if cur == '+' && strings.HasPrefix(t.src[t.offset():], "+") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(INCREMENT, "", start))
 		} else if cur == '+' {
-			tokens = append(tokens, Token{Type: PLUS})
+			tokens = append(tokens, t.token(t.withEquals(PLUS, PLUS_ASSIGN), "", start))
+		} else if cur == '-' && strings.HasPrefix(t.src[t.offset():], "-") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(DECREMENT, "", start))
 		} else if cur == '-' {
-			tokens = append(tokens, Token{Type: MINUS})
+			tokens = append(tokens, t.token(t.withEquals(MINUS, MINUS_ASSIGN), "", start))
+		} else if cur == '*' && strings.HasPrefix(t.src[t.offset():], "*") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(t.withEquals(POW, POW_ASSIGN), "", start))
 		} else if cur == '*' {
-			r, _, err := t.Reader.ReadRune()
-			if err == io.EOF {
-				break
-			}
-			if r == '*' {
-				tokens = append(tokens, Token{Type: POW})
+			tokens = append(tokens, t.token(t.withEquals(MUL, MUL_ASSIGN), "", start))
+		} else if cur == '/' {
+			next, _, err := t.Reader.ReadRune()
+			if err == nil && next == '/' {
+				// exactly three slashes start a doc comment, more are a normal comment
+				text := t.readLine()
+				if strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "//") {
+					tokens = append(tokens, t.token(DOC_COMMENT, strings.TrimPrefix(text[1:], " "), start))
+				}
 				continue
-			} else {
+			} else if err == nil && next == '*' {
+				t.skipBlockComment(start)
+				continue
+			} else if err == nil {
 				t.Reader.UnreadRune()
 			}
-			tokens = append(tokens, Token{Type: MUL})
-		} else if cur == '/' {
-			tokens = append(tokens, Token{Type: DIV})
+			tokens = append(tokens, t.token(t.withEquals(DIV, DIV_ASSIGN), "", start))
 		} else if cur == '=' {
-			tokens = append(tokens, Token{Type: ASSIGN})
+			tokens = append(tokens, t.token(t.withEquals(ASSIGN, EQUALS), "", start))
+		} else if cur == '!' {
+			tokens = append(tokens, t.token(t.withEquals(NOT, NOT_EQUALS), "", start))
+		} else if cur == '<' && strings.HasPrefix(t.src[t.offset():], "<") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(t.withEquals(SHL, SHL_ASSIGN), "", start))
+		} else if cur == '<' {
+			tokens = append(tokens, t.token(t.withEquals(LESS, LESS_EQUALS), "", start))
+		} else if cur == '>' && strings.HasPrefix(t.src[t.offset():], ">>") {
+			t.Reader.Seek(2, io.SeekCurrent)
+			tokens = append(tokens, t.token(t.withEquals(USHR, USHR_ASSIGN), "", start))
+		} else if cur == '>' && strings.HasPrefix(t.src[t.offset():], ">") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(t.withEquals(SHR, SHR_ASSIGN), "", start))
+		} else if cur == '>' {
+			tokens = append(tokens, t.token(t.withEquals(GREATER, GREATER_EQUALS), "", start))
+		} else if (cur == '&' || cur == '|') && strings.HasPrefix(t.src[t.offset():], string(cur)) {
+			t.Reader.ReadRune()
+			if cur == '&' {
+				tokens = append(tokens, t.token(AND, "", start))
+			} else {
+				tokens = append(tokens, t.token(OR, "", start))
+			}
+		} else if cur == '&' {
+			tokens = append(tokens, t.token(t.withEquals(BIT_AND, AND_ASSIGN), "", start))
+		} else if cur == '|' {
+			tokens = append(tokens, t.token(t.withEquals(BIT_OR, OR_ASSIGN), "", start))
+		} else if cur == '^' {
+			tokens = append(tokens, t.token(t.withEquals(BIT_XOR, XOR_ASSIGN), "", start))
+		} else if cur == '~' {
+			tokens = append(tokens, t.token(BIT_NOT, "", start))
+		} else if cur == '%' {
+			tokens = append(tokens, t.token(t.withEquals(MOD, MOD_ASSIGN), "", start))
 		} else if cur == '{' {
-			tokens = append(tokens, Token{Type: CURL_OPEN_PAR})
+			tokens = append(tokens, t.token(CURL_OPEN_PAR, "", start))
 		} else if cur == '}' {
-			tokens = append(tokens, Token{Type: CURL_CLOSE_PAR})
+			tokens = append(tokens, t.token(CURL_CLOSE_PAR, "", start))
 		} else if cur == ',' {
-			tokens = append(tokens, Token{Type: COLON})
+			tokens = append(tokens, t.token(COLON, "", start))
+		} else if cur == '[' {
+			tokens = append(tokens, t.token(OPEN_BRACKET, "", start))
+		} else if cur == ']' {
+			tokens = append(tokens, t.token(CLOSE_BRACKET, "", start))
+		} else if cur == '"' {
+			tokens = append(tokens, t.token(STRING, t.readString(start), start))
+		} else if cur == '.' && strings.HasPrefix(t.src[t.offset():], ".") {
+			t.Reader.ReadRune()
+			tokens = append(tokens, t.token(RANGE, "", start))
+		} else if cur == '.' {
+			tokens = append(tokens, t.token(DOT, "", start))
+		} else if cur == ':' {
+			tokens = append(tokens, t.token(LABEL_COLON, "", start))
 		} else {
-			log.Fatalf("error unrecognized token ('%v')", string(cur))
+			t.diagnostics.Error(diagnostics.UNRECOGNIZED_TOKEN, t.span(start), "unrecognized token ('%v')", string(cur))
 		}
 	}
 	return tokens
 }
 
+// withEquals returns typWithEquals and consumes the '=' if the next rune is one, so '<' and '<=' can be told apart
+func (t *Tokenizer) withEquals(typ, typWithEquals TokenType) TokenType {
+	if strings.HasPrefix(t.src[t.offset():], "=") {
+		t.Reader.ReadRune()
+		return typWithEquals
+	}
+	return typ
+}
+
+// readNumber reads a number literal like 12, 1.5, 2e-3, 10L, 1.5f or 3d and returns its text. The
+// dot only belongs to the number if a digit follows it, so 1..5 is still a range
+func (t *Tokenizer) readNumber(start int) string {
+	end := skipDigits(t.src, start)
+	isDecimal := false
+	if end+1 < len(t.src) && t.src[end] == '.' && isDigit(t.src[end+1]) {
+		end = skipDigits(t.src, end+1)
+		isDecimal = true
+	}
+	if end < len(t.src) && (t.src[end] == 'e' || t.src[end] == 'E') {
+		exponent := end + 1
+		if exponent < len(t.src) && (t.src[exponent] == '+' || t.src[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(t.src) && isDigit(t.src[exponent]) {
+			end = skipDigits(t.src, exponent)
+			isDecimal = true
+		}
+	}
+	isLong := false
+	if end < len(t.src) && strings.ContainsRune("lLfFdD", rune(t.src[end])) &&
+		(end+1 == len(t.src) || !isIdentifierChar(t.src[end+1])) {
+		isLong = t.src[end] == 'l' || t.src[end] == 'L'
+		end++
+	}
+	t.Reader.Seek(int64(end), io.SeekStart)
+	if isDecimal && isLong {
+		t.diagnostics.Error(diagnostics.INVALID_NUMBER, t.span(start), "long literals cannot have a fraction or an exponent").
+			Note("use the suffix d for double or f for float literals")
+	}
+	return t.src[start:end]
+}
+
+func skipDigits(src string, offset int) int {
+	for offset < len(src) && isDigit(src[offset]) {
+		offset++
+	}
+	return offset
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentifierChar(c byte) bool {
+	return isDigit(c) || c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
+
+// readLine reads the rest of the current line without the line break
+func (t *Tokenizer) readLine() string {
+	line := ""
+	for {
+		cur, _, err := t.Reader.ReadRune()
+		if err != nil {
+			break
+		}
+		if cur == '\n' {
+			t.Reader.UnreadRune()
+			break
+		}
+		line += string(cur)
+	}
+	return strings.TrimSuffix(line, "\r")
+}
+
+// readString reads a string literal up to the closing quote and returns its value with
+// the escape sequences replaced. String literals cannot span multiple lines
+func (t *Tokenizer) readString(start int) string {
+	value := make([]rune, 0)
+	for {
+		escapeStart := t.offset()
+		cur, _, err := t.Reader.ReadRune()
+		if err != nil || cur == '\n' {
+			if err == nil {
+				t.Reader.UnreadRune()
+			}
+			t.diagnostics.Error(diagnostics.UNTERMINATED_STRING, t.span(start), "unterminated string literal").
+				Suggest(source.Span{Start: t.position(t.offset()), End: t.position(t.offset())}, "\"", "close the string here")
+			return string(value)
+		}
+		if cur == '"' {
+			return string(value)
+		}
+		if cur != '\\' {
+			value = append(value, cur)
+			continue
+		}
+		if char, ok := t.readEscape(escapeStart); ok {
+			value = append(value, char)
+		}
+	}
+}
+
+var escapes map[rune]rune = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'b':  '\b',
+	'f':  '\f',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// readEscape reads the escape sequence after a backslash. Unicode escapes are written as
+// \uXXXX with four hex digits, where surrogate pairs are combined, or as \u{X} with up to six
+func (t *Tokenizer) readEscape(start int) (rune, bool) {
+	cur, _, err := t.Reader.ReadRune()
+	if err != nil {
+		return 0, false
+	}
+	if char, ok := escapes[cur]; ok {
+		return char, true
+	}
+	if cur != 'u' {
+		if cur == '\n' {
+			t.Reader.UnreadRune()
+		}
+		t.diagnostics.Error(diagnostics.INVALID_ESCAPE, t.span(start), "unknown escape sequence '\\%v'", string(cur)).
+			Note("valid escape sequences are \\n, \\t, \\r, \\b, \\f, \\0, \\\\, \\\", \\' and \\u")
+		return 0, false
+	}
+	char, ok := t.readUnicodeEscape(start)
+	if !ok {
+		return 0, false
+	}
+	if utf16.IsSurrogate(char) {
+		// a high surrogate has to be followed by the escape of a low surrogate
+		lowStart := t.offset()
+		if strings.HasPrefix(t.src[lowStart:], "\\u") && char < 0xdc00 {
+			t.Reader.Seek(2, io.SeekCurrent)
+			low, ok := t.readUnicodeEscape(lowStart)
+			if !ok {
+				return 0, false
+			}
+			if combined := utf16.DecodeRune(char, low); combined != unicode.ReplacementChar {
+				return combined, true
+			}
+		}
+		t.diagnostics.Error(diagnostics.INVALID_ESCAPE, t.span(start), "unpaired surrogate in unicode escape").
+			Note("characters outside of the basic multilingual plane can be written as \\u{1F600}")
+		return 0, false
+	}
+	return char, true
+}
+
+// readUnicodeEscape reads the hex digits of a unicode escape after the u
+func (t *Tokenizer) readUnicodeEscape(start int) (rune, bool) {
+	digits := ""
+	braced := strings.HasPrefix(t.src[t.offset():], "{")
+	if braced {
+		t.Reader.ReadRune()
+	}
+	for (braced && len(digits) < 6) || len(digits) < 4 {
+		cur, _, err := t.Reader.ReadRune()
+		if err != nil {
+			break
+		}
+		if !unicode.Is(unicode.ASCII_Hex_Digit, cur) {
+			t.Reader.UnreadRune()
+			break
+		}
+		digits += string(cur)
+	}
+	if braced {
+		if close, _, err := t.Reader.ReadRune(); err != nil || close != '}' {
+			if err == nil {
+				t.Reader.UnreadRune()
+			}
+			t.diagnostics.Error(diagnostics.INVALID_ESCAPE, t.span(start), "expected '}' to end unicode escape")
+			return 0, false
+		}
+	}
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || (!braced && len(digits) != 4) {
+		t.diagnostics.Error(diagnostics.INVALID_ESCAPE, t.span(start), "invalid unicode escape").
+			Note("unicode escapes are written as \\uXXXX or \\u{X} with up to six hex digits")
+		return 0, false
+	}
+	if value > unicode.MaxRune || (braced && utf16.IsSurrogate(rune(value))) {
+		t.diagnostics.Error(diagnostics.INVALID_ESCAPE, t.span(start), "invalid unicode code point %X", value)
+		return 0, false
+	}
+	return rune(value), true
+}
+
+// skipBlockComment skips a /* */ comment, which can contain other block comments
+func (t *Tokenizer) skipBlockComment(start int) {
+	depth := 1
+	for depth > 0 {
+		cur, _, err := t.Reader.ReadRune()
+		if err != nil {
+			t.diagnostics.Error(diagnostics.UNTERMINATED_COMMENT, source.Span{Start: t.position(start), End: t.position(start + 2)}, "unterminated block comment").
+				Note("block comments can be nested, every '/*' needs a matching '*/'")
+			return
+		}
+		if cur != '/' && cur != '*' {
+			continue
+		}
+		next, _, err := t.Reader.ReadRune()
+		if err != nil {
+			continue
+		}
+		if cur == '/' && next == '*' {
+			depth++
+		} else if cur == '*' && next == '/' {
+			depth--
+		} else {
+			t.Reader.UnreadRune()
+		}
+	}
+}
+
 func IsOperator(t Token) bool {
-	return (t.Type == PLUS || t.Type == MINUS || t.Type == MUL || t.Type == DIV)
+	return (t.Type == PLUS || t.Type == MINUS || t.Type == MUL || t.Type == DIV || t.Type == POW || t.Type == MOD ||
+		t.Type == EQUALS || t.Type == NOT_EQUALS || t.Type == LESS || t.Type == LESS_EQUALS ||
+		t.Type == GREATER || t.Type == GREATER_EQUALS || t.Type == AND || t.Type == OR || t.Type == AS ||
+		t.Type == BIT_AND || t.Type == BIT_OR || t.Type == BIT_XOR || t.Type == SHL || t.Type == SHR || t.Type == USHR)
 }
@@ -0,0 +1,89 @@
+package tokenizer
+
+import (
+	"compiler/diagnostics"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func tokenize(src string) ([]Token, []diagnostics.Diagnostic) {
+	diags := diagnostics.NewDiagnostics()
+	tokens := NewTokenizer("test.e", src, diags).GetTokens()
+	return tokens, diags.List()
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		types []TokenType
+		// values of the doc comment tokens
+		docs []string
+		// code and position of every diagnostic
+		codes []string
+	}{
+		{"line comment", "a // b c;\nd", []TokenType{IDENTIFIER, IDENTIFIER}, nil, nil},
+		{"line comment at the end", "a // b", []TokenType{IDENTIFIER}, nil, nil},
+		{"four slashes are no doc comment", "//// a\nb", []TokenType{IDENTIFIER}, nil, nil},
+		{"doc comment", "/// first\n///second\r\nfun", []TokenType{DOC_COMMENT, DOC_COMMENT, FUN_DEF}, []string{"first", "second"}, nil},
+		{"block comment", "a /* b; */ c", []TokenType{IDENTIFIER, IDENTIFIER}, nil, nil},
+		{"nested block comment", "a /* b /* c */ d */ e", []TokenType{IDENTIFIER, IDENTIFIER}, nil, nil},
+		{"block comment over lines", "a /* b\n// c */\nd", []TokenType{IDENTIFIER, IDENTIFIER}, nil, nil},
+		{"unterminated block comment", "a /* b /* c */ d", []TokenType{IDENTIFIER}, nil, []string{diagnostics.UNTERMINATED_COMMENT + " 1:3"}},
+		{"division is no comment", "a / b", []TokenType{IDENTIFIER, DIV, IDENTIFIER}, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, list := tokenize(test.src)
+			types, docs := make([]TokenType, 0), make([]string, 0)
+			for _, token := range tokens {
+				types = append(types, token.Type)
+				if token.Type == DOC_COMMENT {
+					docs = append(docs, token.Value)
+				}
+			}
+			codes := make([]string, 0)
+			for _, diagnostic := range list {
+				codes = append(codes, fmt.Sprintf("%v %v:%v", diagnostic.Code, diagnostic.Span.Start.Line, diagnostic.Span.Start.Column))
+			}
+			if !slices.Equal(types, test.types) {
+				t.Errorf("expected the tokens %v but got %v", test.types, types)
+			}
+			if !slices.Equal(docs, test.docs) {
+				t.Errorf("expected the doc comments %q but got %q", test.docs, docs)
+			}
+			if !slices.Equal(codes, test.codes) {
+				t.Errorf("expected the diagnostics %v but got %v", test.codes, codes)
+			}
+		})
+	}
+}
+
+func TestPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// start and end of every token as line:column-line:column and the byte offsets
+		spans []string
+	}{
+		{"single line", "let x = 10;", []string{"1:1-1:4 0-3", "1:5-1:6 4-5", "1:7-1:8 6-7", "1:9-1:11 8-10", "1:11-1:12 10-11"}},
+		{"multi-byte runes", "äö = \"ü€\" + x", []string{"1:1-1:3 0-4", "1:4-1:5 5-6", "1:6-1:10 7-14", "1:11-1:12 15-16", "1:13-1:14 17-18"}},
+		{"line feed", "a\n  b\n\nc", []string{"1:1-1:2 0-1", "2:3-2:4 4-5", "4:1-4:2 7-8"}},
+		{"carriage return and line feed", "a\r\nb\r\n\tc", []string{"1:1-1:2 0-1", "2:1-2:2 3-4", "3:2-3:3 7-8"}},
+		{"block comment over lines", "/* ä\n */ x", []string{"2:5-2:6 10-11"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, _ := tokenize(test.src)
+			spans := make([]string, 0, len(tokens))
+			for _, token := range tokens {
+				start, end := token.Span.Start, token.Span.End
+				spans = append(spans, fmt.Sprintf("%v:%v-%v:%v %v-%v", start.Line, start.Column, end.Line, end.Column, start.Offset, end.Offset))
+			}
+			if !slices.Equal(spans, test.spans) {
+				t.Errorf("expected the spans %v but got %v", test.spans, spans)
+			}
+		})
+	}
+}